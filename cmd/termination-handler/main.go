@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/termination"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+func main() {
+	pollIntervalSeconds := flag.Int64(
+		"poll-interval-seconds",
+		5,
+		"interval in seconds at which termination notice endpoint should be checked (Default: 5)",
+	)
+
+	nodeName := flag.String(
+		"node-name",
+		"",
+		"name of the node that the termination handler is running on",
+	)
+
+	metadataURL := flag.String(
+		"metadata-url",
+		termination.DefaultMetadataURL,
+		"URL of the ECS instance metadata service",
+	)
+
+	klog.InitFlags(nil)
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	// Get the node name from the environment if not set on the command line
+	if *nodeName == "" {
+		*nodeName = os.Getenv("NODE_NAME")
+	}
+	if *nodeName == "" {
+		klog.Fatalf("--node-name flag or NODE_NAME environment variable must be set")
+	}
+
+	// Get a config to talk to the apiserver
+	cfg, err := config.GetConfig()
+	if err != nil {
+		klog.Fatalf("Error getting configuration: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		klog.Fatalf("Error setting up scheme: %v", err)
+	}
+	if err := machinev1beta1.AddToScheme(scheme); err != nil {
+		klog.Fatalf("Error setting up scheme: %v", err)
+	}
+
+	logger := klogr.New()
+	handler, err := termination.NewHandler(logger, cfg, scheme, time.Duration(*pollIntervalSeconds)*time.Second, *metadataURL, *nodeName)
+	if err != nil {
+		klog.Fatalf("Error creating handler: %v", err)
+	}
+
+	// Start the termination handler
+	if err := handler.Run(signals.SetupSignalHandler().Done()); err != nil {
+		klog.Fatalf("Error starting termination handler: %v", err)
+	}
+}
@@ -18,6 +18,7 @@ package machine
 
 import (
 	"context"
+	"errors"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
@@ -89,6 +90,7 @@ func (a *Actuator) Create(ctx context.Context, machine *machinev1.Machine) error
 	scope, err := newMachineScope(machineScopeParams{
 		Context:                   ctx,
 		client:                    a.client,
+		eventRecorder:             a.eventRecorder,
 		machine:                   machine,
 		alibabacloudClientBuilder: a.alibabacloudClientBuilder,
 		configManagedClient:       a.configManagedClient,
@@ -102,6 +104,12 @@ func (a *Actuator) Create(ctx context.Context, machine *machinev1.Machine) error
 		if err := scope.patchMachine(); err != nil {
 			return err
 		}
+		// Errors requesting a requeue are not terminal, let the machine controller retry
+		var requeueAfterError *machineapierrors.RequeueAfterError
+		if errors.As(err, &requeueAfterError) {
+			klog.Infof("%s: requeueing machine creation: %v", machine.Name, err)
+			return requeueAfterError
+		}
 		return a.handleMachineError(machine, machineapierrors.InvalidMachineConfiguration("failed to reconcile machine %q: %v", machine.Name, err), createEventAction)
 	}
 	a.eventRecorder.Eventf(machine, corev1.EventTypeNormal, createEventAction, "Created Machine %v", machine.GetName())
@@ -115,6 +123,7 @@ func (a *Actuator) Update(ctx context.Context, machine *machinev1.Machine) error
 	scope, err := newMachineScope(machineScopeParams{
 		Context:                   ctx,
 		client:                    a.client,
+		eventRecorder:             a.eventRecorder,
 		machine:                   machine,
 		alibabacloudClientBuilder: a.alibabacloudClientBuilder,
 		configManagedClient:       a.configManagedClient,
@@ -154,6 +163,7 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1.Machine) error
 	scope, err := newMachineScope(machineScopeParams{
 		Context:                   ctx,
 		client:                    a.client,
+		eventRecorder:             a.eventRecorder,
 		machine:                   machine,
 		alibabacloudClientBuilder: a.alibabacloudClientBuilder,
 		configManagedClient:       a.configManagedClient,
@@ -167,6 +177,12 @@ func (a *Actuator) Delete(ctx context.Context, machine *machinev1.Machine) error
 		if err := scope.patchMachine(); err != nil {
 			return err
 		}
+		// Errors requesting a requeue are not terminal, let the machine controller retry
+		var requeueAfterError *machineapierrors.RequeueAfterError
+		if errors.As(err, &requeueAfterError) {
+			klog.Infof("%s: requeueing machine deletion: %v", machine.Name, err)
+			return requeueAfterError
+		}
 		return a.handleMachineError(machine, machineapierrors.InvalidMachineConfiguration("failed to reconcile machine %q: %v", machine.Name, err), deleteEventAction)
 	}
 
@@ -182,6 +198,7 @@ func (a *Actuator) Exists(ctx context.Context, machine *machinev1.Machine) (bool
 	scope, err := newMachineScope(machineScopeParams{
 		Context:                   ctx,
 		client:                    a.client,
+		eventRecorder:             a.eventRecorder,
 		machine:                   machine,
 		alibabacloudClientBuilder: a.alibabacloudClientBuilder,
 		configManagedClient:       a.configManagedClient,
@@ -22,6 +22,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 	"testing"
 
 	alibabacloudclient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
@@ -170,6 +172,9 @@ func TestMachine(t *testing.T) {
 				mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, stubStoppedInstanceStatus, "192.168.1.1"), nil).AnyTimes()
 				mockAlibabaCloudClient.EXPECT().StopInstances(gomock.Any()).Return(&ecs.StopInstancesResponse{}, nil).AnyTimes()
 				mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).Return(&ecs.DeleteInstancesResponse{}, nil).AnyTimes()
+				mockAlibabaCloudClient.EXPECT().DescribeLoadBalancers(gomock.Any()).Return(&slb.DescribeLoadBalancersResponse{}, nil).AnyTimes()
+				mockAlibabaCloudClient.EXPECT().DescribeEipAddresses(gomock.Any()).Return(&vpc.DescribeEipAddressesResponse{}, nil).AnyTimes()
+				mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Return(&ecs.DescribeNetworkInterfacesResponse{}, nil).AnyTimes()
 
 				return mockAlibabaCloudClient
 			},
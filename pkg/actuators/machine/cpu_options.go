@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+const (
+	// creditEntryLevelInstanceFamilyLevel is the instance family level of burstable instance types
+	creditEntryLevelInstanceFamilyLevel = "CreditEntryLevel"
+
+	// defaultThreadsPerCore is the number of threads per core assumed when the instance type does not report its physical cores
+	defaultThreadsPerCore = 2
+)
+
+// setCPUOptions sets the CPU options and credit specification of the instance on the RunInstances request,
+// after validating them against the instance type
+func setCPUOptions(request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) error {
+	cpuOptions := machineProviderConfig.CPUOptions
+	creditSpecification := machineProviderConfig.CreditSpecification
+
+	switch creditSpecification {
+	case "", alibabacloudproviderv1.StandardCreditSpecification, alibabacloudproviderv1.UnlimitedCreditSpecification:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid credit specification: %s. Allowed options are: %s,%s",
+			creditSpecification,
+			alibabacloudproviderv1.StandardCreditSpecification,
+			alibabacloudproviderv1.UnlimitedCreditSpecification)
+	}
+
+	if cpuOptions == nil && creditSpecification == "" {
+		return nil
+	}
+
+	if machineProviderConfig.InstanceType == "" {
+		// The instance type is taken from the launch template
+		return mapierrors.InvalidMachineConfiguration("cpuOptions and creditSpecification require the instance type to be set")
+	}
+
+	instanceType, err := describeInstanceType(client, machineProviderConfig.RegionID, machineProviderConfig.InstanceType)
+	if err != nil {
+		return err
+	}
+
+	if creditSpecification != "" {
+		if !isBurstableInstanceType(instanceType) {
+			return mapierrors.InvalidMachineConfiguration("credit specification can only be set for burstable instance types, %s is not burstable", machineProviderConfig.InstanceType)
+		}
+		request.CreditSpecification = string(creditSpecification)
+	}
+
+	if cpuOptions != nil {
+		if err := validateCPUOptions(cpuOptions, instanceType); err != nil {
+			return err
+		}
+		if cpuOptions.Core > 0 {
+			request.CpuOptionsCore = requests.NewInteger(int(cpuOptions.Core))
+		}
+		if cpuOptions.ThreadsPerCore > 0 {
+			request.CpuOptionsThreadsPerCore = requests.NewInteger(int(cpuOptions.ThreadsPerCore))
+		}
+		request.CpuOptionsNuma = cpuOptions.Numa
+	}
+
+	return nil
+}
+
+func validateCPUOptions(cpuOptions *alibabacloudproviderv1.CPUOptions, instanceType *ecs.InstanceType) error {
+	cores, threadsPerCore := getInstanceTypeTopology(instanceType)
+
+	if cpuOptions.Core < 0 || cpuOptions.Core > cores {
+		return mapierrors.InvalidMachineConfiguration("invalid cpu core count: %d. Instance type %s has %d cores", cpuOptions.Core, instanceType.InstanceTypeId, cores)
+	}
+
+	if cpuOptions.ThreadsPerCore < 0 || cpuOptions.ThreadsPerCore > threadsPerCore {
+		return mapierrors.InvalidMachineConfiguration("invalid threads per core: %d. Instance type %s has %d threads per core", cpuOptions.ThreadsPerCore, instanceType.InstanceTypeId, threadsPerCore)
+	}
+
+	return nil
+}
+
+// getInstanceTypeTopology returns the number of physical cores and threads per core of the instance type
+func getInstanceTypeTopology(instanceType *ecs.InstanceType) (int32, int32) {
+	vCPUs := int32(instanceType.CpuCoreCount)
+	cores := int32(instanceType.Cores)
+	if cores <= 0 || cores > vCPUs {
+		if vCPUs < defaultThreadsPerCore {
+			return vCPUs, 1
+		}
+		return vCPUs / defaultThreadsPerCore, defaultThreadsPerCore
+	}
+
+	return cores, vCPUs / cores
+}
+
+// EffectiveVCPUCount returns the number of vCPUs of an instance of the instance type created with the CPU options
+func EffectiveVCPUCount(instanceType *ecs.InstanceType, cpuOptions *alibabacloudproviderv1.CPUOptions) int64 {
+	if cpuOptions == nil || (cpuOptions.Core <= 0 && cpuOptions.ThreadsPerCore <= 0) {
+		return int64(instanceType.CpuCoreCount)
+	}
+
+	cores, threadsPerCore := getInstanceTypeTopology(instanceType)
+	if cpuOptions.Core > 0 && cpuOptions.Core < cores {
+		cores = cpuOptions.Core
+	}
+	if cpuOptions.ThreadsPerCore > 0 && cpuOptions.ThreadsPerCore < threadsPerCore {
+		threadsPerCore = cpuOptions.ThreadsPerCore
+	}
+
+	return int64(cores) * int64(threadsPerCore)
+}
+
+func isBurstableInstanceType(instanceType *ecs.InstanceType) bool {
+	return instanceType.InstanceFamilyLevel == creditEntryLevelInstanceFamilyLevel || instanceType.BaselineCredit > 0
+}
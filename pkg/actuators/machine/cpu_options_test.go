@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+const stubBurstableInstanceType = "ecs.t6-c1m2.large"
+
+func stubDescribeCPUInstanceTypesResponse() *ecs.DescribeInstanceTypesResponse {
+	return &ecs.DescribeInstanceTypesResponse{
+		InstanceTypes: ecs.InstanceTypesInDescribeInstanceTypes{
+			InstanceType: []ecs.InstanceType{
+				{InstanceTypeId: stubInstanceType, CpuCoreCount: 8, Cores: 4, MemorySize: 32},
+				{InstanceTypeId: stubBurstableInstanceType, CpuCoreCount: 2, MemorySize: 4, InstanceFamilyLevel: creditEntryLevelInstanceFamilyLevel},
+			},
+		},
+	}
+}
+
+func TestSetCPUOptions(t *testing.T) {
+	cases := []struct {
+		name                   string
+		instanceType           string
+		cpuOptions             *alibabacloudproviderv1.CPUOptions
+		creditSpecification    alibabacloudproviderv1.CreditSpecification
+		expectDescribe         bool
+		expectedCore           string
+		expectedThreadsPerCore string
+		expectedCredit         string
+		succeeds               bool
+	}{
+		{
+			name:     "No CPU options",
+			succeeds: true,
+		},
+		{
+			name:                   "Hyper-threading disabled",
+			cpuOptions:             &alibabacloudproviderv1.CPUOptions{Core: 2, ThreadsPerCore: 1},
+			expectDescribe:         true,
+			expectedCore:           "2",
+			expectedThreadsPerCore: "1",
+			succeeds:               true,
+		},
+		{
+			name:           "Too many cores",
+			cpuOptions:     &alibabacloudproviderv1.CPUOptions{Core: 8},
+			expectDescribe: true,
+		},
+		{
+			name:           "Too many threads per core",
+			cpuOptions:     &alibabacloudproviderv1.CPUOptions{ThreadsPerCore: 4},
+			expectDescribe: true,
+		},
+		{
+			name:                "Unlimited burstable instance",
+			instanceType:        stubBurstableInstanceType,
+			creditSpecification: alibabacloudproviderv1.UnlimitedCreditSpecification,
+			expectDescribe:      true,
+			expectedCredit:      "Unlimited",
+			succeeds:            true,
+		},
+		{
+			name:                "Credit specification for a regular instance type",
+			creditSpecification: alibabacloudproviderv1.StandardCreditSpecification,
+			expectDescribe:      true,
+		},
+		{
+			name:                "Invalid credit specification",
+			instanceType:        stubBurstableInstanceType,
+			creditSpecification: "Boost",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			describeCalls := 0
+			if tc.expectDescribe {
+				describeCalls = 1
+			}
+			mockAlibabaCloudClient.EXPECT().DescribeInstanceTypes(gomock.Any()).Return(stubDescribeCPUInstanceTypesResponse(), nil).Times(describeCalls)
+
+			providerConfig := stubProviderConfig()
+			if tc.instanceType != "" {
+				providerConfig.InstanceType = tc.instanceType
+			}
+			providerConfig.CPUOptions = tc.cpuOptions
+			providerConfig.CreditSpecification = tc.creditSpecification
+
+			request := ecs.CreateRunInstancesRequest()
+			err := setCPUOptions(request, providerConfig, mockAlibabaCloudClient)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			if tc.succeeds {
+				assert.Equal(t, tc.expectedCore, string(request.CpuOptionsCore))
+				assert.Equal(t, tc.expectedThreadsPerCore, string(request.CpuOptionsThreadsPerCore))
+				assert.Equal(t, tc.expectedCredit, request.CreditSpecification)
+			}
+		})
+	}
+}
+
+func TestEffectiveVCPUCount(t *testing.T) {
+	instanceType := &ecs.InstanceType{InstanceTypeId: stubInstanceType, CpuCoreCount: 8, Cores: 4}
+
+	cases := []struct {
+		name       string
+		cpuOptions *alibabacloudproviderv1.CPUOptions
+		expected   int64
+	}{
+		{
+			name:     "No CPU options",
+			expected: 8,
+		},
+		{
+			name:       "Hyper-threading disabled",
+			cpuOptions: &alibabacloudproviderv1.CPUOptions{ThreadsPerCore: 1},
+			expected:   4,
+		},
+		{
+			name:       "Fewer cores",
+			cpuOptions: &alibabacloudproviderv1.CPUOptions{Core: 2},
+			expected:   4,
+		},
+		{
+			name:       "Fewer cores with hyper-threading disabled",
+			cpuOptions: &alibabacloudproviderv1.CPUOptions{Core: 2, ThreadsPerCore: 1},
+			expected:   2,
+		},
+		{
+			name:       "NUMA only",
+			cpuOptions: &alibabacloudproviderv1.CPUOptions{Numa: "1"},
+			expected:   8,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, EffectiveVCPUCount(instanceType, tc.cpuOptions))
+		})
+	}
+
+	// Instance types which do not report their physical cores are assumed to have two threads per core
+	assert.Equal(t, int64(4), EffectiveVCPUCount(&ecs.InstanceType{CpuCoreCount: 8}, &alibabacloudproviderv1.CPUOptions{ThreadsPerCore: 1}))
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"k8s.io/klog"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// dedicatedHostStatusAvailable is the status of a dedicated host which can accept new instances
+	dedicatedHostStatusAvailable = "Available"
+)
+
+// setDedicatedHostPlacement resolves the dedicated host or dedicated host cluster referenced in the
+// provider spec and sets it on the RunInstances request. A dedicated host without enough capacity
+// for the instance type is reported as an invalid configuration, as retrying will not help.
+func setDedicatedHostPlacement(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, request *ecs.RunInstancesRequest, client alibabacloudClient.Client) error {
+	placement := machineProviderConfig.DedicatedHost
+	if placement == nil {
+		return nil
+	}
+
+	if machineProviderConfig.Tenancy != machinev1.HostTenancy {
+		return mapierrors.InvalidMachineConfiguration("dedicatedHost requires tenancy %s", machinev1.HostTenancy)
+	}
+
+	if (placement.Host == nil) == (placement.HostCluster == nil) {
+		return mapierrors.InvalidMachineConfiguration("exactly one of dedicatedHost host and hostCluster must be set")
+	}
+
+	instanceType, err := describeInstanceType(client, machineProviderConfig.RegionID, machineProviderConfig.InstanceType)
+	if err != nil {
+		return err
+	}
+
+	if placement.Host != nil {
+		hostID, err := getDedicatedHostID(machine, machineProviderConfig, instanceType, client)
+		if err != nil {
+			return err
+		}
+		request.DedicatedHostId = hostID
+		return nil
+	}
+
+	hostClusterID, err := getDedicatedHostClusterID(machine, machineProviderConfig, instanceType, client)
+	if err != nil {
+		return err
+	}
+	request.SchedulerOptionsDedicatedHostClusterId = hostClusterID
+	return nil
+}
+
+// getDedicatedHostID returns the ID of the first referenced dedicated host with enough capacity for the instance type
+func getDedicatedHostID(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, instanceType *ecs.InstanceType, client alibabacloudClient.Client) (string, error) {
+	reference := machineProviderConfig.DedicatedHost.Host
+
+	request := ecs.CreateDescribeDedicatedHostsRequest()
+	request.Scheme = "https"
+	request.RegionId = machineProviderConfig.RegionID
+	request.ZoneId = machineProviderConfig.ZoneID
+
+	switch reference.Type {
+	case machinev1.AlibabaResourceReferenceTypeID:
+		if reference.ID == nil || *reference.ID == "" {
+			return "", mapierrors.InvalidMachineConfiguration("No dedicated host ID provided")
+		}
+		ids, err := json.Marshal([]string{*reference.ID})
+		if err != nil {
+			return "", err
+		}
+		request.DedicatedHostIds = string(ids)
+	case machinev1.AlibabaResourceReferenceTypeTags:
+		if reference.Tags == nil || len(*reference.Tags) == 0 {
+			return "", mapierrors.InvalidMachineConfiguration("No tags provided for dedicated host search for machine: %q", machine.Name)
+		}
+		request.Tag = buildDescribeDedicatedHostsTag(*reference.Tags)
+	default:
+		return "", mapierrors.InvalidMachineConfiguration("Unknown dedicated host resource reference type: %s", reference.Type)
+	}
+
+	response, err := client.DescribeDedicatedHosts(request)
+	if err != nil {
+		klog.Errorf("error describing dedicated hosts: %v", err)
+		return "", fmt.Errorf("error describing dedicated hosts: %v", err)
+	}
+
+	if len(response.DedicatedHosts.DedicatedHost) < 1 {
+		return "", mapierrors.InvalidMachineConfiguration("no dedicated host found for machine %q in zone %s", machine.Name, machineProviderConfig.ZoneID)
+	}
+
+	for _, host := range response.DedicatedHosts.DedicatedHost {
+		if host.Status != dedicatedHostStatusAvailable {
+			klog.Infof("%s: skipping dedicated host %s in status %s", machine.Name, host.DedicatedHostId, host.Status)
+			continue
+		}
+		if host.Capacity.AvailableVcpus < instanceType.CpuCoreCount || host.Capacity.AvailableMemory < instanceType.MemorySize {
+			klog.Infof("%s: skipping dedicated host %s without capacity for instance type %s", machine.Name, host.DedicatedHostId, instanceType.InstanceTypeId)
+			continue
+		}
+		return host.DedicatedHostId, nil
+	}
+
+	return "", mapierrors.InvalidMachineConfiguration("no available dedicated host has capacity for instance type %s", instanceType.InstanceTypeId)
+}
+
+// getDedicatedHostClusterID returns the ID of the first referenced dedicated host cluster with capacity for the instance type
+func getDedicatedHostClusterID(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, instanceType *ecs.InstanceType, client alibabacloudClient.Client) (string, error) {
+	reference := machineProviderConfig.DedicatedHost.HostCluster
+
+	request := ecs.CreateDescribeDedicatedHostClustersRequest()
+	request.Scheme = "https"
+	request.RegionId = machineProviderConfig.RegionID
+	request.ZoneId = machineProviderConfig.ZoneID
+
+	switch reference.Type {
+	case machinev1.AlibabaResourceReferenceTypeID:
+		if reference.ID == nil || *reference.ID == "" {
+			return "", mapierrors.InvalidMachineConfiguration("No dedicated host cluster ID provided")
+		}
+		ids, err := json.Marshal([]string{*reference.ID})
+		if err != nil {
+			return "", err
+		}
+		request.DedicatedHostClusterIds = string(ids)
+	case machinev1.AlibabaResourceReferenceTypeTags:
+		if reference.Tags == nil || len(*reference.Tags) == 0 {
+			return "", mapierrors.InvalidMachineConfiguration("No tags provided for dedicated host cluster search for machine: %q", machine.Name)
+		}
+		request.Tag = buildDescribeDedicatedHostClustersTag(*reference.Tags)
+	default:
+		return "", mapierrors.InvalidMachineConfiguration("Unknown dedicated host cluster resource reference type: %s", reference.Type)
+	}
+
+	response, err := client.DescribeDedicatedHostClusters(request)
+	if err != nil {
+		klog.Errorf("error describing dedicated host clusters: %v", err)
+		return "", fmt.Errorf("error describing dedicated host clusters: %v", err)
+	}
+
+	if len(response.DedicatedHostClusters.DedicatedHostCluster) < 1 {
+		return "", mapierrors.InvalidMachineConfiguration("no dedicated host cluster found for machine %q in zone %s", machine.Name, machineProviderConfig.ZoneID)
+	}
+
+	for _, cluster := range response.DedicatedHostClusters.DedicatedHostCluster {
+		if dedicatedHostClusterHasCapacity(cluster.DedicatedHostClusterCapacity, instanceType) {
+			return cluster.DedicatedHostClusterId, nil
+		}
+		klog.Infof("%s: skipping dedicated host cluster %s without capacity for instance type %s", machine.Name, cluster.DedicatedHostClusterId, instanceType.InstanceTypeId)
+	}
+
+	return "", mapierrors.InvalidMachineConfiguration("no dedicated host cluster has capacity for instance type %s", instanceType.InstanceTypeId)
+}
+
+func dedicatedHostClusterHasCapacity(capacity ecs.DedicatedHostClusterCapacity, instanceType *ecs.InstanceType) bool {
+	// Prefer the per instance type capacity when the cluster reports it
+	if len(capacity.AvailableInstanceTypes.AvailableInstanceType) > 0 {
+		for _, available := range capacity.AvailableInstanceTypes.AvailableInstanceType {
+			if available.InstanceType == instanceType.InstanceTypeId {
+				return available.AvailableInstanceCapacity > 0
+			}
+		}
+		return false
+	}
+	return capacity.AvailableVcpus >= instanceType.CpuCoreCount && float64(capacity.AvailableMemory) >= instanceType.MemorySize
+}
+
+// isDedicatedHostCapacityError returns true if RunInstances failed because the dedicated host has not enough resources left
+func isDedicatedHostCapacityError(err error) bool {
+	var serverError *sdkerrors.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	code := serverError.ErrorCode()
+	if !strings.Contains(code, "DedicatedHost") {
+		return false
+	}
+	for _, reason := range []string{"NotEnough", "Insufficient", "NoStock", "Capacity"} {
+		if strings.Contains(code, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeInstanceType returns the specification of the given instance type
+func describeInstanceType(client alibabacloudClient.Client, regionID string, instanceType string) (*ecs.InstanceType, error) {
+	request := ecs.CreateDescribeInstanceTypesRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.InstanceTypes = &[]string{instanceType}
+
+	response, err := client.DescribeInstanceTypes(request)
+	if err != nil {
+		klog.Errorf("error describing instance type %s: %v", instanceType, err)
+		return nil, fmt.Errorf("error describing instance type %s: %v", instanceType, err)
+	}
+
+	for _, it := range response.InstanceTypes.InstanceType {
+		if it.InstanceTypeId == instanceType {
+			return &it, nil
+		}
+	}
+
+	return nil, mapierrors.InvalidMachineConfiguration("instance type %s not found", instanceType)
+}
+
+func buildDescribeDedicatedHostsTag(tags []machinev1.Tag) *[]ecs.DescribeDedicatedHostsTag {
+	rawTagList := removeDuplicatedMachineTags(tags)
+	describeDedicatedHostsTag := make([]ecs.DescribeDedicatedHostsTag, len(rawTagList))
+	for index, tag := range rawTagList {
+		describeDedicatedHostsTag[index] = ecs.DescribeDedicatedHostsTag{
+			Key:   tag.Key,
+			Value: tag.Value,
+		}
+	}
+
+	return &describeDedicatedHostsTag
+}
+
+func buildDescribeDedicatedHostClustersTag(tags []machinev1.Tag) *[]ecs.DescribeDedicatedHostClustersTag {
+	rawTagList := removeDuplicatedMachineTags(tags)
+	describeDedicatedHostClustersTag := make([]ecs.DescribeDedicatedHostClustersTag, len(rawTagList))
+	for index, tag := range rawTagList {
+		describeDedicatedHostClustersTag[index] = ecs.DescribeDedicatedHostClustersTag{
+			Key:   tag.Key,
+			Value: tag.Value,
+		}
+	}
+
+	return &describeDedicatedHostClustersTag
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	stubDedicatedHostID        = "dh-bp12k3v4ln7ck1d9vmp6"
+	stubDedicatedHostClusterID = "dc-bp12wlf6am0vz9v2bd1a"
+)
+
+func stubDescribeInstanceTypesResponse() *ecs.DescribeInstanceTypesResponse {
+	return &ecs.DescribeInstanceTypesResponse{
+		InstanceTypes: ecs.InstanceTypesInDescribeInstanceTypes{
+			InstanceType: []ecs.InstanceType{
+				{InstanceTypeId: stubInstanceType, CpuCoreCount: 8, MemorySize: 16},
+			},
+		},
+	}
+}
+
+func TestSetDedicatedHostPlacement(t *testing.T) {
+	hostID := stubDedicatedHostID
+	clusterID := stubDedicatedHostClusterID
+	tags := []machinev1.Tag{{Key: "dedicated", Value: "masters"}}
+
+	cases := []struct {
+		name                  string
+		tenancy               machinev1.InstanceTenancy
+		placement             *alibabacloudproviderv1.DedicatedHostPlacement
+		hostsResponse         *ecs.DescribeDedicatedHostsResponse
+		hostClustersResponse  *ecs.DescribeDedicatedHostClustersResponse
+		expectedHostID        string
+		expectedHostClusterID string
+		succeeds              bool
+	}{
+		{
+			name:     "No placement",
+			tenancy:  machinev1.HostTenancy,
+			succeeds: true,
+		},
+		{
+			name:    "Placement without host tenancy",
+			tenancy: machinev1.DefaultTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{
+				Host: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeID, ID: &hostID},
+			},
+		},
+		{
+			name:      "Neither host nor host cluster",
+			tenancy:   machinev1.HostTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{},
+		},
+		{
+			name:    "Host by ID",
+			tenancy: machinev1.HostTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{
+				Host: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeID, ID: &hostID},
+			},
+			hostsResponse: &ecs.DescribeDedicatedHostsResponse{
+				DedicatedHosts: ecs.DedicatedHosts{
+					DedicatedHost: []ecs.DedicatedHost{
+						{DedicatedHostId: stubDedicatedHostID, Status: "Available", Capacity: ecs.Capacity{AvailableVcpus: 8, AvailableMemory: 16}},
+					},
+				},
+			},
+			expectedHostID: stubDedicatedHostID,
+			succeeds:       true,
+		},
+		{
+			name:    "Host by tags skips hosts without capacity",
+			tenancy: machinev1.HostTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{
+				Host: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeTags, Tags: &tags},
+			},
+			hostsResponse: &ecs.DescribeDedicatedHostsResponse{
+				DedicatedHosts: ecs.DedicatedHosts{
+					DedicatedHost: []ecs.DedicatedHost{
+						{DedicatedHostId: "dh-full", Status: "Available", Capacity: ecs.Capacity{AvailableVcpus: 4, AvailableMemory: 64}},
+						{DedicatedHostId: "dh-locked", Status: "UnderAssessment", Capacity: ecs.Capacity{AvailableVcpus: 64, AvailableMemory: 64}},
+						{DedicatedHostId: stubDedicatedHostID, Status: "Available", Capacity: ecs.Capacity{AvailableVcpus: 64, AvailableMemory: 64}},
+					},
+				},
+			},
+			expectedHostID: stubDedicatedHostID,
+			succeeds:       true,
+		},
+		{
+			name:    "Host without capacity",
+			tenancy: machinev1.HostTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{
+				Host: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeID, ID: &hostID},
+			},
+			hostsResponse: &ecs.DescribeDedicatedHostsResponse{
+				DedicatedHosts: ecs.DedicatedHosts{
+					DedicatedHost: []ecs.DedicatedHost{
+						{DedicatedHostId: stubDedicatedHostID, Status: "Available", Capacity: ecs.Capacity{AvailableVcpus: 8, AvailableMemory: 8}},
+					},
+				},
+			},
+		},
+		{
+			name:    "Host cluster by ID",
+			tenancy: machinev1.HostTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{
+				HostCluster: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeID, ID: &clusterID},
+			},
+			hostClustersResponse: &ecs.DescribeDedicatedHostClustersResponse{
+				DedicatedHostClusters: ecs.DedicatedHostClusters{
+					DedicatedHostCluster: []ecs.DedicatedHostCluster{
+						{
+							DedicatedHostClusterId: stubDedicatedHostClusterID,
+							DedicatedHostClusterCapacity: ecs.DedicatedHostClusterCapacity{
+								AvailableInstanceTypes: ecs.AvailableInstanceTypesInDescribeDedicatedHostClusters{
+									AvailableInstanceType: []ecs.AvailableInstanceType{{InstanceType: stubInstanceType, AvailableInstanceCapacity: 2}},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedHostClusterID: stubDedicatedHostClusterID,
+			succeeds:              true,
+		},
+		{
+			name:    "Host cluster without capacity",
+			tenancy: machinev1.HostTenancy,
+			placement: &alibabacloudproviderv1.DedicatedHostPlacement{
+				HostCluster: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeTags, Tags: &tags},
+			},
+			hostClustersResponse: &ecs.DescribeDedicatedHostClustersResponse{
+				DedicatedHostClusters: ecs.DedicatedHostClusters{
+					DedicatedHostCluster: []ecs.DedicatedHostCluster{
+						{
+							DedicatedHostClusterId: stubDedicatedHostClusterID,
+							DedicatedHostClusterCapacity: ecs.DedicatedHostClusterCapacity{
+								AvailableVcpus:  4,
+								AvailableMemory: 8,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+
+			providerConfig := stubProviderConfig()
+			providerConfig.Tenancy = tc.tenancy
+			providerConfig.DedicatedHost = tc.placement
+
+			if tc.hostsResponse != nil || tc.hostClustersResponse != nil {
+				mockAlibabaCloudClient.EXPECT().DescribeInstanceTypes(gomock.Any()).Return(stubDescribeInstanceTypesResponse(), nil).Times(1)
+			}
+			if tc.hostsResponse != nil {
+				mockAlibabaCloudClient.EXPECT().DescribeDedicatedHosts(gomock.Any()).Return(tc.hostsResponse, nil).Times(1)
+			}
+			if tc.hostClustersResponse != nil {
+				mockAlibabaCloudClient.EXPECT().DescribeDedicatedHostClusters(gomock.Any()).Return(tc.hostClustersResponse, nil).Times(1)
+			}
+
+			request := ecs.CreateRunInstancesRequest()
+			machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+			err := setDedicatedHostPlacement(machineKey, providerConfig, request, mockAlibabaCloudClient)
+			if !tc.succeeds {
+				// Placement errors are terminal
+				machineErr, ok := err.(*machinecontroller.MachineError)
+				if assert.True(t, ok, "expected a machine error, got %v", err) {
+					assert.Equal(t, machinev1beta1.InvalidConfigurationMachineError, machineErr.Reason)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedHostID, request.DedicatedHostId)
+			assert.Equal(t, tc.expectedHostClusterID, request.SchedulerOptionsDedicatedHostClusterId)
+		})
+	}
+}
+
+func TestIsDedicatedHostCapacityError(t *testing.T) {
+	capacityErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "DedicatedHost.InsufficientCapacity"}`, "")
+	otherErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "OperationDenied.NoStock"}`, "")
+
+	assert.True(t, isDedicatedHostCapacityError(capacityErr))
+	assert.True(t, isDedicatedHostCapacityError(fmt.Errorf("wrapped: %w", capacityErr)))
+	assert.False(t, isDedicatedHostCapacityError(otherErr))
+	assert.False(t, isDedicatedHostCapacityError(fmt.Errorf("error")))
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// defaultStopTimeout is how long a graceful stop may take before the instance is force stopped
+	defaultStopTimeout = 5 * time.Minute
+	// defaultForceStopTimeout is how long a force stop may take before the instance is released with force
+	defaultForceStopTimeout = 5 * time.Minute
+
+	// deletePolicyEscalatedEventReason is the reason of the event recorded when the instance does not stop in time
+	deletePolicyEscalatedEventReason = "DeletePolicyEscalated"
+)
+
+// validateDeletePolicy checks the delete policy of the provider spec
+func validateDeletePolicy(deletePolicy *alibabacloudproviderv1.DeletePolicy) error {
+	if deletePolicy == nil {
+		return nil
+	}
+
+	switch deletePolicy.Mode {
+	case "", alibabacloudproviderv1.GracefulDeleteMode, alibabacloudproviderv1.ForceStopDeleteMode, alibabacloudproviderv1.ForceDeleteMode:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid delete mode %s. Valid values are %s, %s and %s", deletePolicy.Mode,
+			alibabacloudproviderv1.GracefulDeleteMode, alibabacloudproviderv1.ForceStopDeleteMode, alibabacloudproviderv1.ForceDeleteMode)
+	}
+
+	switch deletePolicy.StoppedMode {
+	case "", alibabacloudproviderv1.KeepChargingStoppedMode, alibabacloudproviderv1.StopChargingStoppedMode:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid stopped mode %s. Valid values are %s and %s", deletePolicy.StoppedMode,
+			alibabacloudproviderv1.KeepChargingStoppedMode, alibabacloudproviderv1.StopChargingStoppedMode)
+	}
+
+	if deletePolicy.StopTimeout != nil && deletePolicy.StopTimeout.Duration <= 0 {
+		return mapierrors.InvalidMachineConfiguration("invalid stop timeout %v: must be positive", deletePolicy.StopTimeout.Duration)
+	}
+
+	if deletePolicy.ForceStopTimeout != nil && deletePolicy.ForceStopTimeout.Duration <= 0 {
+		return mapierrors.InvalidMachineConfiguration("invalid force stop timeout %v: must be positive", deletePolicy.ForceStopTimeout.Duration)
+	}
+
+	return nil
+}
+
+// getDeleteMode returns the delete mode of the delete policy, Graceful by default
+func getDeleteMode(deletePolicy *alibabacloudproviderv1.DeletePolicy) alibabacloudproviderv1.DeleteMode {
+	if deletePolicy == nil || deletePolicy.Mode == "" {
+		return alibabacloudproviderv1.GracefulDeleteMode
+	}
+	return deletePolicy.Mode
+}
+
+// getStoppedMode returns the stopped mode of the delete policy, empty to keep the ECS default
+func getStoppedMode(deletePolicy *alibabacloudproviderv1.DeletePolicy) alibabacloudproviderv1.StoppedMode {
+	if deletePolicy == nil {
+		return ""
+	}
+	return deletePolicy.StoppedMode
+}
+
+// getStopTimeout returns how long a graceful stop may take before the instance is force stopped
+func getStopTimeout(deletePolicy *alibabacloudproviderv1.DeletePolicy) time.Duration {
+	if deletePolicy == nil || deletePolicy.StopTimeout == nil {
+		return defaultStopTimeout
+	}
+	return deletePolicy.StopTimeout.Duration
+}
+
+// getForceStopTimeout returns how long a force stop may take before the instance is released with force
+func getForceStopTimeout(deletePolicy *alibabacloudproviderv1.DeletePolicy) time.Duration {
+	if deletePolicy == nil || deletePolicy.ForceStopTimeout == nil {
+		return defaultForceStopTimeout
+	}
+	return deletePolicy.ForceStopTimeout.Duration
+}
+
+// stopInstancesForDeletion stops the instances as required by the delete policy, escalating to a force stop
+// when a graceful stop times out. It returns true when a force stop timed out as well and the instances
+// have to be released with force, otherwise an error requeueing the machine until the instances are Stopped.
+func (r *Reconciler) stopInstancesForDeletion(instances []*ecs.Instance) (bool, error) {
+	deletePolicy := r.providerSpec.DeletePolicy
+	operation := r.instanceOperation()
+	stoppingInstances := getRunningFromInstances(instances)
+
+	switch {
+	case operation == alibabacloudproviderv1.ForceStopInstanceOperation && r.instanceOperationDuration() > getForceStopTimeout(deletePolicy):
+		klog.Warningf("%s: instances were not force stopped within %v, releasing them with force", r.machine.Name, getForceStopTimeout(deletePolicy))
+		r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, deletePolicyEscalatedEventReason,
+			"Instances were not force stopped within %v, releasing them with force", getForceStopTimeout(deletePolicy))
+		return true, nil
+	case operation == alibabacloudproviderv1.StopInstanceOperation && r.instanceOperationDuration() > getStopTimeout(deletePolicy):
+		klog.Warningf("%s: instances were not stopped within %v, force stopping them", r.machine.Name, getStopTimeout(deletePolicy))
+		r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, deletePolicyEscalatedEventReason,
+			"Instances were not stopped within %v, force stopping them", getStopTimeout(deletePolicy))
+		r.startInstanceOperation(alibabacloudproviderv1.ForceStopInstanceOperation)
+		// Instances hanging in Stopping are force stopped too
+		stoppingInstances = instances
+	case operation != alibabacloudproviderv1.StopInstanceOperation && operation != alibabacloudproviderv1.ForceStopInstanceOperation:
+		if getDeleteMode(deletePolicy) == alibabacloudproviderv1.ForceStopDeleteMode {
+			r.startInstanceOperation(alibabacloudproviderv1.ForceStopInstanceOperation)
+		} else {
+			r.startInstanceOperation(alibabacloudproviderv1.StopInstanceOperation)
+		}
+	}
+
+	forceStop := r.instanceOperation() == alibabacloudproviderv1.ForceStopInstanceOperation
+	if err := r.requestStopInstances(stoppingInstances, forceStop); err != nil {
+		return false, err
+	}
+
+	return false, r.requeueInstanceOperation()
+}
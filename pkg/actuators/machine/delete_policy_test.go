@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestValidateDeletePolicy(t *testing.T) {
+	cases := []struct {
+		name         string
+		deletePolicy *alibabacloudproviderv1.DeletePolicy
+		succeeds     bool
+	}{
+		{
+			name:     "No delete policy",
+			succeeds: true,
+		},
+		{
+			name: "Force stop with timeouts",
+			deletePolicy: &alibabacloudproviderv1.DeletePolicy{
+				Mode:             alibabacloudproviderv1.ForceStopDeleteMode,
+				StoppedMode:      alibabacloudproviderv1.StopChargingStoppedMode,
+				StopTimeout:      &metav1.Duration{Duration: time.Minute},
+				ForceStopTimeout: &metav1.Duration{Duration: time.Minute},
+			},
+			succeeds: true,
+		},
+		{
+			name:         "Invalid mode",
+			deletePolicy: &alibabacloudproviderv1.DeletePolicy{Mode: "Terminate"},
+		},
+		{
+			name:         "Invalid stopped mode",
+			deletePolicy: &alibabacloudproviderv1.DeletePolicy{StoppedMode: "Hibernate"},
+		},
+		{
+			name:         "Invalid stop timeout",
+			deletePolicy: &alibabacloudproviderv1.DeletePolicy{StopTimeout: &metav1.Duration{}},
+		},
+		{
+			name:         "Invalid force stop timeout",
+			deletePolicy: &alibabacloudproviderv1.DeletePolicy{ForceStopTimeout: &metav1.Duration{Duration: -time.Minute}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDeletePolicy(tc.deletePolicy)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestStopInstancesForDeletion(t *testing.T) {
+	cases := []struct {
+		name              string
+		mode              alibabacloudproviderv1.DeleteMode
+		operation         alibabacloudproviderv1.InstanceOperation
+		operationDuration time.Duration
+		instanceStatus    string
+		expectStop        int
+		expectForceStop   bool
+		expectForce       bool
+		expectedEvents    int
+		expectedOperation alibabacloudproviderv1.InstanceOperation
+	}{
+		{
+			name:              "Graceful stop",
+			instanceStatus:    ECSInstanceStatusRunning,
+			expectStop:        1,
+			expectedOperation: alibabacloudproviderv1.StopInstanceOperation,
+		},
+		{
+			name:              "Force stop",
+			mode:              alibabacloudproviderv1.ForceStopDeleteMode,
+			instanceStatus:    ECSInstanceStatusRunning,
+			expectStop:        1,
+			expectForceStop:   true,
+			expectedOperation: alibabacloudproviderv1.ForceStopInstanceOperation,
+		},
+		{
+			name:              "Graceful stop in progress",
+			operation:         alibabacloudproviderv1.StopInstanceOperation,
+			operationDuration: time.Minute,
+			instanceStatus:    ECSInstanceStatusStopping,
+			expectedOperation: alibabacloudproviderv1.StopInstanceOperation,
+		},
+		{
+			name:              "Graceful stop timed out",
+			operation:         alibabacloudproviderv1.StopInstanceOperation,
+			operationDuration: time.Hour,
+			instanceStatus:    ECSInstanceStatusStopping,
+			expectStop:        1,
+			expectForceStop:   true,
+			expectedEvents:    1,
+			expectedOperation: alibabacloudproviderv1.ForceStopInstanceOperation,
+		},
+		{
+			name:              "Force stop timed out",
+			operation:         alibabacloudproviderv1.ForceStopInstanceOperation,
+			operationDuration: time.Hour,
+			instanceStatus:    ECSInstanceStatusStopping,
+			expectForce:       true,
+			expectedEvents:    1,
+			expectedOperation: alibabacloudproviderv1.ForceStopInstanceOperation,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, tc.instanceStatus, "192.168.1.0"), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().StopInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.StopInstancesRequest) (*ecs.StopInstancesResponse, error) {
+					assert.Equal(t, []string{stubInstanceID}, *request.InstanceId)
+					assert.Equal(t, tc.expectForceStop, request.ForceStop == "true")
+					assert.Equal(t, string(alibabacloudproviderv1.StopChargingStoppedMode), request.StoppedMode)
+					return &ecs.StopInstancesResponse{}, nil
+				}).Times(tc.expectStop)
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			providerConfig := stubProviderConfig()
+			providerConfig.DeletePolicy = &alibabacloudproviderv1.DeletePolicy{
+				Mode:        tc.mode,
+				StoppedMode: alibabacloudproviderv1.StopChargingStoppedMode,
+			}
+
+			providerStatus := &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{}
+			if tc.operation != "" {
+				startTime := metav1.NewTime(time.Now().Add(-tc.operationDuration))
+				providerStatus.Operation = &tc.operation
+				providerStatus.OperationStartTime = &startTime
+			}
+
+			eventRecorder := record.NewFakeRecorder(10)
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      eventRecorder,
+				machine:            machine,
+				providerSpec:       providerConfig,
+				providerStatus:     providerStatus,
+			})
+
+			instances := stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, tc.instanceStatus, "192.168.1.0").Instances.Instance
+			forceRelease, err := r.stopInstancesForDeletion([]*ecs.Instance{&instances[0]})
+			assert.Equal(t, tc.expectForce, forceRelease)
+			if tc.expectForce {
+				assert.NoError(t, err)
+			} else {
+				_, requeue := err.(*machinecontroller.RequeueAfterError)
+				assert.True(t, requeue, "unexpected error: %v", err)
+			}
+			assert.Equal(t, tc.expectedOperation, r.instanceOperation())
+			assert.Len(t, eventRecorder.Events, tc.expectedEvents)
+		})
+	}
+}
+
+func TestDeleteMachineForce(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusRunning, "192.168.1.0"), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().StopInstances(gomock.Any()).Times(0)
+	mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Times(0)
+	mockAlibabaCloudClient.EXPECT().DescribeLoadBalancers(gomock.Any()).Return(&slb.DescribeLoadBalancersResponse{}, nil).Times(1)
+	mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).DoAndReturn(
+		func(request *ecs.DeleteInstancesRequest) (*ecs.DeleteInstancesResponse, error) {
+			assert.Equal(t, []string{stubInstanceID}, *request.InstanceId)
+			assert.Equal(t, "true", string(request.Force))
+			return &ecs.DeleteInstancesResponse{}, nil
+		}).Times(1)
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providerConfig := stubProviderConfig()
+	providerConfig.DeletePolicy = &alibabacloudproviderv1.DeletePolicy{Mode: alibabacloudproviderv1.ForceDeleteMode}
+
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		eventRecorder:      record.NewFakeRecorder(10),
+		machine:            machine,
+		providerSpec:       providerConfig,
+		providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	assert.NoError(t, r.DeleteMachine(context.TODO()))
+	assert.Equal(t, alibabacloudproviderv1.ReleaseInstanceOperation, r.instanceOperation())
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// deletionProtectionEnabledEventReason is the reason of the event recorded when deletion protection of an instance is turned on
+	deletionProtectionEnabledEventReason = "DeletionProtectionEnabled"
+	// deletionProtectionDisabledEventReason is the reason of the event recorded when deletion protection of an instance is turned off
+	deletionProtectionDisabledEventReason = "DeletionProtectionDisabled"
+)
+
+// setDeletionProtection sets the deletion protection of the instance on the RunInstances request
+func setDeletionProtection(request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	switch machineProviderConfig.DeletionProtection {
+	case "", alibabacloudproviderv1.DeletionProtectionDisabled:
+		return nil
+	case alibabacloudproviderv1.DeletionProtectionEnabled:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid deletion protection: %s. Allowed options are: %s,%s",
+			machineProviderConfig.DeletionProtection,
+			alibabacloudproviderv1.DeletionProtectionEnabled,
+			alibabacloudproviderv1.DeletionProtectionDisabled)
+	}
+
+	if spot := machineProviderConfig.SpotMarketOptions; spot != nil && spot.Strategy != alibabacloudproviderv1.NoSpotStrategy {
+		return mapierrors.InvalidMachineConfiguration("deletion protection cannot be enabled for spot instances")
+	}
+
+	request.DeletionProtection = requests.NewBoolean(true)
+	return nil
+}
+
+// recordDeletionProtectionEnabled records an event when the instance was created with deletion protection
+func (r *Reconciler) recordDeletionProtectionEnabled(instance *ecs.Instance) {
+	if instance == nil || !instance.DeletionProtection {
+		return
+	}
+
+	r.eventRecorder.Eventf(r.machine, corev1.EventTypeNormal, deletionProtectionEnabledEventReason,
+		"Enabled deletion protection of instance %s", instance.InstanceId)
+}
+
+// disableDeletionProtection turns off deletion protection of the instances, so that they can be released.
+// Protection is only turned off when the Machine itself is being deleted.
+func (r *Reconciler) disableDeletionProtection(instances []*ecs.Instance) error {
+	for _, instance := range instances {
+		if !instance.DeletionProtection {
+			continue
+		}
+
+		if r.machine.DeletionTimestamp == nil {
+			return fmt.Errorf("instance %s has deletion protection, which is only turned off when machine %s is deleted", instance.InstanceId, r.machine.Name)
+		}
+
+		if err := r.disableInstanceDeletionProtection(instance); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disableInstanceDeletionProtection turns off the deletion protection of the instance
+func (r *Reconciler) disableInstanceDeletionProtection(instance *ecs.Instance) error {
+	request := ecs.CreateModifyInstanceAttributeRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.InstanceId = instance.InstanceId
+	request.DeletionProtection = requests.NewBoolean(false)
+
+	if _, err := r.alibabacloudClient.ModifyInstanceAttribute(request); err != nil {
+		klog.Errorf("%s: failed to disable deletion protection of instance %s: %v", r.machine.Name, instance.InstanceId, err)
+		return fmt.Errorf("failed to disable deletion protection of instance %s: %v", instance.InstanceId, err)
+	}
+
+	klog.Infof("%s: disabled deletion protection of instance %s", r.machine.Name, instance.InstanceId)
+	r.eventRecorder.Eventf(r.machine, corev1.EventTypeNormal, deletionProtectionDisabledEventReason,
+		"Disabled deletion protection of instance %s", instance.InstanceId)
+
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestSetDeletionProtection(t *testing.T) {
+	cases := []struct {
+		name               string
+		deletionProtection alibabacloudproviderv1.DeletionProtectionMode
+		spotMarketOptions  *alibabacloudproviderv1.SpotMarketOptions
+		expected           string
+		succeeds           bool
+	}{
+		{
+			name:     "Default",
+			succeeds: true,
+		},
+		{
+			name:               "Enabled",
+			deletionProtection: alibabacloudproviderv1.DeletionProtectionEnabled,
+			expected:           "true",
+			succeeds:           true,
+		},
+		{
+			name:               "Disabled",
+			deletionProtection: alibabacloudproviderv1.DeletionProtectionDisabled,
+			succeeds:           true,
+		},
+		{
+			name:               "Enabled for a spot instance",
+			deletionProtection: alibabacloudproviderv1.DeletionProtectionEnabled,
+			spotMarketOptions:  &alibabacloudproviderv1.SpotMarketOptions{},
+		},
+		{
+			name:               "Invalid",
+			deletionProtection: "On",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerConfig := stubProviderConfig()
+			providerConfig.DeletionProtection = tc.deletionProtection
+			providerConfig.SpotMarketOptions = tc.spotMarketOptions
+
+			request := ecs.CreateRunInstancesRequest()
+			err := setDeletionProtection(request, providerConfig)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			assert.Equal(t, tc.expected, string(request.DeletionProtection))
+		})
+	}
+}
+
+func TestDisableDeletionProtection(t *testing.T) {
+	cases := []struct {
+		name           string
+		deleting       bool
+		instances      []*ecs.Instance
+		modifyErr      error
+		expectModify   int
+		expectedEvents int
+		expectError    bool
+	}{
+		{
+			name:     "Machine deleted",
+			deleting: true,
+			instances: []*ecs.Instance{
+				{InstanceId: stubInstanceID, DeletionProtection: true},
+				{InstanceId: "i-unprotected"},
+			},
+			expectModify:   1,
+			expectedEvents: 1,
+		},
+		{
+			name:        "Machine not deleted",
+			instances:   []*ecs.Instance{{InstanceId: stubInstanceID, DeletionProtection: true}},
+			expectError: true,
+		},
+		{
+			name:         "Modify instance attribute fails",
+			deleting:     true,
+			instances:    []*ecs.Instance{{InstanceId: stubInstanceID, DeletionProtection: true}},
+			modifyErr:    fmt.Errorf("error"),
+			expectModify: 1,
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().ModifyInstanceAttribute(gomock.Any()).DoAndReturn(
+				func(request *ecs.ModifyInstanceAttributeRequest) (*ecs.ModifyInstanceAttributeResponse, error) {
+					assert.Equal(t, stubInstanceID, request.InstanceId)
+					assert.Equal(t, "false", string(request.DeletionProtection))
+					return &ecs.ModifyInstanceAttributeResponse{}, tc.modifyErr
+				}).Times(tc.expectModify)
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.deleting {
+				now := metav1.Now()
+				machine.DeletionTimestamp = &now
+			}
+
+			eventRecorder := record.NewFakeRecorder(10)
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      eventRecorder,
+				machine:            machine,
+				providerSpec:       stubProviderConfig(),
+				providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+			})
+
+			err = r.disableDeletionProtection(tc.instances)
+			assert.Equal(t, tc.expectError, err != nil, "unexpected error: %v", err)
+			assert.Len(t, eventRecorder.Events, tc.expectedEvents)
+		})
+	}
+}
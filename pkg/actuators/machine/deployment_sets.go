@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// ECSTagResourceTypeDeploymentSet deployment set tag resource type
+	ECSTagResourceTypeDeploymentSet = "deploymentset"
+
+	// machineSetKind is the kind of the controller owning the machines of a MachineSet
+	machineSetKind = "MachineSet"
+)
+
+// MachineSetDeploymentSetName returns the name of the deployment set created for a MachineSet
+func MachineSetDeploymentSetName(clusterID, machineSetName string) string {
+	return fmt.Sprintf("%s-%s", clusterID, machineSetName)
+}
+
+// MachineSetDeploymentSetTags returns the tags of the deployment set created for a MachineSet
+func MachineSetDeploymentSetTags(clusterID, machineSetName string) *[]ecs.TagResourcesTag {
+	return tagResourceTags(clusterID, MachineSetDeploymentSetName(clusterID, machineSetName))
+}
+
+// machineSetDeploymentSetTagFilter returns the ownership tags the deployment set created for a MachineSet is looked up by
+func machineSetDeploymentSetTagFilter(clusterID, machineSetName string) []machinev1.Tag {
+	tags := make([]machinev1.Tag, 0)
+	for _, tag := range *MachineSetDeploymentSetTags(clusterID, machineSetName) {
+		tags = append(tags, machinev1.Tag{Key: tag.Key, Value: tag.Value})
+	}
+	return tags
+}
+
+// GetMachineSetDeploymentSetID returns the ID of the deployment set created for a MachineSet, looked up by its ownership tags.
+// It returns an empty string if no deployment set carries the tags.
+func GetMachineSetDeploymentSetID(client alibabacloudClient.Client, regionID string, clusterID, machineSetName string) (string, error) {
+	deploymentSetIDs, err := listDeploymentSetIDsByTags(client, regionID, machineSetDeploymentSetTagFilter(clusterID, machineSetName))
+	if err != nil {
+		return "", err
+	}
+
+	switch len(deploymentSetIDs) {
+	case 0:
+		return "", nil
+	case 1:
+		return deploymentSetIDs[0], nil
+	default:
+		return "", fmt.Errorf("multiple deployment sets %v found for machineset %s", deploymentSetIDs, machineSetName)
+	}
+}
+
+// GetDeploymentSetByID returns the deployment set with the given ID, or nil if it does not exist
+func GetDeploymentSetByID(client alibabacloudClient.Client, regionID string, id string) (*ecs.DeploymentSet, error) {
+	request := ecs.CreateDescribeDeploymentSetsRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.DeploymentSetIds = fmt.Sprintf("[%q]", id)
+
+	response, err := client.DescribeDeploymentSets(request)
+	if err != nil {
+		return nil, fmt.Errorf("error describing deployment sets: %v", err)
+	}
+
+	for _, deploymentSet := range response.DeploymentSets.DeploymentSet {
+		if deploymentSet.DeploymentSetId == id {
+			return &deploymentSet, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetDeploymentSetByName returns the deployment set with the given name, or nil if it does not exist
+func GetDeploymentSetByName(client alibabacloudClient.Client, regionID string, name string) (*ecs.DeploymentSet, error) {
+	request := ecs.CreateDescribeDeploymentSetsRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.DeploymentSetName = name
+
+	response, err := client.DescribeDeploymentSets(request)
+	if err != nil {
+		return nil, fmt.Errorf("error describing deployment sets: %v", err)
+	}
+
+	// DeploymentSetName is a fuzzy match
+	for _, deploymentSet := range response.DeploymentSets.DeploymentSet {
+		if deploymentSet.DeploymentSetName == name {
+			return &deploymentSet, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// getDeploymentSetID returns the ID of the deployment set referenced in the provider spec
+func getDeploymentSetID(machine *machinev1beta1.Machine, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+	deploymentSet := machineProviderConfig.DeploymentSet
+
+	switch deploymentSet.Type {
+	case alibabacloudproviderv1.DeploymentSetReferenceTypeID:
+		if deploymentSet.ID == nil || *deploymentSet.ID == "" {
+			return "", mapierrors.InvalidMachineConfiguration("No deployment set ID provided")
+		}
+		return *deploymentSet.ID, nil
+	case alibabacloudproviderv1.DeploymentSetReferenceTypeTags:
+		return getDeploymentSetIDByTags(machine, machineProviderConfig.RegionID, deploymentSet.Tags, client)
+	case alibabacloudproviderv1.DeploymentSetReferenceTypeMachineSet:
+		owner := metav1.GetControllerOf(machine)
+		if owner == nil || owner.Kind != machineSetKind {
+			return "", mapierrors.InvalidMachineConfiguration("deployment set type %s requires machine %q to be owned by a MachineSet", deploymentSet.Type, machine.Name)
+		}
+		clusterID, ok := getClusterID(machine)
+		if !ok {
+			return "", mapierrors.InvalidMachineConfiguration("Unable to get cluster ID for machine: %q", machine.Name)
+		}
+
+		id, err := GetMachineSetDeploymentSetID(client, machineProviderConfig.RegionID, clusterID, owner.Name)
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			// The MachineSet controller has not created and tagged the deployment set yet
+			klog.Infof("%s: deployment set %s for machineset %s not found, requeueing", machine.Name, MachineSetDeploymentSetName(clusterID, owner.Name), owner.Name)
+			return "", &mapierrors.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+		}
+		return id, nil
+	default:
+		return "", mapierrors.InvalidMachineConfiguration("Unknown deployment set reference type: %s", deploymentSet.Type)
+	}
+}
+
+func getDeploymentSetIDByTags(machine *machinev1beta1.Machine, regionID string, tags *[]machinev1.Tag, client alibabacloudClient.Client) (string, error) {
+	if tags == nil || len(*tags) == 0 {
+		return "", mapierrors.InvalidMachineConfiguration("No tags provided for deployment set ID search for machine: %q", machine.Name)
+	}
+
+	deploymentSetIDs, err := listDeploymentSetIDsByTags(client, regionID, *tags)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(deploymentSetIDs) {
+	case 0:
+		klog.Errorf("no deployment set for given tags found")
+		return "", fmt.Errorf("no deployment set for given tags found")
+	case 1:
+		return deploymentSetIDs[0], nil
+	default:
+		return "", mapierrors.InvalidMachineConfiguration("multiple deployment sets %v found for given tags", deploymentSetIDs)
+	}
+}
+
+// listDeploymentSetIDsByTags returns the IDs of the deployment sets carrying all the given tags
+func listDeploymentSetIDsByTags(client alibabacloudClient.Client, regionID string, tags []machinev1.Tag) ([]string, error) {
+	request := ecs.CreateListTagResourcesRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.ResourceType = ECSTagResourceTypeDeploymentSet
+	request.Tag = buildListTagResourcesTag(tags)
+
+	deploymentSetIDs := make([]string, 0)
+	for {
+		response, err := client.ListTagResources(request)
+		if err != nil {
+			klog.Errorf("error listing deployment sets by tags: %v", err)
+			return nil, fmt.Errorf("error listing deployment sets by tags: %v", err)
+		}
+
+		// Every tag of a matching deployment set is listed separately
+		for _, tagResource := range response.TagResources.TagResource {
+			if !containsString(deploymentSetIDs, tagResource.ResourceId) {
+				deploymentSetIDs = append(deploymentSetIDs, tagResource.ResourceId)
+			}
+		}
+
+		if response.NextToken == "" {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+
+	return deploymentSetIDs, nil
+}
+
+func buildListTagResourcesTag(tags []machinev1.Tag) *[]ecs.ListTagResourcesTag {
+	rawTagList := removeDuplicatedMachineTags(tags)
+	listTagResourcesTag := make([]ecs.ListTagResourcesTag, len(rawTagList))
+	for index, tag := range rawTagList {
+		listTagResourcesTag[index] = ecs.ListTagResourcesTag{
+			Key:   tag.Key,
+			Value: tag.Value,
+		}
+	}
+
+	return &listTagResourcesTag
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	stubDeploymentSetID  = "ds-bp13v7bjnj9gisnlo6ow"
+	stubMachineSetName   = "alibabacloud-actuator-testing-machineset"
+	stubDeploymentSetTag = "deployment-set"
+)
+
+func TestGetDeploymentSetID(t *testing.T) {
+	deploymentSetID := stubDeploymentSetID
+	emptyID := ""
+	tags := []machinev1.Tag{{Key: stubDeploymentSetTag, Value: "masters"}}
+
+	cases := []struct {
+		name                     string
+		deploymentSet            *alibabacloudproviderv1.DeploymentSetReference
+		ownedByMachineSet        bool
+		listTagResourcesResponse *ecs.ListTagResourcesResponse
+		expectedID               string
+		expectInvalidConfig      bool
+		expectRequeue            bool
+		succeeds                 bool
+	}{
+		{
+			name: "By ID",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeID,
+				ID:   &deploymentSetID,
+			},
+			expectedID: stubDeploymentSetID,
+			succeeds:   true,
+		},
+		{
+			name: "Empty ID",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeID,
+				ID:   &emptyID,
+			},
+			expectInvalidConfig: true,
+		},
+		{
+			name: "By tags",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeTags,
+				Tags: &tags,
+			},
+			listTagResourcesResponse: &ecs.ListTagResourcesResponse{
+				TagResources: ecs.TagResources{
+					TagResource: []ecs.TagResource{
+						{ResourceId: stubDeploymentSetID, TagKey: stubDeploymentSetTag, TagValue: "masters"},
+						{ResourceId: stubDeploymentSetID, TagKey: "other", TagValue: "tag"},
+					},
+				},
+			},
+			expectedID: stubDeploymentSetID,
+			succeeds:   true,
+		},
+		{
+			name: "By tags matching nothing",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeTags,
+				Tags: &tags,
+			},
+			listTagResourcesResponse: &ecs.ListTagResourcesResponse{},
+		},
+		{
+			name: "By tags matching multiple deployment sets",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeTags,
+				Tags: &tags,
+			},
+			listTagResourcesResponse: &ecs.ListTagResourcesResponse{
+				TagResources: ecs.TagResources{
+					TagResource: []ecs.TagResource{
+						{ResourceId: stubDeploymentSetID},
+						{ResourceId: "ds-other"},
+					},
+				},
+			},
+			expectInvalidConfig: true,
+		},
+		{
+			name: "Owned by MachineSet",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeMachineSet,
+			},
+			ownedByMachineSet: true,
+			listTagResourcesResponse: &ecs.ListTagResourcesResponse{
+				TagResources: ecs.TagResources{
+					TagResource: []ecs.TagResource{
+						{ResourceId: stubDeploymentSetID, TagKey: "Name", TagValue: MachineSetDeploymentSetName(stubClusterID, stubMachineSetName)},
+					},
+				},
+			},
+			expectedID: stubDeploymentSetID,
+			succeeds:   true,
+		},
+		{
+			name: "Owned by MachineSet not created yet",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeMachineSet,
+			},
+			ownedByMachineSet:        true,
+			listTagResourcesResponse: &ecs.ListTagResourcesResponse{},
+			expectRequeue:            true,
+		},
+		{
+			name: "Machine without MachineSet",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: alibabacloudproviderv1.DeploymentSetReferenceTypeMachineSet,
+			},
+			expectInvalidConfig: true,
+		},
+		{
+			name: "Unknown type",
+			deploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+				Type: "Name",
+			},
+			expectInvalidConfig: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(stubWorkerMachineName, nil)
+			if err != nil {
+				t.Fatalf("Unable to build test machine manifest: %v", err)
+			}
+			if tc.ownedByMachineSet {
+				isController := true
+				machine.OwnerReferences = []metav1.OwnerReference{
+					{Kind: "MachineSet", Name: stubMachineSetName, Controller: &isController},
+				}
+			}
+
+			providerConfig := stubProviderConfig()
+			providerConfig.DeploymentSet = tc.deploymentSet
+
+			if tc.listTagResourcesResponse != nil {
+				mockAlibabaCloudClient.EXPECT().ListTagResources(gomock.Any()).DoAndReturn(
+					func(request *ecs.ListTagResourcesRequest) (*ecs.ListTagResourcesResponse, error) {
+						assert.Equal(t, ECSTagResourceTypeDeploymentSet, request.ResourceType)
+						if tc.ownedByMachineSet {
+							assert.Contains(t, *request.Tag, ecs.ListTagResourcesTag{Key: "Name", Value: MachineSetDeploymentSetName(stubClusterID, stubMachineSetName)})
+						} else {
+							assert.Equal(t, &[]ecs.ListTagResourcesTag{{Key: stubDeploymentSetTag, Value: "masters"}}, request.Tag)
+						}
+						return tc.listTagResourcesResponse, nil
+					}).Times(1)
+			}
+
+			id, err := getDeploymentSetID(machine, providerConfig, mockAlibabaCloudClient)
+			if tc.succeeds {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedID, id)
+				return
+			}
+			assert.Error(t, err)
+			machineErr, ok := err.(*machinecontroller.MachineError)
+			assert.Equal(t, tc.expectInvalidConfig, ok && machineErr.Reason == machinev1beta1.InvalidConfigurationMachineError)
+			_, requeue := err.(*machinecontroller.RequeueAfterError)
+			assert.Equal(t, tc.expectRequeue, requeue)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// duplicateInstanceEventReason is the reason of the events recorded while a duplicate instance of the machine is stopped and released
+const duplicateInstanceEventReason = "DuplicateInstance"
+
+// releaseDuplicateInstances stops and releases the instances found for the machine besides the instance backing it.
+// Running duplicates are stopped, they are released by a later reconcile once they are Stopped.
+func (r *Reconciler) releaseDuplicateInstances(instance *ecs.Instance) error {
+	existingInstances, err := getExistingInstances(r.machine, r.providerSpec.RegionID, r.alibabacloudClient)
+	if err != nil {
+		return fmt.Errorf("failed to get existing instances: %w", err)
+	}
+
+	duplicates := 0
+	runningDuplicates := make([]*ecs.Instance, 0)
+	stoppedDuplicates := make([]*ecs.Instance, 0)
+	for _, duplicate := range existingInstances {
+		if duplicate.InstanceId == instance.InstanceId {
+			continue
+		}
+
+		// Instances tagged for another machine with the same name are not duplicates
+		if verifyInstanceOwnership(r.machine, duplicate) != nil {
+			continue
+		}
+		duplicates++
+
+		// Only the instances tagged with the UID of the machine are known to be launched for it,
+		// untagged instances may belong to a machine with the same name in another namespace
+		if !hasMachineUIDTag(r.machine, duplicate) {
+			klog.Warningf("%s: not releasing duplicate instance %s not tagged with machine UID %s", r.machine.Name, duplicate.InstanceId, r.machine.UID)
+			r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, instanceOwnershipMismatchEventReason, "Not releasing duplicate instance %s not tagged with machine UID %s", duplicate.InstanceId, r.machine.UID)
+			continue
+		}
+
+		if duplicate.DeletionProtection {
+			klog.Warningf("%s: not releasing duplicate instance %s with deletion protection", r.machine.Name, duplicate.InstanceId)
+			r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, duplicateInstanceEventReason, "Not releasing duplicate instance %s with deletion protection", duplicate.InstanceId)
+			continue
+		}
+
+		switch duplicate.Status {
+		case ECSInstanceStatusRunning:
+			runningDuplicates = append(runningDuplicates, duplicate)
+		case ECSInstanceStatusStopped:
+			stoppedDuplicates = append(stoppedDuplicates, duplicate)
+		}
+	}
+
+	// All the duplicates found are reported, including the ones which are kept
+	registerDuplicateInstances(r.machine.Namespace, r.machine.Name, duplicates)
+
+	if len(runningDuplicates) > 0 {
+		if _, err := stopInstances(r.alibabacloudClient, r.providerSpec.RegionID, runningDuplicates, false, getStoppedMode(r.providerSpec.DeletePolicy)); err != nil {
+			return fmt.Errorf("failed to stop duplicate instances: %w", err)
+		}
+
+		for _, duplicate := range runningDuplicates {
+			r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, duplicateInstanceEventReason, "Stopping duplicate instance %s", duplicate.InstanceId)
+		}
+	}
+
+	if len(stoppedDuplicates) == 0 {
+		return nil
+	}
+
+	// subscription instances can not be deleted, convert them to pay-as-you-go first
+	if err := convertSubscriptionInstances(r.alibabacloudClient, r.providerSpec.RegionID, stoppedDuplicates); err != nil {
+		return err
+	}
+
+	duplicateIDs := make([]string, 0, len(stoppedDuplicates))
+	for _, duplicate := range stoppedDuplicates {
+		duplicateIDs = append(duplicateIDs, duplicate.InstanceId)
+	}
+
+	request := ecs.CreateDeleteInstancesRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.InstanceId = &duplicateIDs
+
+	if _, err := r.alibabacloudClient.DeleteInstances(request); err != nil {
+		return fmt.Errorf("failed to release duplicate instances %v: %w", duplicateIDs, err)
+	}
+
+	klog.Infof("%s: released duplicate instances %v", r.machine.Name, duplicateIDs)
+	registerReleasedDuplicateInstances(r.machine.Namespace, len(duplicateIDs))
+	for _, duplicateID := range duplicateIDs {
+		r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, duplicateInstanceEventReason, "Released duplicate instance %s", duplicateID)
+	}
+
+	return nil
+}
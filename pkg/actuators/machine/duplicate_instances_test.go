@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+const stubDuplicateInstanceID = "i-bp1duplicate0instance"
+
+var stubMachineUIDTag = ecs.Tag{TagKey: machineUIDTagKey, TagValue: stubMachineUID}
+
+func stubMachineInstance(instanceID, status string, tags ...ecs.Tag) ecs.Instance {
+	return ecs.Instance{
+		InstanceId:         instanceID,
+		Status:             status,
+		InstanceChargeType: string(alibabacloudproviderv1.PostPaidInstanceChargeType),
+		Tags: ecs.TagsInDescribeInstances{
+			Tag: append([]ecs.Tag{
+				{TagKey: clusterFilterKeyPrefix + stubClusterID, TagValue: clusterFilterValue},
+				{TagKey: clusterFilterName, TagValue: stubMasterMachineName},
+			}, tags...),
+		},
+	}
+}
+
+func TestReleaseDuplicateInstances(t *testing.T) {
+	cases := []struct {
+		name               string
+		duplicates         []ecs.Instance
+		expectedStopped    []string
+		expectedReleased   []string
+		expectedEvents     int
+		expectedDuplicates int
+	}{
+		{
+			name: "No duplicate instance",
+		},
+		{
+			name:               "Running duplicate instance is stopped",
+			duplicates:         []ecs.Instance{stubMachineInstance(stubDuplicateInstanceID, ECSInstanceStatusRunning, stubMachineUIDTag)},
+			expectedStopped:    []string{stubDuplicateInstanceID},
+			expectedEvents:     1,
+			expectedDuplicates: 1,
+		},
+		{
+			name:               "Stopped duplicate instance is released",
+			duplicates:         []ecs.Instance{stubMachineInstance(stubDuplicateInstanceID, ECSInstanceStatusStopped, stubMachineUIDTag)},
+			expectedReleased:   []string{stubDuplicateInstanceID},
+			expectedEvents:     1,
+			expectedDuplicates: 1,
+		},
+		{
+			name:               "Stopping duplicate instance is left until it is Stopped",
+			duplicates:         []ecs.Instance{stubMachineInstance(stubDuplicateInstanceID, ECSInstanceStatusStopping, stubMachineUIDTag)},
+			expectedDuplicates: 1,
+		},
+		{
+			name:               "Duplicate instance without the machine UID tag is kept",
+			duplicates:         []ecs.Instance{stubMachineInstance(stubDuplicateInstanceID, ECSInstanceStatusStopped)},
+			expectedEvents:     1,
+			expectedDuplicates: 1,
+		},
+		{
+			name: "Instance owned by another machine is not a duplicate",
+			duplicates: []ecs.Instance{stubMachineInstance(stubDuplicateInstanceID, ECSInstanceStatusStopped,
+				ecs.Tag{TagKey: machineUIDTagKey, TagValue: "0b8e6f4c-1d2a-4e3b-8c5d-7a9f0e1b2c3d"})},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine.UID = stubMachineUID
+
+			instance := stubMachineInstance(stubInstanceID, ECSInstanceStatusRunning)
+			instances := append([]ecs.Instance{instance}, tc.duplicates...)
+
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.DescribeInstancesRequest) (*ecs.DescribeInstancesResponse, error) {
+					response := &ecs.DescribeInstancesResponse{}
+					if request.InstanceIds == "" {
+						response.Instances.Instance = instances
+						return response, nil
+					}
+
+					var instanceIDs []string
+					if err := json.Unmarshal([]byte(request.InstanceIds), &instanceIDs); err != nil {
+						return nil, err
+					}
+					for _, i := range instances {
+						for _, instanceID := range instanceIDs {
+							if i.InstanceId == instanceID {
+								response.Instances.Instance = append(response.Instances.Instance, i)
+							}
+						}
+					}
+					return response, nil
+				}).AnyTimes()
+
+			stopped := make([]string, 0)
+			mockAlibabaCloudClient.EXPECT().StopInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.StopInstancesRequest) (*ecs.StopInstancesResponse, error) {
+					stopped = append(stopped, *request.InstanceId...)
+					return &ecs.StopInstancesResponse{}, nil
+				}).AnyTimes()
+
+			released := make([]string, 0)
+			mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.DeleteInstancesRequest) (*ecs.DeleteInstancesResponse, error) {
+					released = append(released, *request.InstanceId...)
+					return &ecs.DeleteInstancesResponse{}, nil
+				}).AnyTimes()
+
+			eventRecorder := record.NewFakeRecorder(10)
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      eventRecorder,
+				machine:            machine,
+				providerSpec:       stubProviderConfig(),
+				providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+			})
+
+			assert.NoError(t, r.releaseDuplicateInstances(&instance))
+			assert.ElementsMatch(t, tc.expectedStopped, stopped)
+			assert.ElementsMatch(t, tc.expectedReleased, released)
+			assert.Len(t, eventRecorder.Events, tc.expectedEvents)
+
+			metric := &dto.Metric{}
+			if assert.NoError(t, duplicateInstances.With(prometheus.Labels{"namespace": machine.Namespace, "name": machine.Name}).Write(metric)) {
+				assert.Equal(t, float64(tc.expectedDuplicates), metric.GetGauge().GetValue())
+			}
+		})
+	}
+}
@@ -31,6 +31,8 @@ const (
 	machineTagKeyFrom           = "sigs.k8s.io/cloud-provider-alibaba/origin"
 	machineTagValueFrom         = "ocp"
 	machineIsvIntegrationTagKey = "GISV"
+	machineNamespaceTagKey      = "machine.openshift.io/machine-namespace"
+	machineUIDTagKey            = "machine.openshift.io/machine-uid"
 )
 
 func clusterTagFilter(clusterID, machineName string) []ecs.DescribeInstancesTag {
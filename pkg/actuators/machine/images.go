@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"k8s.io/klog"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// describeImagesPageSize is the maximum page size of DescribeImages
+	describeImagesPageSize = 100
+)
+
+// resolveImageID returns the ID of the image the instance should be created from.
+// A literal ImageID takes precedence over the image selector. An empty ID is returned
+// when neither is set and the image is taken from the launch template.
+func resolveImageID(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+	if machineProviderConfig.ImageID != "" {
+		return machineProviderConfig.ImageID, nil
+	}
+
+	selector := machineProviderConfig.Image
+	if selector == nil {
+		if machineProviderConfig.LaunchTemplate != nil {
+			return "", nil
+		}
+		return "", mapierrors.InvalidMachineConfiguration("no image configured for machine %q: one of imageId and image must be set", machine.Name)
+	}
+
+	if imageID, ok := selector.RegionImageIDs[machineProviderConfig.RegionID]; ok && imageID != "" {
+		return imageID, nil
+	}
+
+	if selector.Family == "" && selector.Name == "" && (selector.Tags == nil || len(*selector.Tags) == 0) {
+		return "", mapierrors.InvalidMachineConfiguration("image selector of machine %q has no image for region %s and no family, name or tags", machine.Name, machineProviderConfig.RegionID)
+	}
+
+	return getNewestImageID(machine, machineProviderConfig.RegionID, selector, client)
+}
+
+// getNewestImageID returns the ID of the newest Available image matching the image selector
+func getNewestImageID(machine runtimeclient.ObjectKey, regionID string, selector *alibabacloudproviderv1.ImageSelector, client alibabacloudClient.Client) (string, error) {
+	request := ecs.CreateDescribeImagesRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.Status = EcsImageStatusAvailable
+	request.ImageFamily = selector.Family
+	request.ImageName = selector.Name
+	request.ImageOwnerAlias = selector.OwnerAlias
+	if selector.Tags != nil && len(*selector.Tags) > 0 {
+		request.Tag = buildDescribeImagesTag(*selector.Tags)
+	}
+	request.PageSize = requests.NewInteger(describeImagesPageSize)
+
+	var newest *ecs.Image
+	for page := 1; ; page++ {
+		request.PageNumber = requests.NewInteger(page)
+
+		response, err := client.DescribeImages(request)
+		if err != nil {
+			klog.Errorf("error describing images: %v", err)
+			return "", fmt.Errorf("error describing images: %v", err)
+		}
+
+		for i, image := range response.Images.Image {
+			// ImageName is a fuzzy match
+			if selector.Name != "" && image.ImageName != selector.Name {
+				continue
+			}
+			if image.Status != EcsImageStatusAvailable {
+				continue
+			}
+			// CreationTime is formatted as yyyy-MM-ddTHH:mm:ssZ, so it can be compared as a string
+			if newest == nil || image.CreationTime > newest.CreationTime {
+				newest = &response.Images.Image[i]
+			}
+		}
+
+		if len(response.Images.Image) < describeImagesPageSize || page*describeImagesPageSize >= response.TotalCount {
+			break
+		}
+	}
+
+	if newest == nil {
+		return "", mapierrors.InvalidMachineConfiguration("no available image found for machine %q in region %s matching family %q, name %q and tags %v",
+			machine.Name, regionID, selector.Family, selector.Name, selector.Tags)
+	}
+
+	klog.Infof("%s: resolved image %s (%s) created at %s", machine.Name, newest.ImageId, newest.ImageName, newest.CreationTime)
+	return newest.ImageId, nil
+}
+
+// pinImage resolves the image of the instance and records it in the provider status.
+// Once recorded, the same image is used by all later attempts to create the instance.
+func (r *Reconciler) pinImage(providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	machineKey := runtimeclient.ObjectKey{Name: r.machine.Name, Namespace: r.machine.Namespace}
+
+	if providerSpec.ImageID == "" && r.providerStatus.ImageID != nil && *r.providerStatus.ImageID != "" {
+		klog.Infof("%s: using pinned image %s", r.machine.Name, *r.providerStatus.ImageID)
+		providerSpec.ImageID = *r.providerStatus.ImageID
+		return nil
+	}
+
+	imageID, err := resolveImageID(machineKey, providerSpec, r.alibabacloudClient)
+	if err != nil {
+		return err
+	}
+	if imageID == "" {
+		return nil
+	}
+
+	providerSpec.ImageID = imageID
+	r.providerStatus.ImageID = &imageID
+
+	return nil
+}
+
+func buildDescribeImagesTag(tags []machinev1.Tag) *[]ecs.DescribeImagesTag {
+	rawTagList := removeDuplicatedMachineTags(tags)
+	describeImagesTag := make([]ecs.DescribeImagesTag, len(rawTagList))
+	for index, tag := range rawTagList {
+		describeImagesTag[index] = ecs.DescribeImagesTag{
+			Key:   tag.Key,
+			Value: tag.Value,
+		}
+	}
+
+	return &describeImagesTag
+}
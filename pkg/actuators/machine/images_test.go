@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	stubImageFamily     = "rhcos-4.9"
+	stubOldImageID      = "m-bp1old0rhcos49"
+	stubNewImageID      = "m-bp1new0rhcos49"
+	stubRegionalImageID = "m-bp1regional0rhcos"
+)
+
+func stubFamilyDescribeImagesResponse() *ecs.DescribeImagesResponse {
+	return &ecs.DescribeImagesResponse{
+		TotalCount: 3,
+		Images: ecs.Images{
+			Image: []ecs.Image{
+				{ImageId: stubOldImageID, ImageName: "rhcos-49.84", ImageFamily: stubImageFamily, Status: EcsImageStatusAvailable, CreationTime: "2021-09-01T02:00:00Z"},
+				{ImageId: stubNewImageID, ImageName: "rhcos-49.84-new", ImageFamily: stubImageFamily, Status: EcsImageStatusAvailable, CreationTime: "2021-10-01T02:00:00Z"},
+				{ImageId: "m-bp1creating", ImageName: "rhcos-49.84-creating", ImageFamily: stubImageFamily, Status: "Creating", CreationTime: "2021-11-01T02:00:00Z"},
+			},
+		},
+	}
+}
+
+func TestResolveImageID(t *testing.T) {
+	tags := []machinev1.Tag{{Key: "os", Value: "rhcos"}}
+
+	cases := []struct {
+		name            string
+		imageID         string
+		image           *alibabacloudproviderv1.ImageSelector
+		launchTemplate  *alibabacloudproviderv1.LaunchTemplateReference
+		imagesResponse  *ecs.DescribeImagesResponse
+		expectedImageID string
+		expectError     bool
+	}{
+		{
+			name:            "Literal image ID",
+			imageID:         stubImageID,
+			image:           &alibabacloudproviderv1.ImageSelector{Family: stubImageFamily},
+			expectedImageID: stubImageID,
+		},
+		{
+			name: "Regional image ID",
+			image: &alibabacloudproviderv1.ImageSelector{
+				Family:         stubImageFamily,
+				RegionImageIDs: map[string]string{stubRegionID: stubRegionalImageID, "cn-hangzhou": "m-other"},
+			},
+			expectedImageID: stubRegionalImageID,
+		},
+		{
+			name:            "Newest image of the family",
+			image:           &alibabacloudproviderv1.ImageSelector{Family: stubImageFamily, RegionImageIDs: map[string]string{"cn-hangzhou": "m-other"}},
+			imagesResponse:  stubFamilyDescribeImagesResponse(),
+			expectedImageID: stubNewImageID,
+		},
+		{
+			name:            "Exact image name",
+			image:           &alibabacloudproviderv1.ImageSelector{Name: "rhcos-49.84"},
+			imagesResponse:  stubFamilyDescribeImagesResponse(),
+			expectedImageID: stubOldImageID,
+		},
+		{
+			name:            "Image tags",
+			image:           &alibabacloudproviderv1.ImageSelector{Tags: &tags},
+			imagesResponse:  stubFamilyDescribeImagesResponse(),
+			expectedImageID: stubNewImageID,
+		},
+		{
+			name:           "No matching image",
+			image:          &alibabacloudproviderv1.ImageSelector{Family: stubImageFamily},
+			imagesResponse: &ecs.DescribeImagesResponse{},
+			expectError:    true,
+		},
+		{
+			name:        "Empty image selector",
+			image:       &alibabacloudproviderv1.ImageSelector{},
+			expectError: true,
+		},
+		{
+			name:        "No image",
+			expectError: true,
+		},
+		{
+			name:           "Image from the launch template",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			if tc.imagesResponse != nil {
+				mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(tc.imagesResponse, nil).Times(1)
+			}
+
+			providerConfig := stubProviderConfig()
+			providerConfig.ImageID = tc.imageID
+			providerConfig.Image = tc.image
+			providerConfig.LaunchTemplate = tc.launchTemplate
+
+			machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+			imageID, err := resolveImageID(machineKey, providerConfig, mockAlibabaCloudClient)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedImageID, imageID)
+		})
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	cases := []struct {
+		name            string
+		pinnedImageID   *string
+		expectResolve   bool
+		expectedImageID string
+	}{
+		{
+			name:            "Resolve and pin the image",
+			expectResolve:   true,
+			expectedImageID: stubNewImageID,
+		},
+		{
+			name:            "Use the pinned image",
+			pinnedImageID:   pointer.StringPtr(stubOldImageID),
+			expectedImageID: stubOldImageID,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			if tc.expectResolve {
+				mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubFamilyDescribeImagesResponse(), nil).Times(1)
+			}
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			providerSpec := stubProviderConfig()
+			providerSpec.ImageID = ""
+			providerSpec.Image = &alibabacloudproviderv1.ImageSelector{Family: stubImageFamily}
+
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				machine:            machine,
+				providerSpec:       providerSpec,
+				providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{ImageID: tc.pinnedImageID},
+			})
+
+			pinnedSpec := providerSpec.DeepCopy()
+			assert.NoError(t, r.pinImage(pinnedSpec))
+			assert.Equal(t, tc.expectedImageID, pinnedSpec.ImageID)
+			if assert.NotNil(t, r.providerStatus.ImageID) {
+				assert.Equal(t, tc.expectedImageID, *r.providerStatus.ImageID)
+			}
+			// The Machine's own provider spec is left untouched
+			assert.Empty(t, r.providerSpec.ImageID)
+		})
+	}
+}
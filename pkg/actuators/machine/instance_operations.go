@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// instanceOperation returns the operation in progress on the instance, or an empty string when there is none
+func (r *Reconciler) instanceOperation() alibabacloudproviderv1.InstanceOperation {
+	if r.providerStatus.Operation == nil {
+		return ""
+	}
+	return *r.providerStatus.Operation
+}
+
+// startInstanceOperation records the operation in progress on the instance in the provider status.
+// The start time is kept when the operation is already in progress.
+func (r *Reconciler) startInstanceOperation(operation alibabacloudproviderv1.InstanceOperation) {
+	if r.instanceOperation() == operation {
+		return
+	}
+
+	klog.Infof("%s: starting instance operation %s", r.machine.Name, operation)
+	now := metav1.Now()
+	r.providerStatus.Operation = &operation
+	r.providerStatus.OperationStartTime = &now
+}
+
+// completeInstanceOperation clears the operation in progress on the instance from the provider status
+func (r *Reconciler) completeInstanceOperation() {
+	if r.providerStatus.Operation != nil {
+		klog.Infof("%s: completed instance operation %s", r.machine.Name, *r.providerStatus.Operation)
+	}
+	r.providerStatus.Operation = nil
+	r.providerStatus.OperationStartTime = nil
+}
+
+// instanceOperationDuration returns for how long the operation in progress has been running
+func (r *Reconciler) instanceOperationDuration() time.Duration {
+	if r.providerStatus.OperationStartTime == nil {
+		return 0
+	}
+	return time.Since(r.providerStatus.OperationStartTime.Time)
+}
+
+// requeueInstanceOperation returns the error requeueing the machine until the operation in progress completes
+func (r *Reconciler) requeueInstanceOperation() error {
+	klog.Infof("%s: instance operation %s in progress, returning an error to requeue", r.machine.Name, r.instanceOperation())
+	return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+}
+
+// isInstanceLaunching returns true while the instance has not finished booting after it was launched
+func isInstanceLaunching(instance *ecs.Instance) bool {
+	return instance.Status == ECSInstanceStatusPending || instance.Status == ECSInstanceStatusStarting
+}
+
+// completeLaunch records the launch of the instance once it is no longer booting, or once it was restarted
+func (r *Reconciler) completeLaunch(instance *ecs.Instance) {
+	operation := r.instanceOperation()
+	if operation != alibabacloudproviderv1.LaunchInstanceOperation && operation != alibabacloudproviderv1.RestartInstanceOperation {
+		return
+	}
+
+	if instance.Status == ECSInstanceStatusRunning {
+		registerInstanceLaunch(r.machine.Namespace, getCapacityType(instance))
+	}
+	r.recordDeletionProtectionEnabled(instance)
+	r.completeInstanceOperation()
+}
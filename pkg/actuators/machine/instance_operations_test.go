@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudclient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubectl/pkg/scheme"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestStartInstanceOperation(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReconciler(&machineScope{
+		Context:        context.Background(),
+		machine:        machine,
+		providerSpec:   stubProviderConfig(),
+		providerStatus: &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	r.startInstanceOperation(alibabacloudproviderv1.StopInstanceOperation)
+	assert.Equal(t, alibabacloudproviderv1.StopInstanceOperation, r.instanceOperation())
+	startTime := r.providerStatus.OperationStartTime
+	if assert.NotNil(t, startTime) {
+		// The start time is kept while the operation is in progress
+		r.startInstanceOperation(alibabacloudproviderv1.StopInstanceOperation)
+		assert.Same(t, startTime, r.providerStatus.OperationStartTime)
+	}
+
+	r.completeInstanceOperation()
+	assert.Empty(t, r.instanceOperation())
+	assert.Nil(t, r.providerStatus.OperationStartTime)
+}
+
+func TestCreateLaunchOperation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubDescribeImagesResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().ListResourceGroups(gomock.Any()).Return(stubListResourceGroupsResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DescribeSecurityGroups(gomock.Any()).Return(stubDescribeSecurityGroupsResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).Return(stubDescribeVSwitchesResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).Return(stubRunInstancesResponse(), nil).Times(1)
+	mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusPending, "192.168.1.0"), nil).AnyTimes()
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, machine, stubAlibabaCloudCredentialsSecret(), stubUserDataSecret())
+	machineScope, err := newMachineScope(machineScopeParams{
+		client:  fakeClient,
+		machine: machine,
+		alibabacloudClientBuilder: func(client runtimeclient.Client, secretName, namespace, region string, configManagedClient runtimeclient.Client) (alibabacloudclient.Client, error) {
+			return mockAlibabaCloudClient, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReconciler(machineScope)
+
+	// The machine is requeued until the instance is Running
+	err = r.Create(context.TODO())
+	_, requeue := err.(*machinecontroller.RequeueAfterError)
+	assert.True(t, requeue, "unexpected error: %v", err)
+	assert.Equal(t, alibabacloudproviderv1.LaunchInstanceOperation, r.instanceOperation())
+	if assert.NotNil(t, r.providerStatus.InstanceID) {
+		assert.Equal(t, stubInstanceID, *r.providerStatus.InstanceID)
+	}
+
+	r.completeLaunch(&ecs.Instance{InstanceId: stubInstanceID, Status: ECSInstanceStatusRunning})
+	assert.Empty(t, r.instanceOperation())
+}
+
+func TestDeleteMachineInstanceOperation(t *testing.T) {
+	cases := []struct {
+		name              string
+		operation         alibabacloudproviderv1.InstanceOperation
+		instanceStatus    string
+		noInstance        bool
+		expectStop        int
+		expectDelete      int
+		expectRequeue     bool
+		expectedOperation alibabacloudproviderv1.InstanceOperation
+	}{
+		{
+			name:              "Running instance is stopped",
+			instanceStatus:    ECSInstanceStatusRunning,
+			expectStop:        1,
+			expectRequeue:     true,
+			expectedOperation: alibabacloudproviderv1.StopInstanceOperation,
+		},
+		{
+			name:              "Stopping instance is waited for",
+			operation:         alibabacloudproviderv1.StopInstanceOperation,
+			instanceStatus:    ECSInstanceStatusStopping,
+			expectRequeue:     true,
+			expectedOperation: alibabacloudproviderv1.StopInstanceOperation,
+		},
+		{
+			name:              "Stopped instance is released",
+			operation:         alibabacloudproviderv1.StopInstanceOperation,
+			instanceStatus:    ECSInstanceStatusStopped,
+			expectDelete:      1,
+			expectedOperation: alibabacloudproviderv1.ReleaseInstanceOperation,
+		},
+		{
+			name:              "Released instance is waited for",
+			operation:         alibabacloudproviderv1.ReleaseInstanceOperation,
+			instanceStatus:    ECSInstanceStatusStopped,
+			expectRequeue:     true,
+			expectedOperation: alibabacloudproviderv1.ReleaseInstanceOperation,
+		},
+		{
+			name:       "Instance is gone",
+			operation:  alibabacloudproviderv1.ReleaseInstanceOperation,
+			noInstance: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			describeInstancesResponse := stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, tc.instanceStatus, "192.168.1.0")
+			if tc.noInstance {
+				describeInstancesResponse = &ecs.DescribeInstancesResponse{}
+			}
+
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(describeInstancesResponse, nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Return(&ecs.DescribeNetworkInterfacesResponse{}, nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().StopInstances(gomock.Any()).Return(&ecs.StopInstancesResponse{}, nil).Times(tc.expectStop)
+			mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).Return(&ecs.DeleteInstancesResponse{}, nil).Times(tc.expectDelete)
+			mockAlibabaCloudClient.EXPECT().DescribeLoadBalancers(gomock.Any()).Return(&slb.DescribeLoadBalancersResponse{}, nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeEipAddresses(gomock.Any()).Return(&vpc.DescribeEipAddressesResponse{}, nil).AnyTimes()
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			providerStatus := &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{}
+			if tc.operation != "" {
+				providerStatus.Operation = &tc.operation
+			}
+
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      record.NewFakeRecorder(10),
+				machine:            machine,
+				providerSpec:       stubProviderConfig(),
+				providerStatus:     providerStatus,
+			})
+
+			err = r.DeleteMachine(context.TODO())
+			_, requeue := err.(*machinecontroller.RequeueAfterError)
+			assert.Equal(t, tc.expectRequeue, requeue, "unexpected error: %v", err)
+			if !tc.expectRequeue {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedOperation, r.instanceOperation())
+		})
+	}
+}
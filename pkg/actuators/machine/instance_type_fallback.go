@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"errors"
+	"strings"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// instanceTypeFallbackEventReason is the reason of the event recorded when the instance is created with a fallback instance type
+const instanceTypeFallbackEventReason = "InstanceTypeFallback"
+
+// validateFallbackInstanceTypes checks the fallback instance types can be tried after the instance type of the provider spec
+func validateFallbackInstanceTypes(machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	if len(machineProviderConfig.FallbackInstanceTypes) == 0 {
+		return nil
+	}
+
+	if machineProviderConfig.InstanceType == "" {
+		return mapierrors.InvalidMachineConfiguration("fallbackInstanceTypes require the instance type to be set")
+	}
+
+	seen := map[string]bool{machineProviderConfig.InstanceType: true}
+	for _, instanceType := range machineProviderConfig.FallbackInstanceTypes {
+		if instanceType == "" {
+			return mapierrors.InvalidMachineConfiguration("invalid fallback instance type: must not be empty")
+		}
+		if seen[instanceType] {
+			return mapierrors.InvalidMachineConfiguration("invalid fallback instance type %s: instance types must not be repeated", instanceType)
+		}
+		seen[instanceType] = true
+	}
+
+	return nil
+}
+
+// setFallbackInstanceType switches the RunInstances request to the fallback instance type,
+// validates the CPU options of the provider spec against it and places it on a dedicated host with enough capacity for it
+func setFallbackInstanceType(machine runtimeclient.ObjectKey, request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, instanceType string, client alibabacloudClient.Client) error {
+	fallbackConfig := machineProviderConfig.DeepCopy()
+	fallbackConfig.InstanceType = instanceType
+
+	if err := setCPUOptions(request, fallbackConfig, client); err != nil {
+		return err
+	}
+
+	if err := setDedicatedHostPlacement(machine, fallbackConfig, request, client); err != nil {
+		return err
+	}
+
+	request.InstanceType = instanceType
+	return nil
+}
+
+// isInstanceTypeStockError returns true when the instance type is out of stock in the zone
+func isInstanceTypeStockError(err error) bool {
+	var serverError *sdkerrors.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	return strings.Contains(serverError.ErrorCode(), "NoStock")
+}
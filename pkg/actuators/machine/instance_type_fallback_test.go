@@ -0,0 +1,251 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"net/http"
+	"testing"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestValidateFallbackInstanceTypes(t *testing.T) {
+	cases := []struct {
+		name                  string
+		instanceType          string
+		fallbackInstanceTypes []string
+		succeeds              bool
+	}{
+		{
+			name:     "No fallback instance types",
+			succeeds: true,
+		},
+		{
+			name:                  "Fallback instance types",
+			instanceType:          "ecs.g7.2xlarge",
+			fallbackInstanceTypes: []string{"ecs.g6.2xlarge", "ecs.g5.2xlarge"},
+			succeeds:              true,
+		},
+		{
+			name:                  "Instance type taken from the launch template",
+			fallbackInstanceTypes: []string{"ecs.g6.2xlarge"},
+		},
+		{
+			name:                  "Repeated instance type",
+			instanceType:          "ecs.g7.2xlarge",
+			fallbackInstanceTypes: []string{"ecs.g6.2xlarge", "ecs.g7.2xlarge"},
+		},
+		{
+			name:                  "Empty instance type",
+			instanceType:          "ecs.g7.2xlarge",
+			fallbackInstanceTypes: []string{""},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerConfig := stubProviderConfig()
+			providerConfig.InstanceType = tc.instanceType
+			providerConfig.FallbackInstanceTypes = tc.fallbackInstanceTypes
+
+			err := validateFallbackInstanceTypes(providerConfig)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestRunInstancesInstanceTypeFallback(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noStockErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "OperationDenied.NoStock"}`, "")
+	inStock := "ecs.g5.2xlarge"
+
+	cases := []struct {
+		name                  string
+		fallbackInstanceTypes []string
+		expectedRequests      []string
+		expectedEvents        int
+		succeeds              bool
+	}{
+		{
+			name:                  "Fall back until an instance type is in stock",
+			fallbackInstanceTypes: []string{"ecs.g6.2xlarge", inStock, "ecs.g7a.2xlarge"},
+			expectedRequests:      []string{stubInstanceType, "ecs.g6.2xlarge", inStock},
+			expectedEvents:        2,
+			succeeds:              true,
+		},
+		{
+			name:                  "All instance types out of stock",
+			fallbackInstanceTypes: []string{"ecs.g6.2xlarge"},
+			expectedRequests:      []string{stubInstanceType, "ecs.g6.2xlarge"},
+			expectedEvents:        1,
+		},
+		{
+			name:             "No fallback instance types",
+			expectedRequests: []string{stubInstanceType},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubDescribeImagesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().ListResourceGroups(gomock.Any()).Return(stubListResourceGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeSecurityGroups(gomock.Any()).Return(stubDescribeSecurityGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).Return(stubDescribeVSwitchesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, stubInstanceStatus, "192.168.1.0"), nil).AnyTimes()
+
+			requests := make([]string, 0)
+			mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error) {
+					requests = append(requests, request.InstanceType)
+					if request.InstanceType != inStock {
+						return nil, noStockErr
+					}
+					return stubRunInstancesResponse(), nil
+				}).Times(len(tc.expectedRequests))
+
+			providerConfig := stubProviderConfig()
+			providerConfig.FallbackInstanceTypes = tc.fallbackInstanceTypes
+
+			eventRecorder := record.NewFakeRecorder(10)
+			_, err := runInstances(machine, providerConfig, "", new(int32), mockAlibabaCloudClient, eventRecorder)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			assert.Equal(t, tc.expectedRequests, requests)
+			assert.Len(t, eventRecorder.Events, tc.expectedEvents)
+		})
+	}
+}
+
+func TestSetFallbackInstanceTypeDedicatedHost(t *testing.T) {
+	fallbackInstanceType := "ecs.g7.4xlarge"
+	tags := []machinev1.Tag{{Key: "dedicated", Value: "workers"}}
+
+	cases := []struct {
+		name           string
+		availableVcpus int
+		expectedHostID string
+		succeeds       bool
+	}{
+		{
+			name:           "Dedicated host with capacity for the fallback instance type",
+			availableVcpus: 16,
+			expectedHostID: stubDedicatedHostID,
+			succeeds:       true,
+		},
+		{
+			name:           "Dedicated host without capacity for the fallback instance type",
+			availableVcpus: 8,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeInstanceTypes(gomock.Any()).Return(&ecs.DescribeInstanceTypesResponse{
+				InstanceTypes: ecs.InstanceTypesInDescribeInstanceTypes{
+					InstanceType: []ecs.InstanceType{{InstanceTypeId: fallbackInstanceType, CpuCoreCount: 16, MemorySize: 64}},
+				},
+			}, nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeDedicatedHosts(gomock.Any()).Return(&ecs.DescribeDedicatedHostsResponse{
+				DedicatedHosts: ecs.DedicatedHosts{
+					DedicatedHost: []ecs.DedicatedHost{
+						{DedicatedHostId: stubDedicatedHostID, Status: "Available", Capacity: ecs.Capacity{AvailableVcpus: tc.availableVcpus, AvailableMemory: 64}},
+					},
+				},
+			}, nil).Times(1)
+
+			providerConfig := stubProviderConfig()
+			providerConfig.Tenancy = machinev1.HostTenancy
+			providerConfig.DedicatedHost = &alibabacloudproviderv1.DedicatedHostPlacement{
+				Host: &machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeTags, Tags: &tags},
+			}
+
+			request := ecs.CreateRunInstancesRequest()
+			request.InstanceType = stubInstanceType
+			request.DedicatedHostId = "dh-primary"
+
+			machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+			err := setFallbackInstanceType(machineKey, request, providerConfig, fallbackInstanceType, mockAlibabaCloudClient)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			if tc.succeeds {
+				assert.Equal(t, fallbackInstanceType, request.InstanceType)
+				assert.Equal(t, tc.expectedHostID, request.DedicatedHostId)
+			}
+		})
+	}
+}
+
+func TestRunInstancesInstanceTypeFallbackVSwitches(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noStockErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "OperationDenied.NoStock"}`, "")
+	ipExhaustedErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "InvalidVSwitchId.IpNotEnough"}`, "")
+	fallbackInstanceType := "ecs.g6.2xlarge"
+
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubDescribeImagesResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().ListResourceGroups(gomock.Any()).Return(stubListResourceGroupsResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DescribeSecurityGroups(gomock.Any()).Return(stubDescribeSecurityGroupsResponse(), nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).Return(stubZonedDescribeVSwitchesResponse(), nil).AnyTimes()
+
+	type attempt struct{ instanceType, vSwitchID string }
+	attempts := make([]attempt, 0)
+	mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).DoAndReturn(
+		func(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error) {
+			attempts = append(attempts, attempt{request.InstanceType, request.VSwitchId})
+			switch {
+			case request.InstanceType == stubInstanceType && request.VSwitchId == stubFullVSwitchID:
+				return nil, noStockErr
+			case request.InstanceType == stubInstanceType, request.VSwitchId == stubVSwitchID:
+				return nil, ipExhaustedErr
+			default:
+				return stubRunInstancesResponse(), nil
+			}
+		}).Times(4)
+
+	providerConfig := stubProviderConfig()
+	providerConfig.VSwitch = *stubProviderConfigVSwitchTags()
+	providerConfig.FallbackInstanceTypes = []string{fallbackInstanceType}
+
+	_, err = runInstances(machine, providerConfig, "", new(int32), mockAlibabaCloudClient, record.NewFakeRecorder(10))
+	assert.NoError(t, err)
+	// The fallback instance type starts again from the vswitch with the most free IP addresses
+	assert.Equal(t, []attempt{
+		{stubInstanceType, stubVSwitchID},
+		{stubInstanceType, stubFullVSwitchID},
+		{fallbackInstanceType, stubVSwitchID},
+		{fallbackInstanceType, stubFullVSwitchID},
+	}, attempts)
+}
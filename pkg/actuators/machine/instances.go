@@ -31,12 +31,16 @@ import (
 
 	"k8s.io/klog"
 
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 
 	machinev1 "github.com/openshift/api/machine/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
 	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
 	"github.com/openshift/machine-api-operator/pkg/metrics"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -46,11 +50,6 @@ const (
 	// EcsImageStatusAvailable Image status
 	EcsImageStatusAvailable = "Available"
 
-	// InstanceDefaultTimeout default timeout
-	InstanceDefaultTimeout = 900
-	// DefaultWaitForInterval default interval
-	DefaultWaitForInterval = 5
-
 	// ECSInstanceStatusPending ecs instance status Pedding
 	ECSInstanceStatusPending = "Pending"
 	// ECSInstanceStatusStarting ecs instance status Starting
@@ -61,40 +60,69 @@ const (
 	ECSInstanceStatusStopping = "Stopping"
 	// ECSInstanceStatusStopped ecs instance status Stopped
 	ECSInstanceStatusStopped = "Stopped"
+	// ECSInstanceStatusExpired ecs instance status Expired
+	ECSInstanceStatusExpired = "Expired"
+	// ECSInstanceStatusReleased ecs instance status Released
+	ECSInstanceStatusReleased = "Released"
 
 	// ECSTagResourceTypeInstance  tag resource type
 	ECSTagResourceTypeInstance = "instance"
 )
 
-// runInstances create ecs
-func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, userData string, client alibabacloudClient.Client) (*ecs.Instance, error) {
+// runInstances create ecs, and returns the ID of the instance as soon as it is launched
+func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, userData string, launchAttempts *int32, client alibabacloudClient.Client, eventRecorder record.EventRecorder) (string, error) {
 	machineKey := runtimeclient.ObjectKey{
 		Name:      machine.Name,
 		Namespace: machine.Namespace,
 	}
 
-	// ImageID
-	imageID, err := getImageID(machineKey, machineProviderConfig, client)
-	if err != nil {
-		return nil, mapierrors.InvalidMachineConfiguration("error getting ImageID: %v", err)
+	// Values omitted from the provider spec are taken from the launch template
+	launchTemplate := machineProviderConfig.LaunchTemplate != nil
+
+	var (
+		imageID          string
+		securityGroupIDs *[]string
+		vSwitchIDs       []string
+		vSwitchID        string
+		err              error
+	)
+
+	// ImageID, unless it is already resolved and pinned by the caller
+	if machineProviderConfig.ImageID == "" && machineProviderConfig.Image != nil {
+		machineProviderConfig = machineProviderConfig.DeepCopy()
+		machineProviderConfig.ImageID, err = resolveImageID(machineKey, machineProviderConfig, client)
+		if err != nil {
+			return "", mapierrors.InvalidMachineConfiguration("error resolving ImageID: %v", err)
+		}
+	}
+	if !launchTemplate || machineProviderConfig.ImageID != "" {
+		imageID, err = getImageID(machineKey, machineProviderConfig, client)
+		if err != nil {
+			return "", mapierrors.InvalidMachineConfiguration("error getting ImageID: %v", err)
+		}
 	}
 
 	// SecurgityGroupIds
-	securityGroupIDs, err := getSecurityGroupIDs(machineKey, machineProviderConfig, client)
-	if err != nil {
-		return nil, mapierrors.InvalidMachineConfiguration("error getting security groups ID: %v", err)
+	if !launchTemplate || len(machineProviderConfig.SecurityGroups) > 0 {
+		securityGroupIDs, err = getSecurityGroupIDs(machineKey, machineProviderConfig, client)
+		if err != nil {
+			return "", mapierrors.InvalidMachineConfiguration("error getting security groups ID: %v", err)
+		}
 	}
 
 	// VSwitchID
-	vSwitchID, err := getVSwitchID(machineKey, machineProviderConfig, client)
-	if err != nil {
-		return nil, mapierrors.InvalidMachineConfiguration("error getting vswitch ID: %v", err)
+	if !launchTemplate || machineProviderConfig.VSwitch.Type != "" {
+		vSwitchIDs, err = getVSwitchIDs(machineKey, machineProviderConfig, client)
+		if err != nil {
+			return "", mapierrors.InvalidMachineConfiguration("error getting vswitch ID: %v", err)
+		}
+		vSwitchID = vSwitchIDs[0]
 	}
 
 	clusterID, ok := getClusterID(machine)
 	if !ok {
 		klog.Errorf("Unable to get cluster ID for machine: %q", machine.Name)
-		return nil, mapierrors.InvalidMachineConfiguration("Unable to get cluster ID for machine: %q", machine.Name)
+		return "", mapierrors.InvalidMachineConfiguration("Unable to get cluster ID for machine: %q", machine.Name)
 	}
 
 	// RunInstanceRequest init request params
@@ -105,10 +133,17 @@ func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machin
 	// RegionID
 	runInstancesRequest.RegionId = machineProviderConfig.RegionID
 
+	// LaunchTemplate
+	if launchTemplate {
+		if err := setLaunchTemplate(runInstancesRequest, machineProviderConfig.LaunchTemplate); err != nil {
+			return "", err
+		}
+	}
+
 	// ResourceGroupID
 	if groupId, err := getResourceGroupId(machineKey, machineProviderConfig, client); err != nil {
 		klog.Errorf("Unable to determine resource group ID for machine %q, err %q", machine.Name, err)
-		return nil, mapierrors.InvalidMachineConfiguration("Unable to determine resource group ID for machine: %q", machine.Name)
+		return "", mapierrors.InvalidMachineConfiguration("Unable to determine resource group ID for machine: %q", machine.Name)
 	} else {
 		runInstancesRequest.ResourceGroupId = groupId
 	}
@@ -117,7 +152,7 @@ func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machin
 	runInstancesRequest.SecurityGroupIds = securityGroupIDs
 
 	// Add tags to the created machine
-	tagList := buildTagList(machine.Name, clusterID, machineProviderConfig.Tags)
+	tagList := buildTagList(machine, clusterID, machineProviderConfig.Tags)
 
 	// Tags
 	runInstancesRequest.Tag = covertToRunInstancesTag(tagList)
@@ -158,9 +193,31 @@ func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machin
 	// VswitchId
 	runInstancesRequest.VSwitchId = vSwitchID
 
+	// ZoneId
+	if machineProviderConfig.ZoneID != "" {
+		runInstancesRequest.ZoneId = machineProviderConfig.ZoneID
+	}
+
+	// Ipv6AddressCount
+	if machineProviderConfig.IPv6 != nil {
+		if err := setIPv6Options(machineKey, runInstancesRequest, machineProviderConfig, vSwitchID, client); err != nil {
+			return "", err
+		}
+	}
+
+	// PrivateIpAddress
+	if machineProviderConfig.PrivateIPAddress != "" {
+		if err := validatePrivateIPAddress(machineKey, machineProviderConfig, vSwitchID, client); err != nil {
+			return "", err
+		}
+		runInstancesRequest.PrivateIpAddress = machineProviderConfig.PrivateIPAddress
+	}
+
 	// SystemDisk
 	runInstancesRequest.SystemDiskCategory = machineProviderConfig.SystemDisk.Category
-	runInstancesRequest.SystemDiskSize = strconv.FormatInt(machineProviderConfig.SystemDisk.Size, 10)
+	if !launchTemplate || machineProviderConfig.SystemDisk.Size > 0 {
+		runInstancesRequest.SystemDiskSize = strconv.FormatInt(machineProviderConfig.SystemDisk.Size, 10)
+	}
 	if machineProviderConfig.SystemDisk.Name != "" {
 		runInstancesRequest.SystemDiskDiskName = machineProviderConfig.SystemDisk.Name
 	}
@@ -212,22 +269,145 @@ func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machin
 		runInstancesRequest.UserData = userData
 	}
 
+	// KeyPairName
+	if machineProviderConfig.KeyPairName != "" {
+		if err := validateKeyPair(machineKey, machineProviderConfig, client); err != nil {
+			klog.Errorf("Unable to validate key pair for machine %q, err %q", machine.Name, err)
+			return "", err
+		}
+		runInstancesRequest.KeyPairName = machineProviderConfig.KeyPairName
+	}
+
 	// Setting Tenancy
 	instanceTenancy := machineProviderConfig.Tenancy
 
 	switch instanceTenancy {
 	case "":
-		// Set DefaultTenancy  when not set
-		runInstancesRequest.Tenancy = string(machinev1.DefaultTenancy)
+		// Set DefaultTenancy  when not set, unless it is taken from the launch template
+		if !launchTemplate {
+			runInstancesRequest.Tenancy = string(machinev1.DefaultTenancy)
+		}
 	case machinev1.DefaultTenancy, machinev1.HostTenancy:
 		runInstancesRequest.Tenancy = string(instanceTenancy)
 	default:
-		return nil, mapierrors.CreateMachine("invalid instance tenancy: %s. Allowed options are: %s,%s",
+		return "", mapierrors.CreateMachine("invalid instance tenancy: %s. Allowed options are: %s,%s",
 			instanceTenancy,
 			machinev1.DefaultTenancy,
 			machinev1.HostTenancy)
 	}
-	runResponse, err := client.RunInstances(runInstancesRequest)
+
+	// DedicatedHost
+	if err := setDedicatedHostPlacement(machineKey, machineProviderConfig, runInstancesRequest, client); err != nil {
+		klog.Errorf("Unable to determine dedicated host placement for machine %q, err %q", machine.Name, err)
+		return "", err
+	}
+
+	// SpotMarketOptions
+	if machineProviderConfig.SpotMarketOptions != nil {
+		if err := setSpotMarketOptions(runInstancesRequest, machineProviderConfig.SpotMarketOptions); err != nil {
+			return "", err
+		}
+	}
+
+	// DeploymentSet
+	if machineProviderConfig.DeploymentSet != nil {
+		deploymentSetID, err := getDeploymentSetID(machine, machineProviderConfig, client)
+		if err != nil {
+			klog.Errorf("Unable to determine deployment set ID for machine %q, err %q", machine.Name, err)
+			return "", err
+		}
+		runInstancesRequest.DeploymentSetId = deploymentSetID
+
+		if machineProviderConfig.DeploymentSet.GroupNo != nil {
+			groupNo := *machineProviderConfig.DeploymentSet.GroupNo
+			if groupNo < 1 || groupNo > 7 {
+				return "", mapierrors.InvalidMachineConfiguration("invalid deployment set group number: %d. Valid values are 1 to 7", groupNo)
+			}
+			runInstancesRequest.DeploymentSetGroupNo = requests.NewInteger64(groupNo)
+		}
+	}
+
+	// InstanceChargeType
+	if err := setInstanceChargeType(runInstancesRequest, machineProviderConfig); err != nil {
+		return "", err
+	}
+
+	// PrivatePoolOptions
+	if machineProviderConfig.PrivatePoolOptions != nil {
+		if err := setPrivatePoolOptions(runInstancesRequest, machineProviderConfig.PrivatePoolOptions); err != nil {
+			return "", err
+		}
+	}
+
+	// MetadataServiceOptions
+	if machineProviderConfig.MetadataServiceOptions != nil {
+		if err := setMetadataServiceOptions(runInstancesRequest, machineProviderConfig.MetadataServiceOptions); err != nil {
+			return "", err
+		}
+	}
+
+	// CpuOptions and CreditSpecification
+	if err := setCPUOptions(runInstancesRequest, machineProviderConfig, client); err != nil {
+		return "", err
+	}
+
+	// DeletionProtection
+	if err := setDeletionProtection(runInstancesRequest, machineProviderConfig); err != nil {
+		return "", err
+	}
+
+	// FallbackInstanceTypes
+	if err := validateFallbackInstanceTypes(machineProviderConfig); err != nil {
+		return "", err
+	}
+
+	// DeletePolicy
+	if err := validateDeletePolicy(machineProviderConfig.DeletePolicy); err != nil {
+		return "", err
+	}
+
+	// ProvisioningPolicy
+	if err := validateProvisioningPolicy(machineProviderConfig.ProvisioningPolicy); err != nil {
+		return "", err
+	}
+
+	// runAttempt sends the request with the client token of the launch attempt, so that retrying the request after
+	// a timeout returns the instance which was already launched. A request whose outcome is known consumes its client token,
+	// as ECS returns the outcome of the first request for a client token, even when the request is retried with other parameters.
+	runAttempt := func() (*ecs.RunInstancesResponse, error) {
+		runInstancesRequest.ClientToken = getClientToken(machine, *launchAttempts)
+		runResponse, err := client.RunInstances(runInstancesRequest)
+		var serverError *sdkerrors.ServerError
+		if err == nil || errors.As(err, &serverError) {
+			*launchAttempts++
+		}
+		return runResponse, err
+	}
+
+	// run sends the request, falling back to the match criteria of the private pool options once the private pool cannot be used
+	run := func() (*ecs.RunInstancesResponse, error) {
+		runResponse, err := runAttempt()
+		if err != nil && isPrivatePoolError(err) && setPrivatePoolFallback(runInstancesRequest, machineProviderConfig.PrivatePoolOptions) {
+			klog.Warningf("%s: private pool %s cannot be used, falling back to match criteria %s: %v", machine.Name,
+				machineProviderConfig.PrivatePoolOptions.ID, machineProviderConfig.PrivatePoolOptions.FallbackMatchCriteria, err)
+			runResponse, err = runAttempt()
+		}
+		return runResponse, err
+	}
+
+	runResponse, err := runInstancesInVSwitches(machineKey, runInstancesRequest, machineProviderConfig, vSwitchIDs, client, run)
+	for _, instanceType := range machineProviderConfig.FallbackInstanceTypes {
+		if err == nil || !isInstanceTypeStockError(err) {
+			break
+		}
+		klog.Warningf("%s: instance type %s is out of stock, falling back to instance type %s: %v", machine.Name, runInstancesRequest.InstanceType, instanceType, err)
+		eventRecorder.Eventf(machine, corev1.EventTypeWarning, instanceTypeFallbackEventReason,
+			"Instance type %s is out of stock, falling back to instance type %s", runInstancesRequest.InstanceType, instanceType)
+		if err := setFallbackInstanceType(machineKey, runInstancesRequest, machineProviderConfig, instanceType, client); err != nil {
+			return "", err
+		}
+		runResponse, err = runInstancesInVSwitches(machineKey, runInstancesRequest, machineProviderConfig, vSwitchIDs, client, run)
+	}
 	if err != nil {
 		metrics.RegisterFailedInstanceCreate(&metrics.MachineLabels{
 			Name:      machine.Name,
@@ -236,19 +416,26 @@ func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machin
 		})
 
 		klog.Errorf("Error creating ECS instance: %v", err)
-		return nil, mapierrors.CreateMachine("error creating ECS instance: %v", err)
+		if isDedicatedHostCapacityError(err) {
+			return "", mapierrors.InvalidMachineConfiguration("dedicated host has no capacity for the instance: %v", err)
+		}
+		if isPrivateIPAddressError(err) {
+			return "", mapierrors.InvalidMachineConfiguration("private IP address %s cannot be assigned to the instance: %v", machineProviderConfig.PrivateIPAddress, err)
+		}
+		return "", mapierrors.CreateMachine("error creating ECS instance: %v", err)
 	}
 
 	if runResponse == nil || len(runResponse.InstanceIdSets.InstanceIdSet) != 1 {
 		klog.Errorf("Unexpected reservation creating instances: %v", runResponse)
-		return nil, mapierrors.CreateMachine("unexpected reservation creating instance")
+		return "", mapierrors.CreateMachine("unexpected reservation creating instance")
 	}
 
-	// Sleep
-	time.Sleep(5 * time.Second)
+	return runResponse.InstanceIdSets.InstanceIdSet[0], nil
+}
 
-	// Query the status of the instance until Running
-	instance, err := waitForInstancesStatus(client, machineProviderConfig.RegionID, []string{runResponse.InstanceIdSets.InstanceIdSet[0]}, ECSInstanceStatusRunning, InstanceDefaultTimeout)
+// getLaunchedInstance returns the launched instance, without waiting for it to be Running
+func getLaunchedInstance(machine *machinev1beta1.Machine, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, instanceID string, client alibabacloudClient.Client) (*ecs.Instance, error) {
+	instances, err := describeInstances([]string{instanceID}, machineProviderConfig.RegionID, client)
 	if err != nil {
 		metrics.RegisterFailedInstanceCreate(&metrics.MachineLabels{
 			Name:      machine.Name,
@@ -256,69 +443,201 @@ func runInstances(machine *machinev1beta1.Machine, machineProviderConfig *machin
 			Reason:    err.Error(),
 		})
 
-		klog.Errorf("Error waiting ECS instance to Running: %v", err)
-		return nil, mapierrors.CreateMachine("error waiting ECS instance to Running: %v", err)
+		klog.Errorf("Error describing ECS instance %s: %v", instanceID, err)
+		return nil, mapierrors.CreateMachine("error describing ECS instance %s: %v", instanceID, err)
 	}
 
-	if instance == nil || len(instance) < 1 {
-		return nil, mapierrors.CreateMachine(" ECS instance %s not found", runResponse.InstanceIdSets.InstanceIdSet[0])
+	if len(instances) < 1 {
+		return nil, mapierrors.CreateMachine(" ECS instance %s not found", instanceID)
 	}
 
-	return instance[0], nil
+	return &instances[0], nil
 }
 
-// waitForInstancesStatus waits for instances to given status when instance.NotFound wait until timeout
-func waitForInstancesStatus(client alibabacloudClient.Client, regionID string, instanceIds []string, instanceStatus string, timeout int) ([]*ecs.Instance, error) {
-	if timeout <= 0 {
-		timeout = InstanceDefaultTimeout
+// getClientToken returns the client token of a RunInstances request of the machine.
+// It is derived from the UID of the machine, so that a request retried after a timeout does not launch another instance,
+// and from the number of launch attempts, so that every attempt with a known outcome is followed by a new request.
+func getClientToken(machine *machinev1beta1.Machine, launchAttempts int32) string {
+	if launchAttempts == 0 {
+		return string(machine.UID)
 	}
+	return fmt.Sprintf("%s-%d", machine.UID, launchAttempts)
+}
 
-	result, err := WaitForResult(fmt.Sprintf("Wait for the instances %v state to change to %s ", instanceIds, instanceStatus), func() (stop bool, result interface{}, err error) {
-		describeInstancesRequest := ecs.CreateDescribeInstancesRequest()
-		describeInstancesRequest.RegionId = regionID
-		ids, _ := json.Marshal(instanceIds)
-		describeInstancesRequest.InstanceIds = string(ids)
-		describeInstancesRequest.Scheme = "https"
-		describeInstancesResponse, err := client.DescribeInstances(describeInstancesRequest)
-		klog.V(3).Infof("instance resonpse %v", describeInstancesResponse)
-		if err != nil {
-			return false, nil, err
+// setSpotMarketOptions sets the spot strategy, price limit, protection period and interruption
+// behavior on the RunInstances request from the spot market options of the provider spec.
+func setSpotMarketOptions(request *ecs.RunInstancesRequest, spotMarketOptions *alibabacloudproviderv1.SpotMarketOptions) error {
+	strategy := spotMarketOptions.Strategy
+	if strategy == "" {
+		// Default to a price limited spot instance only when a price limit was given
+		if spotMarketOptions.MaxPrice != nil && *spotMarketOptions.MaxPrice != "" {
+			strategy = alibabacloudproviderv1.SpotWithPriceLimitStrategy
+		} else {
+			strategy = alibabacloudproviderv1.SpotAsPriceGoStrategy
 		}
+	}
 
-		if len(describeInstancesResponse.Instances.Instance) <= 0 {
-			return true, nil, fmt.Errorf("the instances %v not found. ", instanceIds)
+	switch strategy {
+	case alibabacloudproviderv1.NoSpotStrategy:
+		request.SpotStrategy = string(strategy)
+		return nil
+	case alibabacloudproviderv1.SpotWithPriceLimitStrategy:
+		if spotMarketOptions.MaxPrice == nil || *spotMarketOptions.MaxPrice == "" {
+			return mapierrors.InvalidMachineConfiguration("spot strategy %s requires a max price", strategy)
+		}
+		maxPrice, err := strconv.ParseFloat(*spotMarketOptions.MaxPrice, 64)
+		if err != nil || maxPrice <= 0 {
+			return mapierrors.InvalidMachineConfiguration("invalid spot max price: %s", *spotMarketOptions.MaxPrice)
 		}
+		request.SpotPriceLimit = requests.Float(*spotMarketOptions.MaxPrice)
+	case alibabacloudproviderv1.SpotAsPriceGoStrategy:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid spot strategy: %s. Allowed options are: %s,%s,%s",
+			strategy,
+			alibabacloudproviderv1.SpotWithPriceLimitStrategy,
+			alibabacloudproviderv1.SpotAsPriceGoStrategy,
+			alibabacloudproviderv1.NoSpotStrategy)
+	}
+	request.SpotStrategy = string(strategy)
+
+	// SpotDuration
+	if spotMarketOptions.Duration != nil {
+		if *spotMarketOptions.Duration < 0 || *spotMarketOptions.Duration > 6 {
+			return mapierrors.InvalidMachineConfiguration("invalid spot duration: %d. Valid values are 0 to 6", *spotMarketOptions.Duration)
+		}
+		request.SpotDuration = requests.NewInteger64(*spotMarketOptions.Duration)
+	}
 
-		idsLen := len(instanceIds)
-		instances := make([]*ecs.Instance, 0)
+	// SpotInterruptionBehavior
+	switch spotMarketOptions.InterruptionBehavior {
+	case "":
+	case alibabacloudproviderv1.SpotInterruptionBehaviorTerminate, alibabacloudproviderv1.SpotInterruptionBehaviorStop:
+		request.SpotInterruptionBehavior = string(spotMarketOptions.InterruptionBehavior)
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid spot interruption behavior: %s. Allowed options are: %s,%s",
+			spotMarketOptions.InterruptionBehavior,
+			alibabacloudproviderv1.SpotInterruptionBehaviorTerminate,
+			alibabacloudproviderv1.SpotInterruptionBehaviorStop)
+	}
 
-		for _, instance := range describeInstancesResponse.Instances.Instance {
-			if instance.Status == instanceStatus {
-				instances = append(instances, &instance)
-			}
+	return nil
+}
+
+// setInstanceChargeType sets the billing method on the RunInstances request. Subscription instances
+// additionally get the subscription period and auto-renewal settings of the provider spec.
+func setInstanceChargeType(request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	switch machineProviderConfig.InstanceChargeType {
+	case "":
+		return nil
+	case alibabacloudproviderv1.PostPaidInstanceChargeType:
+		request.InstanceChargeType = string(machineProviderConfig.InstanceChargeType)
+		return nil
+	case alibabacloudproviderv1.PrePaidInstanceChargeType:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid instance charge type: %s. Allowed options are: %s,%s",
+			machineProviderConfig.InstanceChargeType,
+			alibabacloudproviderv1.PrePaidInstanceChargeType,
+			alibabacloudproviderv1.PostPaidInstanceChargeType)
+	}
+
+	// Spot instances are always billed pay-as-you-go
+	if machineProviderConfig.SpotMarketOptions != nil && machineProviderConfig.SpotMarketOptions.Strategy != alibabacloudproviderv1.NoSpotStrategy {
+		return mapierrors.InvalidMachineConfiguration("spot instances can not use instance charge type %s", alibabacloudproviderv1.PrePaidInstanceChargeType)
+	}
+
+	subscriptionOptions := machineProviderConfig.SubscriptionOptions
+	if subscriptionOptions == nil {
+		return mapierrors.InvalidMachineConfiguration("instance charge type %s requires subscriptionOptions", alibabacloudproviderv1.PrePaidInstanceChargeType)
+	}
+
+	periodUnit := subscriptionOptions.PeriodUnit
+	if periodUnit == "" {
+		periodUnit = alibabacloudproviderv1.MonthPeriodUnit
+	}
+
+	var validPeriods, validAutoRenewPeriods []int64
+	switch periodUnit {
+	case alibabacloudproviderv1.WeekPeriodUnit:
+		validPeriods = []int64{1, 2, 3, 4}
+		validAutoRenewPeriods = []int64{1, 2, 3}
+	case alibabacloudproviderv1.MonthPeriodUnit:
+		validPeriods = []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 12, 24, 36, 48, 60}
+		validAutoRenewPeriods = []int64{1, 2, 3, 6, 12, 24, 36, 48, 60}
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid subscription period unit: %s. Allowed options are: %s,%s",
+			periodUnit,
+			alibabacloudproviderv1.WeekPeriodUnit,
+			alibabacloudproviderv1.MonthPeriodUnit)
+	}
+
+	if !containsInt64(validPeriods, subscriptionOptions.Period) {
+		return mapierrors.InvalidMachineConfiguration("invalid subscription period: %d %s. Allowed values are: %v",
+			subscriptionOptions.Period, periodUnit, validPeriods)
+	}
+
+	request.InstanceChargeType = string(alibabacloudproviderv1.PrePaidInstanceChargeType)
+	request.Period = requests.NewInteger64(subscriptionOptions.Period)
+	request.PeriodUnit = string(periodUnit)
+
+	if subscriptionOptions.AutoRenew {
+		if !containsInt64(validAutoRenewPeriods, subscriptionOptions.AutoRenewPeriod) {
+			return mapierrors.InvalidMachineConfiguration("invalid subscription auto-renew period: %d %s. Allowed values are: %v",
+				subscriptionOptions.AutoRenewPeriod, periodUnit, validAutoRenewPeriods)
 		}
+		request.AutoRenew = requests.NewBoolean(true)
+		request.AutoRenewPeriod = requests.NewInteger64(subscriptionOptions.AutoRenewPeriod)
+	}
 
-		if len(instances) == idsLen {
-			return true, instances, nil
+	return nil
+}
+
+func containsInt64(values []int64, value int64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
+	}
+	return false
+}
 
-		return false, nil, fmt.Errorf("the instances  %v state are not  the expected state  %s ", instanceIds, instanceStatus)
+// convertSubscriptionInstances converts all subscription instances to pay-as-you-go,
+// as subscription instances can not be released by DeleteInstances.
+func convertSubscriptionInstances(client alibabacloudClient.Client, regionID string, instances []*ecs.Instance) error {
+	subscriptionInstanceIDs := make([]string, 0)
+	for _, instance := range instances {
+		// expired subscription instances are released without being converted
+		if instance.InstanceChargeType == string(alibabacloudproviderv1.PrePaidInstanceChargeType) && instance.Status != ECSInstanceStatusExpired {
+			subscriptionInstanceIDs = append(subscriptionInstanceIDs, instance.InstanceId)
+		}
+	}
 
-	}, false, DefaultWaitForInterval, timeout)
+	if len(subscriptionInstanceIDs) < 1 {
+		return nil
+	}
 
+	instanceIDs, err := json.Marshal(subscriptionInstanceIDs)
 	if err != nil {
-		klog.Errorf("Wait for the instances %v state change to %v occur error %v", instanceIds, instanceStatus, err)
-		return nil, err
+		return err
 	}
 
-	if result == nil {
-		return nil, nil
+	klog.Infof("Converting subscription instances %v to pay-as-you-go before deletion", subscriptionInstanceIDs)
+
+	modifyInstanceChargeTypeRequest := ecs.CreateModifyInstanceChargeTypeRequest()
+	modifyInstanceChargeTypeRequest.RegionId = regionID
+	modifyInstanceChargeTypeRequest.Scheme = "https"
+	modifyInstanceChargeTypeRequest.InstanceIds = string(instanceIDs)
+	modifyInstanceChargeTypeRequest.InstanceChargeType = string(alibabacloudproviderv1.PostPaidInstanceChargeType)
+	modifyInstanceChargeTypeRequest.IncludeDataDisks = requests.NewBoolean(true)
+	modifyInstanceChargeTypeRequest.AutoPay = requests.NewBoolean(true)
+
+	if _, err := client.ModifyInstanceChargeType(modifyInstanceChargeTypeRequest); err != nil {
+		return fmt.Errorf("failed to convert subscription instances %v to pay-as-you-go, they must be released manually: %w", subscriptionInstanceIDs, err)
 	}
 
-	return result.([]*ecs.Instance), nil
+	return nil
 }
 
-func getImageID(machine runtimeclient.ObjectKey, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+func getImageID(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
 	klog.Infof("%s validate image in region %s", machineProviderConfig.ImageID, machineProviderConfig.RegionID)
 	request := ecs.CreateDescribeImagesRequest()
 	request.ImageId = machineProviderConfig.ImageID
@@ -351,7 +670,91 @@ func getImageID(machine runtimeclient.ObjectKey, machineProviderConfig *machinev
 	return image.ImageId, nil
 }
 
-func getSecurityGroupIDs(machine runtimeclient.ObjectKey, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (*[]string, error) {
+// setIPv6Options requests IPv6 addresses for the instance after checking that the vswitch has an IPv6 CIDR block
+func setIPv6Options(machine runtimeclient.ObjectKey, request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, vSwitchID string, client alibabacloudClient.Client) error {
+	options := machineProviderConfig.IPv6
+
+	switch options.PrimaryIPFamily {
+	case "", alibabacloudproviderv1.IPv4IPFamily, alibabacloudproviderv1.IPv6IPFamily:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid primary IP family: %s. Allowed options are: %s,%s",
+			options.PrimaryIPFamily,
+			alibabacloudproviderv1.IPv4IPFamily,
+			alibabacloudproviderv1.IPv6IPFamily)
+	}
+
+	addressCount := options.AddressCount
+	if addressCount == 0 {
+		addressCount = 1
+	}
+	if addressCount < 1 {
+		return mapierrors.InvalidMachineConfiguration("invalid IPv6 address count: %d", addressCount)
+	}
+
+	// The vswitch of the launch template is checked by RunInstances
+	if vSwitchID != "" {
+		describeVSwitchesRequest := vpc.CreateDescribeVSwitchesRequest()
+		describeVSwitchesRequest.Scheme = "https"
+		describeVSwitchesRequest.RegionId = machineProviderConfig.RegionID
+		describeVSwitchesRequest.VSwitchId = vSwitchID
+
+		describeVSwitchesResponse, err := client.DescribeVSwitches(describeVSwitchesRequest)
+		if err != nil {
+			klog.Errorf("error describing vswitch %s: %v", vSwitchID, err)
+			return fmt.Errorf("error describing vswitch %s: %v", vSwitchID, err)
+		}
+		if len(describeVSwitchesResponse.VSwitches.VSwitch) < 1 {
+			return mapierrors.InvalidMachineConfiguration("vswitch %s not found", vSwitchID)
+		}
+		if describeVSwitchesResponse.VSwitches.VSwitch[0].Ipv6CidrBlock == "" {
+			klog.Errorf("%s: vswitch %s has no IPv6 CIDR block", machine.Name, vSwitchID)
+			return mapierrors.InvalidMachineConfiguration("vswitch %s has no IPv6 CIDR block", vSwitchID)
+		}
+	}
+
+	request.Ipv6AddressCount = requests.NewInteger64(addressCount)
+	return nil
+}
+
+// isIPv6Primary returns true if IPv6 addresses are reported before IPv4 addresses in the Machine status.
+// Without IPv6 options IPv4 addresses are reported first, which is the default primary IP family.
+func isIPv6Primary(machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) bool {
+	if machineProviderConfig == nil || machineProviderConfig.IPv6 == nil {
+		return false
+	}
+	return machineProviderConfig.IPv6.PrimaryIPFamily == alibabacloudproviderv1.IPv6IPFamily
+}
+
+// validateKeyPair checks that the key pair referenced in the provider spec exists in the region
+func validateKeyPair(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) error {
+	klog.Infof("%s validate key pair in region %s", machineProviderConfig.KeyPairName, machineProviderConfig.RegionID)
+	request := ecs.CreateDescribeKeyPairsRequest()
+	request.Scheme = "https"
+	request.RegionId = machineProviderConfig.RegionID
+	request.KeyPairName = machineProviderConfig.KeyPairName
+
+	response, err := client.DescribeKeyPairs(request)
+	if err != nil {
+		metrics.RegisterFailedInstanceCreate(&metrics.MachineLabels{
+			Name:      machine.Name,
+			Namespace: machine.Namespace,
+			Reason:    err.Error(),
+		})
+		klog.Errorf("error describing key pairs: %v", err)
+		return fmt.Errorf("error describing key pairs: %v", err)
+	}
+
+	// KeyPairName supports wildcards, so only an exact match is accepted
+	for _, keyPair := range response.KeyPairs.KeyPair {
+		if keyPair.KeyPairName == machineProviderConfig.KeyPairName {
+			return nil
+		}
+	}
+
+	return mapierrors.InvalidMachineConfiguration("key pair %q not found in region %s", machineProviderConfig.KeyPairName, machineProviderConfig.RegionID)
+}
+
+func getSecurityGroupIDs(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (*[]string, error) {
 	klog.Infof("query security groups in region %s", machineProviderConfig.RegionID)
 	var securityGroupIDs []string
 
@@ -384,7 +787,7 @@ func getSecurityGroupIDs(machine runtimeclient.ObjectKey, machineProviderConfig
 	return &securityGroupIDs, nil
 }
 
-func getSecurityGroupIDByTags(machine runtimeclient.ObjectKey, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, tags *[]machinev1.Tag, client alibabacloudClient.Client) ([]string, error) {
+func getSecurityGroupIDByTags(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, tags *[]machinev1.Tag, client alibabacloudClient.Client) ([]string, error) {
 	if tags == nil {
 		return nil, mapierrors.InvalidMachineConfiguration("No tags provided for security group ID search for machine: %q", machine.Name)
 	}
@@ -434,31 +837,42 @@ func buildDescribeSecurityGroupsTag(tags []machinev1.Tag) *[]ecs.DescribeSecurit
 	return &describeSecurityGroupsTag
 }
 
-func getVSwitchID(machine runtimeclient.ObjectKey, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+func getVSwitchID(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+	vSwitchIDs, err := getVSwitchIDs(machine, machineProviderConfig, client)
+	if err != nil {
+		return "", err
+	}
+	return vSwitchIDs[0], nil
+}
+
+// getVSwitchIDs returns the vswitches the instance can be created in, in the order they should be tried
+func getVSwitchIDs(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) ([]string, error) {
 	klog.Infof("validate vswitch in region %s", machineProviderConfig.RegionID)
 	switch machineProviderConfig.VSwitch.Type {
 	case machinev1.AlibabaResourceReferenceTypeID:
 		if machineProviderConfig.VSwitch.ID != nil && *machineProviderConfig.VSwitch.ID != "" {
-			return *machineProviderConfig.VSwitch.ID, nil
+			return []string{*machineProviderConfig.VSwitch.ID}, nil
 		} else {
-			return "", mapierrors.InvalidMachineConfiguration("No vswitch resource id provided")
+			return nil, mapierrors.InvalidMachineConfiguration("No vswitch resource id provided")
 		}
 	case machinev1.AlibabaResourceReferenceTypeTags:
-		return getVSwitchIDFromTags(machine, machineProviderConfig, client)
+		return getVSwitchIDsFromTags(machine, machineProviderConfig, client)
 	default:
-		return "", mapierrors.InvalidMachineConfiguration("Unknown vswitch resource reference type: %s", machineProviderConfig.VSwitch.Type)
+		return nil, mapierrors.InvalidMachineConfiguration("Unknown vswitch resource reference type: %s", machineProviderConfig.VSwitch.Type)
 	}
 }
 
-func getVSwitchIDFromTags(machine runtimeclient.ObjectKey, mpc *machinev1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+func getVSwitchIDsFromTags(machine runtimeclient.ObjectKey, mpc *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) ([]string, error) {
 	if mpc.VSwitch.Tags == nil {
-		return "", mapierrors.InvalidMachineConfiguration("No tags provided for VSwitch ID search for machine: %q", machine.Name)
+		return nil, mapierrors.InvalidMachineConfiguration("No tags provided for VSwitch ID search for machine: %q", machine.Name)
 	}
 	// Build a request to fetch the vSwitchID from the tags provided
 	describeVSwitchesRequest := vpc.CreateDescribeVSwitchesRequest()
 	describeVSwitchesRequest.Scheme = "https"
 	describeVSwitchesRequest.RegionId = mpc.RegionID
 	describeVSwitchesRequest.VpcId = mpc.VpcID
+	describeVSwitchesRequest.ZoneId = mpc.ZoneID
+	describeVSwitchesRequest.PageSize = requests.NewInteger(describeVSwitchesPageSize)
 	describeVSwitchesRequest.Tag = buildDescribeVSwitchesTag(*mpc.VSwitch.Tags)
 	describeVSwitchesResponse, err := client.DescribeVSwitches(describeVSwitchesRequest)
 	if err != nil {
@@ -468,13 +882,14 @@ func getVSwitchIDFromTags(machine runtimeclient.ObjectKey, mpc *machinev1.Alibab
 			Reason:    err.Error(),
 		})
 		klog.Errorf("error describing vswitches: %v", err)
-		return "", fmt.Errorf("error describing vswitches: %v", err)
+		return nil, fmt.Errorf("error describing vswitches: %v", err)
 	}
-	if len(describeVSwitchesResponse.VSwitches.VSwitch) < 1 {
-		klog.Errorf("no vswitches found for given tags, vpcid, and regionid")
-		return "", fmt.Errorf("no vswitches found for given tags, vpcid, and regionid")
+	vSwitchIDs := rankVSwitches(describeVSwitchesResponse.VSwitches.VSwitch, mpc.ZoneID)
+	if len(vSwitchIDs) < 1 {
+		klog.Errorf("no vswitches found for given tags, vpcid, zoneid and regionid")
+		return nil, fmt.Errorf("no vswitches found for given tags, vpcid, zoneid and regionid")
 	}
-	return describeVSwitchesResponse.VSwitches.VSwitch[0].VSwitchId, nil
+	return vSwitchIDs, nil
 }
 
 func buildDescribeVSwitchesTag(tags []machinev1.Tag) *[]vpc.DescribeVSwitchesTag {
@@ -504,21 +919,23 @@ func removeDuplicatedMachineTags(machineTags []machinev1.Tag) []*machinev1.Tag {
 }
 
 // buildTagList compile a list of ecs tags from machine provider spec and infrastructure object platform spec
-func buildTagList(machineName string, clusterID string, machineTags []machinev1.Tag) []*machinev1.Tag {
+func buildTagList(machine *machinev1beta1.Machine, clusterID string, machineTags []machinev1.Tag) []*machinev1.Tag {
 	rawTagList := make([]*machinev1.Tag, 0)
 
 	for _, tag := range machineTags {
 		// Alibabacoud tags are case sensitive, so we don't need to worry about other casing of "Name"
-		if !strings.HasPrefix(tag.Key, clusterFilterKeyPrefix) && tag.Key != clusterFilterName {
+		if !strings.HasPrefix(tag.Key, clusterFilterKeyPrefix) && tag.Key != clusterFilterName && !isMachineOwnershipTagKey(tag.Key) {
 			rawTagList = append(rawTagList, &machinev1.Tag{Key: tag.Key, Value: tag.Value})
 		}
 	}
 	rawTagList = append(rawTagList, []*machinev1.Tag{
 		{Key: clusterFilterKeyPrefix + clusterID, Value: clusterFilterValue},
-		{Key: clusterFilterName, Value: machineName},
+		{Key: clusterFilterName, Value: machine.Name},
 		{Key: clusterOwnedKey, Value: clusterOwnedValue},
 		{Key: machineTagKeyFrom, Value: machineTagValueFrom},
 		{Key: machineIsvIntegrationTagKey, Value: machineTagValueFrom},
+		{Key: machineNamespaceTagKey, Value: machine.Namespace},
+		{Key: machineUIDTagKey, Value: string(machine.UID)},
 	}...)
 
 	return removeDuplicatedTags(rawTagList)
@@ -552,10 +969,6 @@ func covertToRunInstancesTag(tags []*machinev1.Tag) *[]ecs.RunInstancesTag {
 	return &runInstancesTags
 }
 
-func getExistingInstanceByID(instanceID string, regionID string, client alibabacloudClient.Client) (*ecs.Instance, error) {
-	return getInstanceByID(instanceID, regionID, client, supportedInstanceStates())
-}
-
 // getInstanceByID returns the instance with the given ID if it exists.
 func getInstanceByID(instanceID string, regionID string, client alibabacloudClient.Client, instanceStates []string) (*ecs.Instance, error) {
 	if instanceID == "" {
@@ -650,7 +1063,9 @@ func getInstances(machine *machinev1beta1.Machine, regionID string, client aliba
 
 	instances := make([]*ecs.Instance, 0)
 
-	for _, instance := range result.Instances.Instance {
+	for i := range result.Instances.Instance {
+		// copy each instance before taking its address, the range variable is shared between iterations
+		instance := result.Instances.Instance[i]
 		err := instanceHasSupportedState(&instance, instanceStates)
 		if err != nil {
 			klog.Errorf("Excluding instance matching %s: %v", machine.Name, err)
@@ -663,7 +1078,8 @@ func getInstances(machine *machinev1beta1.Machine, regionID string, client aliba
 }
 
 // stopInstances stop all provided instances with a single ECS request.
-func stopInstances(client alibabacloudClient.Client, regionID string, instances []*ecs.Instance) ([]ecs.InstanceResponse, error) {
+// A force stop also applies to the instances which are already Stopping.
+func stopInstances(client alibabacloudClient.Client, regionID string, instances []*ecs.Instance, forceStop bool, stoppedMode alibabacloudproviderv1.StoppedMode) ([]ecs.InstanceResponse, error) {
 	instanceIDs := make([]string, 0)
 	// Stop all older instances:
 	for _, instance := range instances {
@@ -685,11 +1101,15 @@ func stopInstances(client alibabacloudClient.Client, regionID string, instances
 	// needStoppedInstance
 	needStoppedInstanceIDs := make([]string, 0)
 	for _, instance := range existingInstances {
-		if instance.Status == ECSInstanceStatusRunning {
+		if instance.Status == ECSInstanceStatusRunning || forceStop && instance.Status == ECSInstanceStatusStopping {
 			needStoppedInstanceIDs = append(needStoppedInstanceIDs, instance.InstanceId)
 		}
 	}
 
+	if len(needStoppedInstanceIDs) == 0 {
+		return nil, nil
+	}
+
 	for _, instanceID := range needStoppedInstanceIDs {
 		klog.Infof("Stopping %v instance", instanceID)
 	}
@@ -698,6 +1118,10 @@ func stopInstances(client alibabacloudClient.Client, regionID string, instances
 	stopInstancesRequest.RegionId = regionID
 	stopInstancesRequest.Scheme = "https"
 	stopInstancesRequest.InstanceId = &needStoppedInstanceIDs
+	stopInstancesRequest.StoppedMode = string(stoppedMode)
+	if forceStop {
+		stopInstancesRequest.ForceStop = requests.NewBoolean(true)
+	}
 
 	stopInstancesResponse, err := client.StopInstances(stopInstancesRequest)
 	if err != nil {
@@ -767,9 +1191,20 @@ func getRunningFromInstances(instances []*ecs.Instance) []*ecs.Instance {
 	return runningInstances
 }
 
+// isSpotInstance returns true if the instance was created as a spot instance.
+func isSpotInstance(instance *ecs.Instance) bool {
+	switch alibabacloudproviderv1.SpotStrategy(instance.SpotStrategy) {
+	case alibabacloudproviderv1.SpotWithPriceLimitStrategy, alibabacloudproviderv1.SpotAsPriceGoStrategy:
+		return true
+	default:
+		return false
+	}
+}
+
 // correctExistingTags validates Name and clusterID tags are correct on the instance
-// and sets them if they are not.
-func correctExistingTags(machine *machinev1beta1.Machine, regionID string, instance *ecs.Instance, client alibabacloudClient.Client) error {
+// and sets them if they are not. The machine namespace and UID tags are only set when tagOwnership is true,
+// so that an untagged instance found by its name is not claimed by the machine.
+func correctExistingTags(machine *machinev1beta1.Machine, regionID string, instance *ecs.Instance, tagOwnership bool, client alibabacloudClient.Client) error {
 	// https://www.alibabacloud.com/help/en/doc-detail/110424.htm
 	if instance == nil || instance.InstanceId == "" {
 		return fmt.Errorf("unexpected nil found in instance: %v", instance)
@@ -781,6 +1216,8 @@ func correctExistingTags(machine *machinev1beta1.Machine, regionID string, insta
 	nameTagOk := false
 	clusterTagOk := false
 	ownedTagOk := false
+	namespaceTagOk := !tagOwnership
+	uidTagOk := !tagOwnership
 	for _, tag := range instance.Tags.Tag {
 		if tag.TagKey != "" && tag.TagValue != "" {
 			if tag.TagKey == clusterFilterName && tag.TagValue == machine.Name {
@@ -792,16 +1229,26 @@ func correctExistingTags(machine *machinev1beta1.Machine, regionID string, insta
 			if tag.TagKey == clusterOwnedKey && tag.TagValue == clusterOwnedValue {
 				ownedTagOk = true
 			}
+			if tag.TagKey == machineNamespaceTagKey && tag.TagValue == machine.Namespace {
+				namespaceTagOk = true
+			}
+			if tag.TagKey == machineUIDTagKey && tag.TagValue == string(machine.UID) {
+				uidTagOk = true
+			}
 		}
 	}
 
 	// Update our tags if they're not set or correct
-	if !nameTagOk || !clusterTagOk || !ownedTagOk {
+	if !nameTagOk || !clusterTagOk || !ownedTagOk || !namespaceTagOk || !uidTagOk {
 		// Create tags only adds/replaces what is present, does not affect other tags.
 		request := ecs.CreateTagResourcesRequest()
 		request.Scheme = "https"
 		request.RegionId = regionID
-		request.Tag = tagResourceTags(clusterID, machine.Name)
+		tags := *tagResourceTags(clusterID, machine.Name)
+		if tagOwnership {
+			tags = append(tags, machineOwnershipTags(machine)...)
+		}
+		request.Tag = &tags
 		request.ResourceId = &[]string{instance.InstanceId}
 		request.ResourceType = ECSTagResourceTypeInstance
 
@@ -817,7 +1264,7 @@ func correctExistingTags(machine *machinev1beta1.Machine, regionID string, insta
 // resource group id if available, or determine the group id by using the search tags.
 // An error will be returned if no group id can be found, or if multiple groups are
 // found from the search tags.
-func getResourceGroupId(machine runtimeclient.ObjectKey, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+func getResourceGroupId(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
 	switch machineProviderConfig.ResourceGroup.Type {
 	case machinev1.AlibabaResourceReferenceTypeID:
 		if machineProviderConfig.ResourceGroup.ID != nil && *machineProviderConfig.ResourceGroup.ID != "" {
@@ -832,7 +1279,7 @@ func getResourceGroupId(machine runtimeclient.ObjectKey, machineProviderConfig *
 	}
 }
 
-func getResourceGroupIdFromName(machine runtimeclient.ObjectKey, machineProviderConfig *machinev1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
+func getResourceGroupIdFromName(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, client alibabacloudClient.Client) (string, error) {
 	if machineProviderConfig.ResourceGroup.Name == nil || *machineProviderConfig.ResourceGroup.Name == "" {
 		return "", mapierrors.InvalidMachineConfiguration("No name provided for resource Group ID search for machine: %q", machine.Name)
 	}
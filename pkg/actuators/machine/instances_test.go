@@ -17,16 +17,24 @@ limitations under the License.
 package machine
 
 import (
+	"errors"
 	"fmt"
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/resourcemanager"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 	"github.com/golang/mock/gomock"
 	machinev1 "github.com/openshift/api/machine/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
 	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"net/http"
 	"reflect"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"testing"
 )
 
@@ -44,11 +52,11 @@ func TestRunInstances(t *testing.T) {
 	}
 
 	providerConfig := stubProviderConfig()
-	stubTagList := buildTagList(machine.Name, stubClusterID, providerConfig.Tags)
+	stubTagList := buildTagList(machine, stubClusterID, providerConfig.Tags)
 
 	cases := []struct {
 		name                      string
-		providerConfig            *machinev1.AlibabaCloudMachineProviderConfig
+		providerConfig            *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig
 		securityGroupResponse     *ecs.DescribeSecurityGroupsResponse
 		securityGroupErr          error
 		vswitchesResponse         *vpc.DescribeVSwitchesResponse
@@ -841,7 +849,10 @@ func TestRunInstances(t *testing.T) {
 			mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).Return(tc.runInstancesResponse, tc.runInstancesErr).AnyTimes()
 			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(tc.instancesResponse, tc.instancesErr).AnyTimes()
 
-			_, runErr := runInstances(machine, tc.providerConfig, "", mockAlibabaCloudClient)
+			instanceID, runErr := runInstances(machine, tc.providerConfig, "", new(int32), mockAlibabaCloudClient, record.NewFakeRecorder(10))
+			if runErr == nil {
+				_, runErr = getLaunchedInstance(machine, tc.providerConfig, instanceID, mockAlibabaCloudClient)
+			}
 			t.Log(runErr)
 			if runErr == nil {
 				if !tc.succeeds {
@@ -951,6 +962,8 @@ func TestBuildTagList(t *testing.T) {
 				{Key: clusterOwnedKey, Value: clusterOwnedValue},
 				{Key: machineTagKeyFrom, Value: machineTagValueFrom},
 				{Key: machineIsvIntegrationTagKey, Value: machineTagValueFrom},
+				{Key: machineNamespaceTagKey, Value: "machineNamespace"},
+				{Key: machineUIDTagKey, Value: "machineUID"},
 			},
 		},
 		{
@@ -968,6 +981,24 @@ func TestBuildTagList(t *testing.T) {
 				{Key: clusterOwnedKey, Value: clusterOwnedValue},
 				{Key: machineTagKeyFrom, Value: machineTagValueFrom},
 				{Key: machineIsvIntegrationTagKey, Value: machineTagValueFrom},
+				{Key: machineNamespaceTagKey, Value: "machineNamespace"},
+				{Key: machineUIDTagKey, Value: "machineUID"},
+			},
+		},
+		{
+			name: "should filter out ownership tags from provider spec",
+			machineSpecTags: []machinev1beta1.TagSpecification{
+				{Name: machineNamespaceTagKey, Value: "badnamespace"},
+				{Name: machineUIDTagKey, Value: "baduid"},
+			},
+			expected: []*machinev1.Tag{
+				{Key: "kubernetes.io/cluster/clusterID", Value: "owned"},
+				{Key: "Name", Value: "machineName"},
+				{Key: clusterOwnedKey, Value: clusterOwnedValue},
+				{Key: machineTagKeyFrom, Value: machineTagValueFrom},
+				{Key: machineIsvIntegrationTagKey, Value: machineTagValueFrom},
+				{Key: machineNamespaceTagKey, Value: "machineNamespace"},
+				{Key: machineUIDTagKey, Value: "machineUID"},
 			},
 		},
 		{
@@ -984,6 +1015,8 @@ func TestBuildTagList(t *testing.T) {
 				{Key: clusterOwnedKey, Value: clusterOwnedValue},
 				{Key: machineTagKeyFrom, Value: machineTagValueFrom},
 				{Key: machineIsvIntegrationTagKey, Value: machineTagValueFrom},
+				{Key: machineNamespaceTagKey, Value: "machineNamespace"},
+				{Key: machineUIDTagKey, Value: "machineUID"},
 			},
 		},
 	}
@@ -997,7 +1030,11 @@ func TestBuildTagList(t *testing.T) {
 				})
 			}
 
-			actual := buildTagList("machineName", "clusterID", machineTags)
+			machine := &machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machineName", Namespace: "machineNamespace", UID: "machineUID"},
+			}
+
+			actual := buildTagList(machine, "clusterID", machineTags)
 			if !reflect.DeepEqual(c.expected, actual) {
 				t.Errorf("test #%d: expected %+v, got %+v", i, c.expected, actual)
 			}
@@ -1159,6 +1196,7 @@ func TestCorrectExistingTags(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Unable to build test machine manifest: %v", err)
 	}
+	machine.UID = stubMachineUID
 	clusterID, _ := getClusterID(machine)
 	instance := ecs.Instance{
 		InstanceId: stubInstanceID,
@@ -1166,10 +1204,57 @@ func TestCorrectExistingTags(t *testing.T) {
 	testCases := []struct {
 		name               string
 		tags               []ecs.Tag
+		tagOwnership       bool
 		expectedCreateTags bool
 	}{
 		{
 			name: "Valid Tags",
+			tags: []ecs.Tag{
+				{
+					TagKey:   "kubernetes.io/cluster/" + clusterID,
+					TagValue: "owned",
+				},
+				{
+					TagKey:   "Name",
+					TagValue: machine.Name,
+				},
+				{
+					TagKey:   clusterOwnedKey,
+					TagValue: clusterOwnedValue,
+				},
+				{
+					TagKey:   machineNamespaceTagKey,
+					TagValue: machine.Namespace,
+				},
+				{
+					TagKey:   machineUIDTagKey,
+					TagValue: string(machine.UID),
+				},
+			},
+			tagOwnership:       true,
+			expectedCreateTags: false,
+		},
+		{
+			name: "Missing Machine Namespace And UID Tags",
+			tags: []ecs.Tag{
+				{
+					TagKey:   "kubernetes.io/cluster/" + clusterID,
+					TagValue: "owned",
+				},
+				{
+					TagKey:   "Name",
+					TagValue: machine.Name,
+				},
+				{
+					TagKey:   clusterOwnedKey,
+					TagValue: clusterOwnedValue,
+				},
+			},
+			tagOwnership:       true,
+			expectedCreateTags: true,
+		},
+		{
+			name: "Missing Machine Namespace And UID Tags On Unrecorded Instance",
 			tags: []ecs.Tag{
 				{
 					TagKey:   "kubernetes.io/cluster/" + clusterID,
@@ -1184,6 +1269,7 @@ func TestCorrectExistingTags(t *testing.T) {
 					TagValue: clusterOwnedValue,
 				},
 			},
+			tagOwnership:       false,
 			expectedCreateTags: false,
 		},
 		{
@@ -1198,6 +1284,7 @@ func TestCorrectExistingTags(t *testing.T) {
 					TagValue: "badname",
 				},
 			},
+			tagOwnership:       true,
 			expectedCreateTags: true,
 		},
 		{
@@ -1212,6 +1299,7 @@ func TestCorrectExistingTags(t *testing.T) {
 					TagValue: machine.Name,
 				},
 			},
+			tagOwnership:       true,
 			expectedCreateTags: true,
 		},
 		{
@@ -1226,11 +1314,13 @@ func TestCorrectExistingTags(t *testing.T) {
 					TagValue: "bad name",
 				},
 			},
+			tagOwnership:       true,
 			expectedCreateTags: true,
 		},
 		{
 			name:               "No Tags",
 			tags:               nil,
+			tagOwnership:       true,
 			expectedCreateTags: true,
 		},
 	}
@@ -1244,13 +1334,572 @@ func TestCorrectExistingTags(t *testing.T) {
 			}
 
 			if tc.expectedCreateTags {
-				mockAlibabaCloudClient.EXPECT().TagResources(gomock.Any()).Return(&ecs.TagResourcesResponse{}, nil).MinTimes(1)
+				mockAlibabaCloudClient.EXPECT().TagResources(gomock.Any()).DoAndReturn(func(request *ecs.TagResourcesRequest) (*ecs.TagResourcesResponse, error) {
+					ownershipTags := 0
+					for _, tag := range *request.Tag {
+						if isMachineOwnershipTagKey(tag.Key) {
+							ownershipTags++
+						}
+					}
+					assert.Equal(t, tc.tagOwnership, ownershipTags > 0)
+					return &ecs.TagResourcesResponse{}, nil
+				}).MinTimes(1)
 			}
 
-			err := correctExistingTags(machine, "", &instance, mockAlibabaCloudClient)
+			err := correctExistingTags(machine, "", &instance, tc.tagOwnership, mockAlibabaCloudClient)
 			if err != nil {
 				t.Fatalf("Unexpected error: %v", err)
 			}
 		})
 	}
 }
+
+func TestSetSpotMarketOptions(t *testing.T) {
+	maxPrice := "0.98"
+	invalidPrice := "abc"
+	duration := int64(1)
+	invalidDuration := int64(7)
+
+	cases := []struct {
+		name              string
+		spotMarketOptions *alibabacloudproviderv1.SpotMarketOptions
+		succeeds          bool
+		expectedStrategy  string
+		expectedPrice     string
+		expectedDuration  string
+		expectedBehavior  string
+	}{
+		{
+			name:              "Empty options default to SpotAsPriceGo",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{},
+			succeeds:          true,
+			expectedStrategy:  "SpotAsPriceGo",
+		},
+		{
+			name: "Max price defaults to SpotWithPriceLimit",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				MaxPrice:             &maxPrice,
+				Duration:             &duration,
+				InterruptionBehavior: alibabacloudproviderv1.SpotInterruptionBehaviorStop,
+			},
+			succeeds:         true,
+			expectedStrategy: "SpotWithPriceLimit",
+			expectedPrice:    "0.98",
+			expectedDuration: "1",
+			expectedBehavior: "Stop",
+		},
+		{
+			name: "NoSpot",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				Strategy: alibabacloudproviderv1.NoSpotStrategy,
+				MaxPrice: &maxPrice,
+			},
+			succeeds:         true,
+			expectedStrategy: "NoSpot",
+		},
+		{
+			name: "SpotWithPriceLimit without max price",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				Strategy: alibabacloudproviderv1.SpotWithPriceLimitStrategy,
+			},
+		},
+		{
+			name: "Invalid max price",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				MaxPrice: &invalidPrice,
+			},
+		},
+		{
+			name: "Invalid strategy",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				Strategy: "Spot",
+			},
+		},
+		{
+			name: "Invalid duration",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				Duration: &invalidDuration,
+			},
+		},
+		{
+			name: "Invalid interruption behavior",
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{
+				InterruptionBehavior: "Hibernate",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := ecs.CreateRunInstancesRequest()
+			err := setSpotMarketOptions(request, tc.spotMarketOptions)
+			if !tc.succeeds {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStrategy, request.SpotStrategy)
+			assert.Equal(t, tc.expectedPrice, string(request.SpotPriceLimit))
+			assert.Equal(t, tc.expectedDuration, string(request.SpotDuration))
+			assert.Equal(t, tc.expectedBehavior, request.SpotInterruptionBehavior)
+		})
+	}
+}
+
+func TestIsSpotInstance(t *testing.T) {
+	assert.True(t, isSpotInstance(&ecs.Instance{SpotStrategy: "SpotWithPriceLimit"}))
+	assert.True(t, isSpotInstance(&ecs.Instance{SpotStrategy: "SpotAsPriceGo"}))
+	assert.False(t, isSpotInstance(&ecs.Instance{SpotStrategy: "NoSpot"}))
+	assert.False(t, isSpotInstance(&ecs.Instance{}))
+}
+
+func TestSetInstanceChargeType(t *testing.T) {
+	cases := []struct {
+		name                    string
+		instanceChargeType      alibabacloudproviderv1.InstanceChargeType
+		subscriptionOptions     *alibabacloudproviderv1.SubscriptionOptions
+		spotMarketOptions       *alibabacloudproviderv1.SpotMarketOptions
+		succeeds                bool
+		expectedChargeType      string
+		expectedPeriod          string
+		expectedPeriodUnit      string
+		expectedAutoRenew       string
+		expectedAutoRenewPeriod string
+	}{
+		{
+			name:     "Empty instance charge type",
+			succeeds: true,
+		},
+		{
+			name:               "PostPaid",
+			instanceChargeType: alibabacloudproviderv1.PostPaidInstanceChargeType,
+			succeeds:           true,
+			expectedChargeType: "PostPaid",
+		},
+		{
+			name:               "PrePaid with default period unit",
+			instanceChargeType: alibabacloudproviderv1.PrePaidInstanceChargeType,
+			subscriptionOptions: &alibabacloudproviderv1.SubscriptionOptions{
+				Period: 12,
+			},
+			succeeds:           true,
+			expectedChargeType: "PrePaid",
+			expectedPeriod:     "12",
+			expectedPeriodUnit: "Month",
+		},
+		{
+			name:               "PrePaid with auto renew",
+			instanceChargeType: alibabacloudproviderv1.PrePaidInstanceChargeType,
+			subscriptionOptions: &alibabacloudproviderv1.SubscriptionOptions{
+				Period:          2,
+				PeriodUnit:      alibabacloudproviderv1.WeekPeriodUnit,
+				AutoRenew:       true,
+				AutoRenewPeriod: 1,
+			},
+			succeeds:                true,
+			expectedChargeType:      "PrePaid",
+			expectedPeriod:          "2",
+			expectedPeriodUnit:      "Week",
+			expectedAutoRenew:       "true",
+			expectedAutoRenewPeriod: "1",
+		},
+		{
+			name:               "PrePaid without subscription options",
+			instanceChargeType: alibabacloudproviderv1.PrePaidInstanceChargeType,
+		},
+		{
+			name:               "PrePaid with invalid period",
+			instanceChargeType: alibabacloudproviderv1.PrePaidInstanceChargeType,
+			subscriptionOptions: &alibabacloudproviderv1.SubscriptionOptions{
+				Period:     5,
+				PeriodUnit: alibabacloudproviderv1.WeekPeriodUnit,
+			},
+		},
+		{
+			name:               "PrePaid with invalid auto renew period",
+			instanceChargeType: alibabacloudproviderv1.PrePaidInstanceChargeType,
+			subscriptionOptions: &alibabacloudproviderv1.SubscriptionOptions{
+				Period:    1,
+				AutoRenew: true,
+			},
+		},
+		{
+			name:               "PrePaid spot instance",
+			instanceChargeType: alibabacloudproviderv1.PrePaidInstanceChargeType,
+			subscriptionOptions: &alibabacloudproviderv1.SubscriptionOptions{
+				Period: 1,
+			},
+			spotMarketOptions: &alibabacloudproviderv1.SpotMarketOptions{},
+		},
+		{
+			name:               "Invalid instance charge type",
+			instanceChargeType: "Subscription",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerConfig := stubProviderConfig()
+			providerConfig.InstanceChargeType = tc.instanceChargeType
+			providerConfig.SubscriptionOptions = tc.subscriptionOptions
+			providerConfig.SpotMarketOptions = tc.spotMarketOptions
+
+			request := ecs.CreateRunInstancesRequest()
+			err := setInstanceChargeType(request, providerConfig)
+			if !tc.succeeds {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedChargeType, request.InstanceChargeType)
+			assert.Equal(t, tc.expectedPeriod, string(request.Period))
+			assert.Equal(t, tc.expectedPeriodUnit, request.PeriodUnit)
+			assert.Equal(t, tc.expectedAutoRenew, string(request.AutoRenew))
+			assert.Equal(t, tc.expectedAutoRenewPeriod, string(request.AutoRenewPeriod))
+		})
+	}
+}
+
+func TestConvertSubscriptionInstances(t *testing.T) {
+	cases := []struct {
+		name          string
+		instances     []*ecs.Instance
+		expectConvert bool
+		convertErr    error
+		succeeds      bool
+	}{
+		{
+			name: "No subscription instances",
+			instances: []*ecs.Instance{
+				{InstanceId: "i-postpaid", InstanceChargeType: "PostPaid"},
+			},
+			succeeds: true,
+		},
+		{
+			name: "Subscription instance is converted",
+			instances: []*ecs.Instance{
+				{InstanceId: "i-postpaid", InstanceChargeType: "PostPaid"},
+				{InstanceId: "i-prepaid", InstanceChargeType: "PrePaid"},
+			},
+			expectConvert: true,
+			succeeds:      true,
+		},
+		{
+			name: "Expired subscription instance is released as it is",
+			instances: []*ecs.Instance{
+				{InstanceId: "i-expired", InstanceChargeType: "PrePaid", Status: ECSInstanceStatusExpired},
+			},
+			succeeds: true,
+		},
+		{
+			name: "Conversion fails",
+			instances: []*ecs.Instance{
+				{InstanceId: "i-prepaid", InstanceChargeType: "PrePaid"},
+			},
+			expectConvert: true,
+			convertErr:    fmt.Errorf("error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+
+			if tc.expectConvert {
+				mockAlibabaCloudClient.EXPECT().ModifyInstanceChargeType(gomock.Any()).DoAndReturn(
+					func(request *ecs.ModifyInstanceChargeTypeRequest) (*ecs.ModifyInstanceChargeTypeResponse, error) {
+						assert.Equal(t, `["i-prepaid"]`, request.InstanceIds)
+						assert.Equal(t, "PostPaid", request.InstanceChargeType)
+						return &ecs.ModifyInstanceChargeTypeResponse{}, tc.convertErr
+					}).Times(1)
+			}
+
+			err := convertSubscriptionInstances(mockAlibabaCloudClient, "cn-beijing", tc.instances)
+			assert.Equal(t, tc.succeeds, err == nil)
+		})
+	}
+}
+
+func TestValidateKeyPair(t *testing.T) {
+	cases := []struct {
+		name        string
+		response    *ecs.DescribeKeyPairsResponse
+		describeErr error
+		succeeds    bool
+	}{
+		{
+			name: "Key pair exists",
+			response: &ecs.DescribeKeyPairsResponse{
+				KeyPairs: ecs.KeyPairs{
+					KeyPair: []ecs.KeyPair{{KeyPairName: "break-glass"}},
+				},
+			},
+			succeeds: true,
+		},
+		{
+			name: "Only a similarly named key pair exists",
+			response: &ecs.DescribeKeyPairsResponse{
+				KeyPairs: ecs.KeyPairs{
+					KeyPair: []ecs.KeyPair{{KeyPairName: "break-glass-old"}},
+				},
+			},
+		},
+		{
+			name:     "Key pair not found",
+			response: &ecs.DescribeKeyPairsResponse{},
+		},
+		{
+			name:        "Describe key pairs fails",
+			describeErr: fmt.Errorf("error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeKeyPairs(gomock.Any()).DoAndReturn(
+				func(request *ecs.DescribeKeyPairsRequest) (*ecs.DescribeKeyPairsResponse, error) {
+					assert.Equal(t, "break-glass", request.KeyPairName)
+					assert.Equal(t, stubRegionID, request.RegionId)
+					return tc.response, tc.describeErr
+				}).Times(1)
+
+			providerConfig := stubProviderConfig()
+			providerConfig.KeyPairName = "break-glass"
+
+			machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+			err := validateKeyPair(machineKey, providerConfig, mockAlibabaCloudClient)
+			assert.Equal(t, tc.succeeds, err == nil)
+			if tc.response != nil && !tc.succeeds {
+				machineErr, ok := err.(*machinecontroller.MachineError)
+				if assert.True(t, ok, "expected a machine error, got %v", err) {
+					assert.Equal(t, machinev1beta1.InvalidConfigurationMachineError, machineErr.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestSetIPv6Options(t *testing.T) {
+	cases := []struct {
+		name          string
+		options       *alibabacloudproviderv1.IPv6Options
+		vSwitchID     string
+		ipv6CidrBlock string
+		expectedCount string
+		succeeds      bool
+	}{
+		{
+			name:          "Default IPv6 address count",
+			options:       &alibabacloudproviderv1.IPv6Options{},
+			vSwitchID:     stubVSwitchID,
+			ipv6CidrBlock: "2408:4005:3a8:1e00::/64",
+			expectedCount: "1",
+			succeeds:      true,
+		},
+		{
+			name:          "IPv6 primary IP family",
+			options:       &alibabacloudproviderv1.IPv6Options{AddressCount: 2, PrimaryIPFamily: alibabacloudproviderv1.IPv6IPFamily},
+			vSwitchID:     stubVSwitchID,
+			ipv6CidrBlock: "2408:4005:3a8:1e00::/64",
+			expectedCount: "2",
+			succeeds:      true,
+		},
+		{
+			name:      "VSwitch without IPv6 CIDR block",
+			options:   &alibabacloudproviderv1.IPv6Options{},
+			vSwitchID: stubVSwitchID,
+		},
+		{
+			name:          "VSwitch from the launch template",
+			options:       &alibabacloudproviderv1.IPv6Options{AddressCount: 1},
+			expectedCount: "1",
+			succeeds:      true,
+		},
+		{
+			name:    "Invalid IPv6 address count",
+			options: &alibabacloudproviderv1.IPv6Options{AddressCount: -1},
+		},
+		{
+			name:    "Invalid primary IP family",
+			options: &alibabacloudproviderv1.IPv6Options{PrimaryIPFamily: "IPv5"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			if tc.vSwitchID != "" {
+				mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).DoAndReturn(
+					func(request *vpc.DescribeVSwitchesRequest) (*vpc.DescribeVSwitchesResponse, error) {
+						assert.Equal(t, tc.vSwitchID, request.VSwitchId)
+						assert.Equal(t, stubRegionID, request.RegionId)
+						return &vpc.DescribeVSwitchesResponse{
+							VSwitches: vpc.VSwitches{
+								VSwitch: []vpc.VSwitch{{VSwitchId: tc.vSwitchID, Ipv6CidrBlock: tc.ipv6CidrBlock}},
+							},
+						}, nil
+					}).Times(1)
+			}
+
+			providerConfig := stubProviderConfig()
+			providerConfig.IPv6 = tc.options
+
+			request := ecs.CreateRunInstancesRequest()
+			machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+			err := setIPv6Options(machineKey, request, providerConfig, tc.vSwitchID, mockAlibabaCloudClient)
+			assert.Equal(t, tc.succeeds, err == nil)
+			if tc.succeeds {
+				assert.Equal(t, tc.expectedCount, string(request.Ipv6AddressCount))
+			} else {
+				machineErr, ok := err.(*machinecontroller.MachineError)
+				if assert.True(t, ok, "expected a machine error, got %v", err) {
+					assert.Equal(t, machinev1beta1.InvalidConfigurationMachineError, machineErr.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractNodeAddressesFromInstanceOrder(t *testing.T) {
+	instance := &ecs.Instance{
+		InstanceId: "i-bp1dualstack",
+		NetworkInterfaces: ecs.NetworkInterfacesInDescribeInstances{
+			NetworkInterface: []ecs.NetworkInterface{
+				{
+					Type: ECSNetworkInterfaceTypeSecondary,
+					PrivateIpSets: ecs.PrivateIpSetsInDescribeInstances{
+						PrivateIpSet: []ecs.PrivateIpSet{{PrivateIpAddress: "172.16.0.1"}},
+					},
+				},
+				{
+					Type: "Primary",
+					PrivateIpSets: ecs.PrivateIpSetsInDescribeInstances{
+						PrivateIpSet: []ecs.PrivateIpSet{{PrivateIpAddress: "10.0.0.1"}},
+					},
+					Ipv6Sets: ecs.Ipv6SetsInDescribeInstances{
+						Ipv6Set: []ecs.Ipv6Set{{Ipv6Address: "2408:4005:3a8:1e00::1"}},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name            string
+		options         *alibabacloudproviderv1.IPv6Options
+		expectedFirstIP string
+	}{
+		{
+			name:            "No IPv6 options",
+			expectedFirstIP: "10.0.0.1",
+		},
+		{
+			name:            "Default primary IP family",
+			options:         &alibabacloudproviderv1.IPv6Options{},
+			expectedFirstIP: "10.0.0.1",
+		},
+		{
+			name:            "IPv4 primary IP family",
+			options:         &alibabacloudproviderv1.IPv6Options{PrimaryIPFamily: alibabacloudproviderv1.IPv4IPFamily},
+			expectedFirstIP: "10.0.0.1",
+		},
+		{
+			name:            "IPv6 primary IP family",
+			options:         &alibabacloudproviderv1.IPv6Options{PrimaryIPFamily: alibabacloudproviderv1.IPv6IPFamily},
+			expectedFirstIP: "2408:4005:3a8:1e00::1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerConfig := stubProviderConfig()
+			providerConfig.IPv6 = tc.options
+
+			addresses, err := extractNodeAddressesFromInstance(instance, isIPv6Primary(providerConfig))
+			assert.NoError(t, err)
+			if assert.Len(t, addresses, 2) {
+				assert.Equal(t, tc.expectedFirstIP, addresses[0].Address)
+			}
+		})
+	}
+}
+
+func TestRunInstancesClientToken(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.UID = stubMachineUID
+
+	noStockErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "OperationDenied.NoStock"}`, "")
+	timeoutErr := errors.New("read: connection timed out")
+
+	cases := []struct {
+		name                   string
+		launchAttempts         int32
+		fallbackInstanceTypes  []string
+		errs                   []error
+		expectedTokens         []string
+		expectedLaunchAttempts int32
+	}{
+		{
+			name:                   "First launch attempt",
+			errs:                   []error{nil},
+			expectedTokens:         []string{stubMachineUID},
+			expectedLaunchAttempts: 1,
+		},
+		{
+			name:                   "Fallback attempts use their own client token",
+			launchAttempts:         2,
+			fallbackInstanceTypes:  []string{"ecs.g6.2xlarge"},
+			errs:                   []error{noStockErr, nil},
+			expectedTokens:         []string{stubMachineUID + "-2", stubMachineUID + "-3"},
+			expectedLaunchAttempts: 4,
+		},
+		{
+			name:                   "Client token kept when the outcome is unknown",
+			launchAttempts:         1,
+			errs:                   []error{timeoutErr},
+			expectedTokens:         []string{stubMachineUID + "-1"},
+			expectedLaunchAttempts: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubDescribeImagesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().ListResourceGroups(gomock.Any()).Return(stubListResourceGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeSecurityGroups(gomock.Any()).Return(stubDescribeSecurityGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).Return(stubDescribeVSwitchesResponse(), nil).AnyTimes()
+
+			tokens := make([]string, 0)
+			mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error) {
+					err := tc.errs[len(tokens)]
+					tokens = append(tokens, request.ClientToken)
+					if err != nil {
+						return nil, err
+					}
+					return stubRunInstancesResponse(), nil
+				}).Times(len(tc.errs))
+
+			providerConfig := stubProviderConfig()
+			providerConfig.FallbackInstanceTypes = tc.fallbackInstanceTypes
+
+			// The instance ID is returned without waiting for the instance to be Running
+			launchAttempts := tc.launchAttempts
+			instanceID, err := runInstances(machine, providerConfig, "", &launchAttempts, mockAlibabaCloudClient, record.NewFakeRecorder(10))
+			if tc.errs[len(tc.errs)-1] == nil && assert.NoError(t, err) {
+				assert.Equal(t, stubInstanceID, instanceID)
+			}
+			assert.Equal(t, tc.expectedTokens, tokens)
+			assert.Equal(t, tc.expectedLaunchAttempts, launchAttempts)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setLaunchTemplate sets the launch template referenced in the provider spec on the RunInstances request
+func setLaunchTemplate(request *ecs.RunInstancesRequest, launchTemplate *alibabacloudproviderv1.LaunchTemplateReference) error {
+	if err := validateLaunchTemplateReference(launchTemplate); err != nil {
+		return err
+	}
+
+	request.LaunchTemplateId = launchTemplate.ID
+	request.LaunchTemplateName = launchTemplate.Name
+	if launchTemplate.Version != nil {
+		request.LaunchTemplateVersion = requests.NewInteger64(*launchTemplate.Version)
+	}
+
+	return nil
+}
+
+func validateLaunchTemplateReference(launchTemplate *alibabacloudproviderv1.LaunchTemplateReference) error {
+	if (launchTemplate.ID == "") == (launchTemplate.Name == "") {
+		return mapierrors.InvalidMachineConfiguration("exactly one of launchTemplate id and name must be set")
+	}
+
+	if launchTemplate.Version != nil && *launchTemplate.Version < 1 {
+		return mapierrors.InvalidMachineConfiguration("invalid launch template version: %d", *launchTemplate.Version)
+	}
+
+	return nil
+}
+
+// resolveLaunchTemplate returns the ID and the version of the referenced launch template.
+// The default version of the launch template is returned when the reference has no version.
+func resolveLaunchTemplate(client alibabacloudClient.Client, regionID string, launchTemplate *alibabacloudproviderv1.LaunchTemplateReference) (string, int64, error) {
+	if err := validateLaunchTemplateReference(launchTemplate); err != nil {
+		return "", 0, err
+	}
+
+	request := ecs.CreateDescribeLaunchTemplatesRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	if launchTemplate.ID != "" {
+		request.LaunchTemplateId = &[]string{launchTemplate.ID}
+	} else {
+		request.LaunchTemplateName = &[]string{launchTemplate.Name}
+	}
+
+	response, err := client.DescribeLaunchTemplates(request)
+	if err != nil {
+		klog.Errorf("error describing launch templates: %v", err)
+		return "", 0, fmt.Errorf("error describing launch templates: %v", err)
+	}
+
+	if len(response.LaunchTemplateSets.LaunchTemplateSet) != 1 {
+		return "", 0, mapierrors.InvalidMachineConfiguration("launch template %s%s not found", launchTemplate.ID, launchTemplate.Name)
+	}
+
+	template := response.LaunchTemplateSets.LaunchTemplateSet[0]
+	if launchTemplate.Version != nil {
+		if *launchTemplate.Version > template.LatestVersionNumber {
+			return "", 0, mapierrors.InvalidMachineConfiguration("launch template %s has no version %d", template.LaunchTemplateId, *launchTemplate.Version)
+		}
+		return template.LaunchTemplateId, *launchTemplate.Version, nil
+	}
+
+	return template.LaunchTemplateId, template.DefaultVersionNumber, nil
+}
+
+// pinLaunchTemplate resolves the launch template referenced in the provider spec and records it in the provider status.
+// The provider spec is updated to reference the resolved version, so that the instance is created from the version
+// recorded in the provider status even if the default version changes in the meantime.
+func (r *Reconciler) pinLaunchTemplate(providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	if providerSpec.LaunchTemplate == nil {
+		r.providerStatus.LaunchTemplateID = nil
+		r.providerStatus.LaunchTemplateVersion = nil
+		return nil
+	}
+
+	launchTemplateID, version, err := resolveLaunchTemplate(r.alibabacloudClient, providerSpec.RegionID, providerSpec.LaunchTemplate)
+	if err != nil {
+		return err
+	}
+	klog.Infof("%s: using launch template %s version %d", r.machine.Name, launchTemplateID, version)
+
+	providerSpec.LaunchTemplate = &alibabacloudproviderv1.LaunchTemplateReference{
+		ID:      launchTemplateID,
+		Version: &version,
+	}
+
+	r.providerStatus.LaunchTemplateID = &launchTemplateID
+	r.providerStatus.LaunchTemplateVersion = &version
+
+	return nil
+}
+
+// reconcileLaunchTemplateDrift compares the launch template version the instance was created from with the version
+// currently referenced by the MachineSet owning the Machine, or by the Machine itself, and reports the result in the
+// LaunchTemplateDrift condition.
+func (r *Reconciler) reconcileLaunchTemplateDrift() error {
+	desired, err := r.getDesiredLaunchTemplate()
+	if err != nil {
+		return err
+	}
+
+	if desired == nil && r.providerStatus.LaunchTemplateID == nil {
+		// Launch templates are not used by this Machine
+		return nil
+	}
+
+	condition := metav1.Condition{
+		Type:    string(alibabacloudproviderv1.LaunchTemplateDrift),
+		Status:  metav1.ConditionFalse,
+		Reason:  alibabacloudproviderv1.LaunchTemplateUpToDateConditionReason,
+		Message: "Instance was created from the referenced launch template version",
+	}
+
+	switch {
+	case desired == nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = alibabacloudproviderv1.LaunchTemplateVersionChangedConditionReason
+		condition.Message = fmt.Sprintf("Instance was created from launch template %s version %d, which is no longer referenced",
+			*r.providerStatus.LaunchTemplateID, *r.providerStatus.LaunchTemplateVersion)
+	case r.providerStatus.LaunchTemplateID == nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = alibabacloudproviderv1.LaunchTemplateVersionChangedConditionReason
+		condition.Message = fmt.Sprintf("Instance was not created from launch template %s version %d", desired.ID, *desired.Version)
+	case *r.providerStatus.LaunchTemplateID != desired.ID || *r.providerStatus.LaunchTemplateVersion != *desired.Version:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = alibabacloudproviderv1.LaunchTemplateVersionChangedConditionReason
+		condition.Message = fmt.Sprintf("Instance was created from launch template %s version %d, but version %d of launch template %s is referenced",
+			*r.providerStatus.LaunchTemplateID, *r.providerStatus.LaunchTemplateVersion, *desired.Version, desired.ID)
+	}
+
+	if condition.Status == metav1.ConditionTrue {
+		klog.Infof("%s: launch template drift detected: %s", r.machine.Name, condition.Message)
+	}
+	r.providerStatus.Conditions = setMachineProviderCondition(condition, r.providerStatus.Conditions)
+
+	return nil
+}
+
+// getDesiredLaunchTemplate returns the resolved launch template referenced by the MachineSet owning the Machine.
+// Machines which are not owned by a MachineSet use the launch template referenced in their own provider spec.
+func (r *Reconciler) getDesiredLaunchTemplate() (*alibabacloudproviderv1.LaunchTemplateReference, error) {
+	providerSpec := r.providerSpec
+
+	if owner := metav1.GetControllerOf(r.machine); owner != nil && owner.Kind == machineSetKind {
+		machineSet := &machinev1beta1.MachineSet{}
+		key := runtimeclient.ObjectKey{Namespace: r.machine.Namespace, Name: owner.Name}
+		if err := r.client.Get(r.Context, key, machineSet); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("error getting machineset %s: %w", owner.Name, err)
+		} else if err == nil {
+			providerSpec, err = alibabacloudproviderv1.ProviderSpecFromRawExtension(machineSet.Spec.Template.Spec.ProviderSpec.Value)
+			if err != nil {
+				return nil, fmt.Errorf("error getting provider spec of machineset %s: %w", owner.Name, err)
+			}
+		}
+	}
+
+	if providerSpec.LaunchTemplate == nil {
+		return nil, nil
+	}
+
+	launchTemplateID, version, err := resolveLaunchTemplate(r.alibabacloudClient, r.providerSpec.RegionID, providerSpec.LaunchTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &alibabacloudproviderv1.LaunchTemplateReference{
+		ID:      launchTemplateID,
+		Version: &version,
+	}, nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubectl/pkg/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	stubLaunchTemplateID   = "lt-bp1apo0bbbkuy4rdhqmh"
+	stubLaunchTemplateName = "hardened-workers"
+)
+
+func stubDescribeLaunchTemplatesResponse(defaultVersion, latestVersion int64) *ecs.DescribeLaunchTemplatesResponse {
+	return &ecs.DescribeLaunchTemplatesResponse{
+		LaunchTemplateSets: ecs.LaunchTemplateSets{
+			LaunchTemplateSet: []ecs.LaunchTemplateSet{
+				{
+					LaunchTemplateId:     stubLaunchTemplateID,
+					LaunchTemplateName:   stubLaunchTemplateName,
+					DefaultVersionNumber: defaultVersion,
+					LatestVersionNumber:  latestVersion,
+				},
+			},
+		},
+	}
+}
+
+func TestSetLaunchTemplate(t *testing.T) {
+	cases := []struct {
+		name            string
+		launchTemplate  *alibabacloudproviderv1.LaunchTemplateReference
+		expectedID      string
+		expectedName    string
+		expectedVersion string
+		expectError     bool
+	}{
+		{
+			name:           "By ID",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID},
+			expectedID:     stubLaunchTemplateID,
+		},
+		{
+			name:            "By name and version",
+			launchTemplate:  &alibabacloudproviderv1.LaunchTemplateReference{Name: stubLaunchTemplateName, Version: pointer.Int64Ptr(3)},
+			expectedName:    stubLaunchTemplateName,
+			expectedVersion: "3",
+		},
+		{
+			name:           "Both ID and name",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Name: stubLaunchTemplateName},
+			expectError:    true,
+		},
+		{
+			name:           "Neither ID nor name",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{},
+			expectError:    true,
+		},
+		{
+			name:           "Invalid version",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(0)},
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := ecs.CreateRunInstancesRequest()
+			err := setLaunchTemplate(request, tc.launchTemplate)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedID, request.LaunchTemplateId)
+			assert.Equal(t, tc.expectedName, request.LaunchTemplateName)
+			assert.Equal(t, tc.expectedVersion, string(request.LaunchTemplateVersion))
+		})
+	}
+}
+
+func TestResolveLaunchTemplate(t *testing.T) {
+	cases := []struct {
+		name            string
+		launchTemplate  *alibabacloudproviderv1.LaunchTemplateReference
+		response        *ecs.DescribeLaunchTemplatesResponse
+		expectedVersion int64
+		expectError     bool
+	}{
+		{
+			name:            "Default version",
+			launchTemplate:  &alibabacloudproviderv1.LaunchTemplateReference{Name: stubLaunchTemplateName},
+			response:        stubDescribeLaunchTemplatesResponse(2, 4),
+			expectedVersion: 2,
+		},
+		{
+			name:            "Explicit version",
+			launchTemplate:  &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(4)},
+			response:        stubDescribeLaunchTemplatesResponse(2, 4),
+			expectedVersion: 4,
+		},
+		{
+			name:           "Unknown version",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(5)},
+			response:       stubDescribeLaunchTemplatesResponse(2, 4),
+			expectError:    true,
+		},
+		{
+			name:           "Launch template not found",
+			launchTemplate: &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID},
+			response:       &ecs.DescribeLaunchTemplatesResponse{},
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeLaunchTemplates(gomock.Any()).Return(tc.response, nil).Times(1)
+
+			id, version, err := resolveLaunchTemplate(mockAlibabaCloudClient, stubRegionID, tc.launchTemplate)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, stubLaunchTemplateID, id)
+			assert.Equal(t, tc.expectedVersion, version)
+		})
+	}
+}
+
+func TestReconcileLaunchTemplateDrift(t *testing.T) {
+	cases := []struct {
+		name                    string
+		machineSetTemplate      *alibabacloudproviderv1.LaunchTemplateReference
+		machineTemplate         *alibabacloudproviderv1.LaunchTemplateReference
+		statusVersion           *int64
+		defaultVersion          int64
+		expectedConditionStatus metav1.ConditionStatus
+	}{
+		{
+			name: "No launch template",
+		},
+		{
+			name:                    "Up to date",
+			machineSetTemplate:      &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(2)},
+			machineTemplate:         &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(2)},
+			statusVersion:           pointer.Int64Ptr(2),
+			defaultVersion:          2,
+			expectedConditionStatus: metav1.ConditionFalse,
+		},
+		{
+			name:                    "MachineSet references a new version",
+			machineSetTemplate:      &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(3)},
+			machineTemplate:         &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID, Version: pointer.Int64Ptr(2)},
+			statusVersion:           pointer.Int64Ptr(2),
+			defaultVersion:          2,
+			expectedConditionStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                    "Default version changed",
+			machineSetTemplate:      &alibabacloudproviderv1.LaunchTemplateReference{Name: stubLaunchTemplateName},
+			machineTemplate:         &alibabacloudproviderv1.LaunchTemplateReference{Name: stubLaunchTemplateName},
+			statusVersion:           pointer.Int64Ptr(2),
+			defaultVersion:          3,
+			expectedConditionStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                    "Launch template added to the MachineSet",
+			machineSetTemplate:      &alibabacloudproviderv1.LaunchTemplateReference{ID: stubLaunchTemplateID},
+			defaultVersion:          2,
+			expectedConditionStatus: metav1.ConditionTrue,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeLaunchTemplates(gomock.Any()).Return(stubDescribeLaunchTemplatesResponse(tc.defaultVersion, 3), nil).AnyTimes()
+
+			machineSetProviderSpec := stubProviderConfig()
+			machineSetProviderSpec.LaunchTemplate = tc.machineSetTemplate
+			rawProviderSpec, err := alibabacloudproviderv1.RawExtensionFromProviderSpec(machineSetProviderSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			machineSet := &machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      stubMachineSetName,
+					Namespace: defaultNamespace,
+				},
+				Spec: machinev1beta1.MachineSetSpec{
+					Template: machinev1beta1.MachineTemplateSpec{
+						Spec: machinev1beta1.MachineSpec{
+							ProviderSpec: machinev1beta1.ProviderSpec{Value: rawProviderSpec},
+						},
+					},
+				},
+			}
+
+			machine, err := stubWorkerMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine.OwnerReferences = []metav1.OwnerReference{
+				{
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					Kind:       machineSetKind,
+					Name:       stubMachineSetName,
+					Controller: pointer.BoolPtr(true),
+				},
+			}
+
+			providerSpec := stubProviderConfig()
+			providerSpec.LaunchTemplate = tc.machineTemplate
+			providerStatus := &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{}
+			if tc.statusVersion != nil {
+				providerStatus.LaunchTemplateID = pointer.StringPtr(stubLaunchTemplateID)
+				providerStatus.LaunchTemplateVersion = tc.statusVersion
+			}
+
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				client:             fake.NewFakeClientWithScheme(scheme.Scheme, machineSet),
+				machine:            machine,
+				providerSpec:       providerSpec,
+				providerStatus:     providerStatus,
+			})
+
+			assert.NoError(t, r.reconcileLaunchTemplateDrift())
+
+			condition := findProviderCondition(r.providerStatus.Conditions, alibabacloudproviderv1.LaunchTemplateDrift)
+			if tc.expectedConditionStatus == "" {
+				assert.Nil(t, condition)
+				return
+			}
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedConditionStatus, condition.Status)
+			}
+		})
+	}
+}
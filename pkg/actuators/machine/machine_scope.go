@@ -27,12 +27,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog"
 
-	machinev1 "github.com/openshift/api/machine/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	alibabav1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
 	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
 	machineapierros "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -44,11 +44,13 @@ type machineScope struct {
 	alibabacloudClient alibabacloudClient.Client
 	// api server controller runtime client
 	client runtimeclient.Client
+	// event recorder for the machine resource
+	eventRecorder record.EventRecorder
 	// machine resource
 	machine            *machinev1beta1.Machine
 	machineToBePatched runtimeclient.Patch
-	providerSpec       *machinev1.AlibabaCloudMachineProviderConfig
-	providerStatus     *machinev1.AlibabaCloudMachineProviderStatus
+	providerSpec       *alibabav1.AlibabaCloudMachineProviderConfig
+	providerStatus     *alibabav1.AlibabaCloudMachineProviderStatus
 }
 
 // machineScopeParams defines the input parameters used to create a new MachineScope.
@@ -58,6 +60,8 @@ type machineScopeParams struct {
 	alibabacloudClientBuilder alibabacloudClient.AlibabaCloudClientBuilderFunc
 	// api server controller runtime client
 	client runtimeclient.Client
+	// event recorder for the machine resource
+	eventRecorder record.EventRecorder
 	// machine resource
 	machine *machinev1beta1.Machine
 	// api server controller runtime client for the openshift-config-managed namespace
@@ -90,6 +94,7 @@ func newMachineScope(params machineScopeParams) (*machineScope, error) {
 		Context:            params.Context,
 		alibabacloudClient: aliClient,
 		client:             params.client,
+		eventRecorder:      params.eventRecorder,
 		machine:            params.machine,
 		machineToBePatched: runtimeclient.MergeFrom(params.machine.DeepCopy()),
 		providerSpec:       providerSpec,
@@ -159,9 +164,45 @@ func (s *machineScope) setProviderStatus(instance *ecs.Instance, condition metav
 	if instance == nil {
 		s.providerStatus.InstanceID = nil
 		s.providerStatus.InstanceState = nil
+		s.providerStatus.SpotStrategy = nil
+		s.providerStatus.DedicatedHostID = nil
+		s.providerStatus.DedicatedHostClusterID = nil
+		s.providerStatus.LaunchTemplateID = nil
+		s.providerStatus.LaunchTemplateVersion = nil
+		s.providerStatus.PrivatePoolID = nil
+		s.providerStatus.VSwitchID = nil
+		s.providerStatus.ZoneID = nil
+		s.providerStatus.InstanceType = nil
 	} else {
 		s.providerStatus.InstanceID = &instance.InstanceId
 		s.providerStatus.InstanceState = &instance.Status
+		if instance.ImageId != "" {
+			s.providerStatus.ImageID = &instance.ImageId
+		}
+		if instance.SpotStrategy != "" {
+			spotStrategy := alibabav1.SpotStrategy(instance.SpotStrategy)
+			s.providerStatus.SpotStrategy = &spotStrategy
+		}
+		if instance.DedicatedHostAttribute.DedicatedHostId != "" {
+			s.providerStatus.DedicatedHostID = &instance.DedicatedHostAttribute.DedicatedHostId
+		}
+		if instance.DedicatedHostAttribute.DedicatedHostClusterId != "" {
+			s.providerStatus.DedicatedHostClusterID = &instance.DedicatedHostAttribute.DedicatedHostClusterId
+		}
+		if instance.PrivatePoolOptionsId != "" {
+			s.providerStatus.PrivatePoolID = &instance.PrivatePoolOptionsId
+		} else {
+			s.providerStatus.PrivatePoolID = nil
+		}
+		if instance.VpcAttributes.VSwitchId != "" {
+			s.providerStatus.VSwitchID = &instance.VpcAttributes.VSwitchId
+		}
+		if instance.ZoneId != "" {
+			s.providerStatus.ZoneID = &instance.ZoneId
+		}
+		if instance.InstanceType != "" {
+			s.providerStatus.InstanceType = &instance.InstanceType
+		}
 	}
 
 	networkAddresses, err := s.getNetworkAddress(instance)
@@ -180,7 +221,7 @@ func (s *machineScope) getNetworkAddress(instance *ecs.Instance) ([]corev1.NodeA
 
 	networkAddresses := make([]corev1.NodeAddress, 0)
 
-	addresses, err := extractNodeAddressesFromInstance(instance)
+	addresses, err := extractNodeAddressesFromInstance(instance, isIPv6Primary(s.providerSpec))
 	if err != nil {
 		klog.Errorf("%s: Error extracting instance IP addresses: %v", s.machine.Name, err)
 		return nil, err
@@ -198,17 +239,26 @@ func (s *machineScope) getNetworkAddress(instance *ecs.Instance) ([]corev1.NodeA
 	return networkAddresses, nil
 }
 
-// extractNodeAddressesFromInstance maps the instance information from ECS to an array of NodeAddresses
-func extractNodeAddressesFromInstance(instance *ecs.Instance) ([]corev1.NodeAddress, error) {
+// extractNodeAddressesFromInstance maps the instance information from ECS to an array of NodeAddresses.
+// The internal addresses of the primary IP family are listed first.
+func extractNodeAddressesFromInstance(instance *ecs.Instance, ipv6Primary bool) ([]corev1.NodeAddress, error) {
 
 	if instance == nil {
 		return nil, fmt.Errorf("the ecs instance is nil")
 	}
 
 	addresses := make([]corev1.NodeAddress, 0)
+	ipv4Addresses := make([]corev1.NodeAddress, 0)
+	ipv6Addresses := make([]corev1.NodeAddress, 0)
 
 	// handle internal network interfaces
 	for _, networkInterface := range instance.NetworkInterfaces.NetworkInterface {
+		// The addresses of the secondary network interfaces are recorded in the provider status,
+		// only the primary network interface holds the node IPs
+		if networkInterface.Type == ECSNetworkInterfaceTypeSecondary {
+			continue
+		}
+
 		// https://github.com/openshift-kni/origin/commit/7db21c1e26a344e25ae1b825d4f21e7bef5c3650
 		for _, ipv6Address := range networkInterface.Ipv6Sets.Ipv6Set {
 			if addr := ipv6Address.Ipv6Address; addr != "" {
@@ -216,7 +266,7 @@ func extractNodeAddressesFromInstance(instance *ecs.Instance) ([]corev1.NodeAddr
 				if ip == nil {
 					return nil, fmt.Errorf("ECS instance had invalid IPv6 address: %s (%q)", instance.InstanceId, addr)
 				}
-				addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip.String()})
+				ipv6Addresses = append(ipv6Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip.String()})
 			}
 		}
 
@@ -226,11 +276,19 @@ func extractNodeAddressesFromInstance(instance *ecs.Instance) ([]corev1.NodeAddr
 				if ip == nil {
 					return nil, fmt.Errorf("ECS instance had invalid private address: %s (%q)", instance.InstanceId, ipAddress)
 				}
-				addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip.String()})
+				ipv4Addresses = append(ipv4Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip.String()})
 			}
 		}
 	}
 
+	if ipv6Primary {
+		addresses = append(addresses, ipv6Addresses...)
+		addresses = append(addresses, ipv4Addresses...)
+	} else {
+		addresses = append(addresses, ipv4Addresses...)
+		addresses = append(addresses, ipv6Addresses...)
+	}
+
 	//// TODO: Other IP addresses (multiple ips)?
 	for _, publicIPAddress := range instance.PublicIpAddress.IpAddress {
 		if publicIPAddress != "" {
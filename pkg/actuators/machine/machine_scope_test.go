@@ -42,7 +42,7 @@ import (
 
 const testNamespace = "ms-test"
 
-func machineWithSpec(spec *machinev1.AlibabaCloudMachineProviderConfig) *machinev1beta1.Machine {
+func machineWithSpec(spec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) *machinev1beta1.Machine {
 	rawSpec, err := alibabacloudproviderv1.RawExtensionFromProviderSpec(spec)
 	if err != nil {
 		panic("Failed to encode raw extension from provider spec")
@@ -64,16 +64,18 @@ func machineWithSpec(spec *machinev1.AlibabaCloudMachineProviderConfig) *machine
 func TestGetUserData(t *testing.T) {
 	userDataSecretName := "test-ms-secret"
 
-	defaultProviderSpec := &machinev1.AlibabaCloudMachineProviderConfig{
-		UserDataSecret: &corev1.LocalObjectReference{
-			Name: userDataSecretName,
+	defaultProviderSpec := &alibabacloudproviderv1.AlibabaCloudMachineProviderConfig{
+		AlibabaCloudMachineProviderConfig: machinev1.AlibabaCloudMachineProviderConfig{
+			UserDataSecret: &corev1.LocalObjectReference{
+				Name: userDataSecretName,
+			},
 		},
 	}
 
 	testCases := []struct {
 		testCase         string
 		userDataSecret   *corev1.Secret
-		providerSpec     *machinev1.AlibabaCloudMachineProviderConfig
+		providerSpec     *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig
 		expectedUserdata []byte
 		expectError      bool
 	}{
@@ -122,7 +124,7 @@ func TestGetUserData(t *testing.T) {
 		{
 			testCase:         "no user-data in provider spec",
 			userDataSecret:   nil,
-			providerSpec:     &machinev1.AlibabaCloudMachineProviderConfig{},
+			providerSpec:     &alibabacloudproviderv1.AlibabaCloudMachineProviderConfig{},
 			expectError:      false,
 			expectedUserdata: nil,
 		},
@@ -191,7 +193,7 @@ func TestPatchMachine(t *testing.T) {
 
 	failedPhase := "Failed"
 
-	providerStatus := &machinev1.AlibabaCloudMachineProviderStatus{}
+	providerStatus := &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{}
 
 	testCases := []struct {
 		name   string
@@ -346,11 +348,11 @@ func TestGetNetworkAddress(t *testing.T) {
 			expected: []corev1.NodeAddress{
 				{
 					Type:    corev1.NodeInternalIP,
-					Address: "::ac10:102",
+					Address: "172.16.1.2",
 				},
 				{
 					Type:    corev1.NodeInternalIP,
-					Address: "172.16.1.2",
+					Address: "::ac10:102",
 				},
 				{
 					Type:    corev1.NodeExternalIP,
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// defaultMetadataHTTPPutResponseHopLimit is the hop limit of the metadata service when none is configured
+	defaultMetadataHTTPPutResponseHopLimit = 1
+	// maxMetadataHTTPPutResponseHopLimit is the maximum hop limit of the metadata service
+	maxMetadataHTTPPutResponseHopLimit = 64
+)
+
+func validateMetadataServiceOptions(options *alibabacloudproviderv1.MetadataServiceOptions) error {
+	switch options.HTTPTokens {
+	case "", alibabacloudproviderv1.RequiredMetadataHTTPTokens, alibabacloudproviderv1.OptionalMetadataHTTPTokens:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid metadata http tokens: %s. Allowed options are: %s,%s",
+			options.HTTPTokens,
+			alibabacloudproviderv1.RequiredMetadataHTTPTokens,
+			alibabacloudproviderv1.OptionalMetadataHTTPTokens)
+	}
+
+	if options.HTTPPutResponseHopLimit < 0 || options.HTTPPutResponseHopLimit > maxMetadataHTTPPutResponseHopLimit {
+		return mapierrors.InvalidMachineConfiguration("invalid metadata http put response hop limit: %d. Valid values are 1 to %d",
+			options.HTTPPutResponseHopLimit, maxMetadataHTTPPutResponseHopLimit)
+	}
+
+	return nil
+}
+
+// setMetadataServiceOptions sets the metadata service options on the RunInstances request
+func setMetadataServiceOptions(request *ecs.RunInstancesRequest, options *alibabacloudproviderv1.MetadataServiceOptions) error {
+	if err := validateMetadataServiceOptions(options); err != nil {
+		return err
+	}
+
+	request.HttpTokens = string(options.HTTPTokens)
+	if options.HTTPPutResponseHopLimit > 0 {
+		request.HttpPutResponseHopLimit = requests.NewInteger(int(options.HTTPPutResponseHopLimit))
+	}
+
+	return nil
+}
+
+// metadataServiceOptionsDrift returns a description of the metadata options of the instance which differ
+// from the provider spec, or an empty string when they match
+func metadataServiceOptionsDrift(instance *ecs.Instance, options *alibabacloudproviderv1.MetadataServiceOptions) string {
+	httpTokens := alibabacloudproviderv1.MetadataHTTPTokens(instance.MetadataOptions.HttpTokens)
+	if httpTokens == "" {
+		httpTokens = alibabacloudproviderv1.OptionalMetadataHTTPTokens
+	}
+	if options.HTTPTokens != "" && options.HTTPTokens != httpTokens {
+		return fmt.Sprintf("http tokens are %s instead of %s", httpTokens, options.HTTPTokens)
+	}
+
+	hopLimit := instance.MetadataOptions.HttpPutResponseHopLimit
+	if hopLimit == 0 {
+		hopLimit = defaultMetadataHTTPPutResponseHopLimit
+	}
+	if options.HTTPPutResponseHopLimit > 0 && int(options.HTTPPutResponseHopLimit) != hopLimit {
+		return fmt.Sprintf("http put response hop limit is %d instead of %d", hopLimit, options.HTTPPutResponseHopLimit)
+	}
+
+	return ""
+}
+
+// reconcileMetadataServiceOptions corrects the metadata options of the instance when they differ from the provider spec,
+// and reports the result in the MetadataOptionsApplied condition. A failure to apply the options does not fail the
+// reconciliation, it is only reported in the condition.
+func (r *Reconciler) reconcileMetadataServiceOptions(instance *ecs.Instance) {
+	options := r.providerSpec.MetadataServiceOptions
+	if options == nil || instance == nil {
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:    string(alibabacloudproviderv1.MetadataOptionsApplied),
+		Status:  metav1.ConditionTrue,
+		Reason:  alibabacloudproviderv1.MetadataOptionsAppliedConditionReason,
+		Message: "Metadata options of the instance match the provider spec",
+	}
+
+	if err := r.applyMetadataServiceOptions(instance, options); err != nil {
+		klog.Warningf("%s: failed to apply metadata options: %v", r.machine.Name, err)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = alibabacloudproviderv1.MetadataOptionsFailedConditionReason
+		condition.Message = err.Error()
+	}
+
+	r.providerStatus.Conditions = setMachineProviderCondition(condition, r.providerStatus.Conditions)
+}
+
+func (r *Reconciler) applyMetadataServiceOptions(instance *ecs.Instance, options *alibabacloudproviderv1.MetadataServiceOptions) error {
+	if err := validateMetadataServiceOptions(options); err != nil {
+		return err
+	}
+
+	drift := metadataServiceOptionsDrift(instance, options)
+	if drift == "" {
+		return nil
+	}
+	klog.Infof("%s: correcting metadata options of instance %s: %s", r.machine.Name, instance.InstanceId, drift)
+
+	request := ecs.CreateModifyInstanceMetadataOptionsRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.InstanceId = instance.InstanceId
+	request.HttpTokens = string(options.HTTPTokens)
+	if options.HTTPPutResponseHopLimit > 0 {
+		request.HttpPutResponseHopLimit = requests.NewInteger(int(options.HTTPPutResponseHopLimit))
+	}
+
+	if _, err := r.alibabacloudClient.ModifyInstanceMetadataOptions(request); err != nil {
+		return fmt.Errorf("error modifying metadata options of instance %s (%s): %v", instance.InstanceId, drift, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetMetadataServiceOptions(t *testing.T) {
+	cases := []struct {
+		name               string
+		options            *alibabacloudproviderv1.MetadataServiceOptions
+		expectedHTTPTokens string
+		expectedHopLimit   string
+		succeeds           bool
+	}{
+		{
+			name:               "Hardened mode",
+			options:            &alibabacloudproviderv1.MetadataServiceOptions{HTTPTokens: alibabacloudproviderv1.RequiredMetadataHTTPTokens, HTTPPutResponseHopLimit: 2},
+			expectedHTTPTokens: "Required",
+			expectedHopLimit:   "2",
+			succeeds:           true,
+		},
+		{
+			name:     "Platform defaults",
+			options:  &alibabacloudproviderv1.MetadataServiceOptions{},
+			succeeds: true,
+		},
+		{
+			name:    "Invalid http tokens",
+			options: &alibabacloudproviderv1.MetadataServiceOptions{HTTPTokens: "Enabled"},
+		},
+		{
+			name:    "Invalid hop limit",
+			options: &alibabacloudproviderv1.MetadataServiceOptions{HTTPPutResponseHopLimit: 65},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := ecs.CreateRunInstancesRequest()
+			err := setMetadataServiceOptions(request, tc.options)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			if tc.succeeds {
+				assert.Equal(t, tc.expectedHTTPTokens, request.HttpTokens)
+				assert.Equal(t, tc.expectedHopLimit, string(request.HttpPutResponseHopLimit))
+			}
+		})
+	}
+}
+
+func TestReconcileMetadataServiceOptions(t *testing.T) {
+	hardened := &alibabacloudproviderv1.MetadataServiceOptions{HTTPTokens: alibabacloudproviderv1.RequiredMetadataHTTPTokens, HTTPPutResponseHopLimit: 2}
+
+	cases := []struct {
+		name            string
+		options         *alibabacloudproviderv1.MetadataServiceOptions
+		metadataOptions ecs.MetadataOptions
+		expectModify    bool
+		modifyErr       error
+		expectedStatus  metav1.ConditionStatus
+	}{
+		{
+			name:            "Metadata options applied",
+			options:         hardened,
+			metadataOptions: ecs.MetadataOptions{HttpTokens: "Required", HttpPutResponseHopLimit: 2},
+			expectedStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:            "Correct drifted metadata options",
+			options:         hardened,
+			metadataOptions: ecs.MetadataOptions{HttpTokens: "Optional", HttpPutResponseHopLimit: 2},
+			expectModify:    true,
+			expectedStatus:  metav1.ConditionTrue,
+		},
+		{
+			name:           "Correct the default hop limit",
+			options:        &alibabacloudproviderv1.MetadataServiceOptions{HTTPPutResponseHopLimit: 2},
+			expectModify:   true,
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:            "Metadata options cannot be applied",
+			options:         hardened,
+			metadataOptions: ecs.MetadataOptions{HttpTokens: "Optional", HttpPutResponseHopLimit: 1},
+			expectModify:    true,
+			modifyErr:       fmt.Errorf("error"),
+			expectedStatus:  metav1.ConditionFalse,
+		},
+		{
+			name:            "No metadata options",
+			metadataOptions: ecs.MetadataOptions{HttpTokens: "Optional", HttpPutResponseHopLimit: 1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			modifyCalls := 0
+			if tc.expectModify {
+				modifyCalls = 1
+			}
+			mockAlibabaCloudClient.EXPECT().ModifyInstanceMetadataOptions(gomock.Any()).DoAndReturn(
+				func(request *ecs.ModifyInstanceMetadataOptionsRequest) (*ecs.ModifyInstanceMetadataOptionsResponse, error) {
+					assert.Equal(t, stubInstanceID, request.InstanceId)
+					assert.Equal(t, string(tc.options.HTTPTokens), request.HttpTokens)
+					assert.Equal(t, "2", string(request.HttpPutResponseHopLimit))
+					return &ecs.ModifyInstanceMetadataOptionsResponse{}, tc.modifyErr
+				}).Times(modifyCalls)
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			providerSpec := stubProviderConfig()
+			providerSpec.MetadataServiceOptions = tc.options
+
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				machine:            machine,
+				providerSpec:       providerSpec,
+				providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+			})
+
+			r.reconcileMetadataServiceOptions(&ecs.Instance{InstanceId: stubInstanceID, MetadataOptions: tc.metadataOptions})
+
+			condition := findProviderCondition(r.providerStatus.Conditions, alibabacloudproviderv1.MetadataOptionsApplied)
+			if tc.expectedStatus == "" {
+				assert.Nil(t, condition)
+				return
+			}
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedStatus, condition.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	instanceLaunchCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_alibabacloud_instance_launches_total",
+			Help: "Number of instances launched, by the capacity the instance was created from.",
+		}, []string{"namespace", "capacity_type"},
+	)
+
+	duplicateInstances = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mapi_alibabacloud_duplicate_instances",
+			Help: "Number of duplicate instances found for a machine, including the ones which are not released.",
+		}, []string{"namespace", "name"},
+	)
+
+	releasedDuplicateInstanceCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mapi_alibabacloud_duplicate_instances_released_total",
+			Help: "Number of duplicate instances found for a machine and released.",
+		}, []string{"namespace"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(instanceLaunchCount, duplicateInstances, releasedDuplicateInstanceCount)
+}
+
+// registerInstanceLaunch records the launch of an instance created from the given capacity type
+func registerInstanceLaunch(namespace string, capacityType string) {
+	instanceLaunchCount.With(prometheus.Labels{
+		"namespace":     namespace,
+		"capacity_type": capacityType,
+	}).Inc()
+}
+
+// registerDuplicateInstances records the number of duplicate instances currently found for a machine
+func registerDuplicateInstances(namespace string, name string, count int) {
+	duplicateInstances.With(prometheus.Labels{
+		"namespace": namespace,
+		"name":      name,
+	}).Set(float64(count))
+}
+
+// unregisterDuplicateInstances forgets the duplicate instances of a deleted machine
+func unregisterDuplicateInstances(namespace string, name string) {
+	duplicateInstances.Delete(prometheus.Labels{
+		"namespace": namespace,
+		"name":      name,
+	})
+}
+
+// registerReleasedDuplicateInstances records the release of duplicate instances found for a machine
+func registerReleasedDuplicateInstances(namespace string, count int) {
+	releasedDuplicateInstanceCount.With(prometheus.Labels{
+		"namespace": namespace,
+	}).Add(float64(count))
+}
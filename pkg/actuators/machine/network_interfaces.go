@@ -0,0 +1,380 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"k8s.io/klog"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ECSNetworkInterfaceStatusAvailable network interface status when it is not attached to an instance
+	ECSNetworkInterfaceStatusAvailable = "Available"
+	// ECSNetworkInterfaceStatusInUse network interface status when it is attached to an instance
+	ECSNetworkInterfaceStatusInUse = "InUse"
+	// ECSNetworkInterfaceStatusDeleting network interface status when it is being deleted
+	ECSNetworkInterfaceStatusDeleting = "Deleting"
+
+	// ECSNetworkInterfaceTypeSecondary type of the secondary network interfaces of an instance
+	ECSNetworkInterfaceTypeSecondary = "Secondary"
+
+	// networkInterfaceIndexTagKey is the tag recording the position of a network interface in the provider spec
+	networkInterfaceIndexTagKey = "machine.openshift.io/network-interface-index"
+)
+
+// validateNetworkInterfaces checks the secondary network interfaces of the provider spec
+func validateNetworkInterfaces(networkInterfaces []alibabacloudproviderv1.NetworkInterface) error {
+	for i, networkInterface := range networkInterfaces {
+		switch networkInterface.VSwitch.Type {
+		case machinev1.AlibabaResourceReferenceTypeID, machinev1.AlibabaResourceReferenceTypeTags:
+		default:
+			return mapierrors.InvalidMachineConfiguration("Unknown vswitch resource reference type of network interface %d: %s", i, networkInterface.VSwitch.Type)
+		}
+
+		if networkInterface.QueueNumber < 0 {
+			return mapierrors.InvalidMachineConfiguration("invalid queue number of network interface %d: %d", i, networkInterface.QueueNumber)
+		}
+
+		if networkInterface.SecondaryPrivateIPAddressCount < 0 {
+			return mapierrors.InvalidMachineConfiguration("invalid secondary private IP address count of network interface %d: %d", i, networkInterface.SecondaryPrivateIPAddressCount)
+		}
+	}
+
+	return nil
+}
+
+// reconcileNetworkInterfaces creates the secondary network interfaces of the provider spec which do not exist yet,
+// attaches them to the instance and reports them in the provider status.
+// The network interfaces removed from the provider spec are detached and deleted, they are reported until they are gone.
+func (r *Reconciler) reconcileNetworkInterfaces(instance *ecs.Instance) error {
+	if len(r.providerSpec.NetworkInterfaces) == 0 && len(r.providerStatus.NetworkInterfaces) == 0 {
+		return nil
+	}
+
+	if err := validateNetworkInterfaces(r.providerSpec.NetworkInterfaces); err != nil {
+		return err
+	}
+
+	existing, err := getMachineNetworkInterfaces(r.machine, r.providerSpec.RegionID, r.alibabacloudClient)
+	if err != nil {
+		return err
+	}
+
+	byIndex := make(map[string]ecs.NetworkInterfaceSet)
+	for _, networkInterface := range existing {
+		if index, ok := getNetworkInterfaceIndex(networkInterface); ok {
+			byIndex[index] = networkInterface
+		}
+	}
+
+	statuses := make([]alibabacloudproviderv1.NetworkInterfaceStatus, 0, len(r.providerSpec.NetworkInterfaces))
+	for i, spec := range r.providerSpec.NetworkInterfaces {
+		networkInterface, ok := byIndex[strconv.Itoa(i)]
+		if !ok {
+			created, err := createNetworkInterface(r.machine, r.providerSpec, i, spec, instance, r.alibabacloudClient)
+			if err != nil {
+				return err
+			}
+			networkInterface = *created
+		}
+
+		switch {
+		case networkInterface.Status == ECSNetworkInterfaceStatusAvailable && (instance.Status == ECSInstanceStatusRunning || instance.Status == ECSInstanceStatusStopped):
+			if err := attachNetworkInterface(networkInterface.NetworkInterfaceId, instance.InstanceId, r.providerSpec.RegionID, r.alibabacloudClient); err != nil {
+				return err
+			}
+			klog.Infof("%s: attached network interface %s to instance %s", r.machine.Name, networkInterface.NetworkInterfaceId, instance.InstanceId)
+			networkInterface.Status = "Attaching"
+		case networkInterface.InstanceId != "" && networkInterface.InstanceId != instance.InstanceId:
+			return fmt.Errorf("network interface %s is attached to instance %s instead of instance %s", networkInterface.NetworkInterfaceId, networkInterface.InstanceId, instance.InstanceId)
+		}
+
+		statuses = append(statuses, getNetworkInterfaceStatus(networkInterface))
+	}
+
+	for _, networkInterface := range existing {
+		index, _ := getNetworkInterfaceIndex(networkInterface)
+		if i, err := strconv.Atoi(index); err == nil && i >= 0 && i < len(r.providerSpec.NetworkInterfaces) {
+			continue
+		}
+		if keepNetworkInterface(networkInterface) {
+			continue
+		}
+
+		detaching, err := r.releaseNetworkInterface(networkInterface)
+		if err != nil {
+			return err
+		}
+		if detaching {
+			networkInterface.Status = "Detaching"
+			statuses = append(statuses, getNetworkInterfaceStatus(networkInterface))
+		}
+	}
+
+	r.providerStatus.NetworkInterfaces = statuses
+	return nil
+}
+
+// getNetworkInterfaceIndex returns the position of the network interface in the provider spec recorded in its tags
+func getNetworkInterfaceIndex(networkInterface ecs.NetworkInterfaceSet) (string, bool) {
+	for _, tag := range networkInterface.Tags.Tag {
+		// The tags of network interfaces are returned as TagKey and TagValue
+		if tag.TagKey == networkInterfaceIndexTagKey {
+			return tag.TagValue, true
+		} else if tag.Key == networkInterfaceIndexTagKey {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// createNetworkInterface creates the secondary network interface at the given index of the provider spec
+func createNetworkInterface(machine *machinev1beta1.Machine, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, index int, networkInterface alibabacloudproviderv1.NetworkInterface, instance *ecs.Instance, client alibabacloudClient.Client) (*ecs.NetworkInterfaceSet, error) {
+	clusterID, ok := getClusterID(machine)
+	if !ok {
+		return nil, fmt.Errorf("unable to get cluster ID for machine: %q", machine.Name)
+	}
+	machineKey := runtimeclient.ObjectKey{Name: machine.Name, Namespace: machine.Namespace}
+
+	// The vswitch and security groups are resolved like the ones of the instance
+	networkInterfaceConfig := machineProviderConfig.DeepCopy()
+	networkInterfaceConfig.VSwitch = networkInterface.VSwitch
+	networkInterfaceConfig.SecurityGroups = networkInterface.SecurityGroups
+
+	vSwitchID, err := getVSwitchID(machineKey, networkInterfaceConfig, client)
+	if err != nil {
+		klog.Errorf("Unable to determine vswitch ID of network interface %d for machine %q, err %q", index, machine.Name, err)
+		return nil, err
+	}
+
+	securityGroupIDs := instance.SecurityGroupIds.SecurityGroupId
+	if len(networkInterface.SecurityGroups) > 0 {
+		ids, err := getSecurityGroupIDs(machineKey, networkInterfaceConfig, client)
+		if err != nil {
+			klog.Errorf("Unable to determine security groups of network interface %d for machine %q, err %q", index, machine.Name, err)
+			return nil, err
+		}
+		securityGroupIDs = *ids
+	}
+
+	request := ecs.CreateCreateNetworkInterfaceRequest()
+	request.Scheme = "https"
+	request.RegionId = machineProviderConfig.RegionID
+	request.VSwitchId = vSwitchID
+	request.SecurityGroupIds = &securityGroupIDs
+	request.NetworkInterfaceName = fmt.Sprintf("%s-eni-%d", machine.Name, index)
+	// A request retried after a timeout does not create another network interface
+	request.ClientToken = fmt.Sprintf("%s-eni-%d", machine.UID, index)
+	if networkInterface.QueueNumber > 0 {
+		request.QueueNumber = requests.NewInteger(int(networkInterface.QueueNumber))
+	}
+	if networkInterface.SecondaryPrivateIPAddressCount > 0 {
+		request.SecondaryPrivateIpAddressCount = requests.NewInteger(int(networkInterface.SecondaryPrivateIPAddressCount))
+	}
+
+	tags := buildTagList(machine, clusterID, machineProviderConfig.Tags)
+	tags = append(tags, &machinev1.Tag{Key: networkInterfaceIndexTagKey, Value: strconv.Itoa(index)})
+	createTags := make([]ecs.CreateNetworkInterfaceTag, 0, len(tags))
+	for _, tag := range tags {
+		createTags = append(createTags, ecs.CreateNetworkInterfaceTag{Key: tag.Key, Value: tag.Value})
+	}
+	request.Tag = &createTags
+
+	response, err := client.CreateNetworkInterface(request)
+	if err != nil {
+		klog.Errorf("Error creating network interface %d for machine %q: %v", index, machine.Name, err)
+		return nil, fmt.Errorf("error creating network interface %d: %v", index, err)
+	}
+	klog.Infof("%s: created network interface %s in vswitch %s", machine.Name, response.NetworkInterfaceId, response.VSwitchId)
+
+	created := &ecs.NetworkInterfaceSet{
+		NetworkInterfaceId: response.NetworkInterfaceId,
+		Status:             response.Status,
+		VSwitchId:          response.VSwitchId,
+		PrivateIpAddress:   response.PrivateIpAddress,
+	}
+	for _, privateIP := range response.PrivateIpSets.PrivateIpSet {
+		created.PrivateIpSets.PrivateIpSet = append(created.PrivateIpSets.PrivateIpSet, ecs.PrivateIpSet{
+			PrivateIpAddress: privateIP.PrivateIpAddress,
+			Primary:          privateIP.Primary,
+		})
+	}
+
+	return created, nil
+}
+
+func attachNetworkInterface(networkInterfaceID, instanceID, regionID string, client alibabacloudClient.Client) error {
+	request := ecs.CreateAttachNetworkInterfaceRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.NetworkInterfaceId = networkInterfaceID
+	request.InstanceId = instanceID
+
+	if _, err := client.AttachNetworkInterface(request); err != nil {
+		klog.Errorf("Error attaching network interface %s to instance %s: %v", networkInterfaceID, instanceID, err)
+		return fmt.Errorf("error attaching network interface %s to instance %s: %v", networkInterfaceID, instanceID, err)
+	}
+
+	return nil
+}
+
+// getMachineNetworkInterfaces returns the secondary network interfaces tagged with the machine name and cluster ID
+func getMachineNetworkInterfaces(machine *machinev1beta1.Machine, regionID string, client alibabacloudClient.Client) ([]ecs.NetworkInterfaceSet, error) {
+	clusterID, ok := getClusterID(machine)
+	if !ok {
+		return nil, fmt.Errorf("unable to get cluster ID for machine: %q", machine.Name)
+	}
+
+	request := ecs.CreateDescribeNetworkInterfacesRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.Type = ECSNetworkInterfaceTypeSecondary
+	request.Tag = &[]ecs.DescribeNetworkInterfacesTag{
+		{Key: clusterFilterKeyPrefix + clusterID, Value: clusterFilterValue},
+		{Key: clusterFilterName, Value: machine.Name},
+		{Key: machineNamespaceTagKey, Value: machine.Namespace},
+		{Key: machineUIDTagKey, Value: string(machine.UID)},
+	}
+
+	networkInterfaces := make([]ecs.NetworkInterfaceSet, 0)
+	for {
+		response, err := client.DescribeNetworkInterfaces(request)
+		if err != nil {
+			klog.Errorf("error describing network interfaces: %v", err)
+			return nil, fmt.Errorf("error describing network interfaces: %v", err)
+		}
+
+		networkInterfaces = append(networkInterfaces, response.NetworkInterfaceSets.NetworkInterfaceSet...)
+
+		if response.NextToken == "" {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+
+	return networkInterfaces, nil
+}
+
+func getNetworkInterfaceStatus(networkInterface ecs.NetworkInterfaceSet) alibabacloudproviderv1.NetworkInterfaceStatus {
+	status := alibabacloudproviderv1.NetworkInterfaceStatus{
+		ID:        networkInterface.NetworkInterfaceId,
+		VSwitchID: networkInterface.VSwitchId,
+		Status:    networkInterface.Status,
+	}
+
+	if networkInterface.PrivateIpAddress != "" {
+		status.PrivateIPAddresses = append(status.PrivateIPAddresses, networkInterface.PrivateIpAddress)
+	}
+	for _, privateIP := range networkInterface.PrivateIpSets.PrivateIpSet {
+		if !privateIP.Primary && privateIP.PrivateIpAddress != "" {
+			status.PrivateIPAddresses = append(status.PrivateIPAddresses, privateIP.PrivateIpAddress)
+		}
+	}
+
+	return status
+}
+
+// deleteNetworkInterfaces detaches the secondary network interfaces of the machine from its stopped instances and deletes them
+func (r *Reconciler) deleteNetworkInterfaces() error {
+	networkInterfaces, err := getMachineNetworkInterfaces(r.machine, r.providerSpec.RegionID, r.alibabacloudClient)
+	if err != nil {
+		return err
+	}
+
+	detaching := 0
+	for _, networkInterface := range networkInterfaces {
+		if keepNetworkInterface(networkInterface) {
+			continue
+		}
+
+		isDetaching, err := r.releaseNetworkInterface(networkInterface)
+		if err != nil {
+			return err
+		}
+		if isDetaching {
+			detaching++
+		}
+	}
+
+	if detaching > 0 {
+		klog.Infof("%s: waiting for %d network interfaces to be detached, returning an error to requeue", r.machine.Name, detaching)
+		return &mapierrors.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+	}
+
+	r.providerStatus.NetworkInterfaces = nil
+	return nil
+}
+
+// releaseNetworkInterface detaches the network interface from its instance, or deletes it once it is detached.
+// It returns true while the network interface is detached.
+func (r *Reconciler) releaseNetworkInterface(networkInterface ecs.NetworkInterfaceSet) (bool, error) {
+	switch networkInterface.Status {
+	case ECSNetworkInterfaceStatusAvailable:
+	case ECSNetworkInterfaceStatusDeleting:
+		return false, nil
+	case ECSNetworkInterfaceStatusInUse:
+		request := ecs.CreateDetachNetworkInterfaceRequest()
+		request.Scheme = "https"
+		request.RegionId = r.providerSpec.RegionID
+		request.NetworkInterfaceId = networkInterface.NetworkInterfaceId
+		request.InstanceId = networkInterface.InstanceId
+
+		if _, err := r.alibabacloudClient.DetachNetworkInterface(request); err != nil {
+			klog.Errorf("Error detaching network interface %s from instance %s: %v", networkInterface.NetworkInterfaceId, networkInterface.InstanceId, err)
+			return false, fmt.Errorf("error detaching network interface %s: %v", networkInterface.NetworkInterfaceId, err)
+		}
+		klog.Infof("%s: detached network interface %s from instance %s", r.machine.Name, networkInterface.NetworkInterfaceId, networkInterface.InstanceId)
+		return true, nil
+	default:
+		// The network interface is deleted once it is detached
+		return true, nil
+	}
+
+	request := ecs.CreateDeleteNetworkInterfaceRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.NetworkInterfaceId = networkInterface.NetworkInterfaceId
+
+	if _, err := r.alibabacloudClient.DeleteNetworkInterface(request); err != nil {
+		klog.Errorf("Error deleting network interface %s: %v", networkInterface.NetworkInterfaceId, err)
+		return false, fmt.Errorf("error deleting network interface %s: %v", networkInterface.NetworkInterfaceId, err)
+	}
+	klog.Infof("%s: deleted network interface %s", r.machine.Name, networkInterface.NetworkInterfaceId)
+	return false, nil
+}
+
+// keepNetworkInterface returns true when the network interface is tagged to be kept
+func keepNetworkInterface(networkInterface ecs.NetworkInterfaceSet) bool {
+	for _, tag := range networkInterface.Tags.Tag {
+		if isKeepOnDeleteTag(tag.TagKey, tag.TagValue) {
+			klog.Infof("Keeping network interface %s tagged %s", networkInterface.NetworkInterfaceId, keepOnDeleteTagKey)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	stubNetworkInterfaceID      = "eni-bp1storage"
+	stubOtherNetworkInterfaceID = "eni-bp1management"
+	stubStorageVSwitchID        = "vsw-bp1storage"
+	stubMachineUID              = "6c1a0a5e-4f8b-4c1e-9d3a-2b7f1e0c9a41"
+)
+
+func stubNetworkInterfaceSet(id, status, instanceID string, index string) ecs.NetworkInterfaceSet {
+	return ecs.NetworkInterfaceSet{
+		NetworkInterfaceId: id,
+		Status:             status,
+		InstanceId:         instanceID,
+		VSwitchId:          stubStorageVSwitchID,
+		PrivateIpAddress:   "10.0.1.10",
+		PrivateIpSets: ecs.PrivateIpSetsInDescribeNetworkInterfaces{
+			PrivateIpSet: []ecs.PrivateIpSet{
+				{PrivateIpAddress: "10.0.1.10", Primary: true},
+				{PrivateIpAddress: "10.0.1.11"},
+			},
+		},
+		Tags: ecs.TagsInDescribeNetworkInterfaces{
+			Tag: []ecs.Tag{{TagKey: networkInterfaceIndexTagKey, TagValue: index}},
+		},
+	}
+}
+
+func TestReconcileNetworkInterfaces(t *testing.T) {
+	networkInterfaces := []alibabacloudproviderv1.NetworkInterface{
+		{
+			VSwitch:                        machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeID, ID: pointer.StringPtr(stubStorageVSwitchID)},
+			QueueNumber:                    4,
+			SecondaryPrivateIPAddressCount: 1,
+		},
+		{
+			VSwitch: machinev1.AlibabaResourceReference{Type: machinev1.AlibabaResourceReferenceTypeID, ID: pointer.StringPtr(stubVSwitchID)},
+			SecurityGroups: []machinev1.AlibabaResourceReference{
+				{Type: machinev1.AlibabaResourceReferenceTypeID, ID: pointer.StringPtr("sg-management")},
+			},
+		},
+	}
+
+	cases := []struct {
+		name             string
+		existing         []ecs.NetworkInterfaceSet
+		expectCreate     int
+		expectAttach     int
+		expectDetach     int
+		expectDelete     int
+		expectedStatuses []string
+		expectError      bool
+	}{
+		{
+			name:             "Create and attach the network interfaces",
+			expectCreate:     2,
+			expectAttach:     2,
+			expectedStatuses: []string{"Attaching", "Attaching"},
+		},
+		{
+			name: "Network interfaces already attached",
+			existing: []ecs.NetworkInterfaceSet{
+				stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "0"),
+				stubNetworkInterfaceSet(stubOtherNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "1"),
+			},
+			expectedStatuses: []string{ECSNetworkInterfaceStatusInUse, ECSNetworkInterfaceStatusInUse},
+		},
+		{
+			name: "Attach a detached network interface",
+			existing: []ecs.NetworkInterfaceSet{
+				stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusAvailable, "", "0"),
+				stubNetworkInterfaceSet(stubOtherNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "1"),
+			},
+			expectAttach:     1,
+			expectedStatuses: []string{"Attaching", ECSNetworkInterfaceStatusInUse},
+		},
+		{
+			name: "Network interface removed from the provider spec is detached",
+			existing: []ecs.NetworkInterfaceSet{
+				stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "0"),
+				stubNetworkInterfaceSet(stubOtherNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "1"),
+				stubNetworkInterfaceSet("eni-bp1removed", ECSNetworkInterfaceStatusInUse, stubInstanceID, "2"),
+			},
+			expectDetach:     1,
+			expectedStatuses: []string{ECSNetworkInterfaceStatusInUse, ECSNetworkInterfaceStatusInUse, "Detaching"},
+		},
+		{
+			name: "Detached network interface removed from the provider spec is deleted",
+			existing: []ecs.NetworkInterfaceSet{
+				stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "0"),
+				stubNetworkInterfaceSet(stubOtherNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "1"),
+				stubNetworkInterfaceSet("eni-bp1removed", ECSNetworkInterfaceStatusAvailable, "", "2"),
+			},
+			expectDelete:     1,
+			expectedStatuses: []string{ECSNetworkInterfaceStatusInUse, ECSNetworkInterfaceStatusInUse},
+		},
+		{
+			name: "Network interface attached to another instance",
+			existing: []ecs.NetworkInterfaceSet{
+				stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, "i-other", "0"),
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).DoAndReturn(
+				func(request *ecs.DescribeNetworkInterfacesRequest) (*ecs.DescribeNetworkInterfacesResponse, error) {
+					assert.Equal(t, ECSNetworkInterfaceTypeSecondary, request.Type)
+					return &ecs.DescribeNetworkInterfacesResponse{
+						NetworkInterfaceSets: ecs.NetworkInterfaceSets{NetworkInterfaceSet: tc.existing},
+					}, nil
+				}).Times(1)
+			mockAlibabaCloudClient.EXPECT().CreateNetworkInterface(gomock.Any()).DoAndReturn(
+				func(request *ecs.CreateNetworkInterfaceRequest) (*ecs.CreateNetworkInterfaceResponse, error) {
+					if request.VSwitchId == stubStorageVSwitchID {
+						assert.Equal(t, stubMachineUID+"-eni-0", request.ClientToken)
+						assert.Equal(t, []string{"sg-instance"}, *request.SecurityGroupIds)
+						assert.Equal(t, "4", string(request.QueueNumber))
+						assert.Equal(t, "1", string(request.SecondaryPrivateIpAddressCount))
+						assert.Contains(t, *request.Tag, ecs.CreateNetworkInterfaceTag{Key: networkInterfaceIndexTagKey, Value: "0"})
+						return &ecs.CreateNetworkInterfaceResponse{NetworkInterfaceId: stubNetworkInterfaceID, VSwitchId: request.VSwitchId, Status: ECSNetworkInterfaceStatusAvailable}, nil
+					}
+					assert.Equal(t, stubMachineUID+"-eni-1", request.ClientToken)
+					assert.Equal(t, []string{"sg-management"}, *request.SecurityGroupIds)
+					assert.Contains(t, *request.Tag, ecs.CreateNetworkInterfaceTag{Key: networkInterfaceIndexTagKey, Value: "1"})
+					return &ecs.CreateNetworkInterfaceResponse{NetworkInterfaceId: stubOtherNetworkInterfaceID, VSwitchId: request.VSwitchId, Status: ECSNetworkInterfaceStatusAvailable}, nil
+				}).Times(tc.expectCreate)
+			mockAlibabaCloudClient.EXPECT().AttachNetworkInterface(gomock.Any()).DoAndReturn(
+				func(request *ecs.AttachNetworkInterfaceRequest) (*ecs.AttachNetworkInterfaceResponse, error) {
+					assert.Equal(t, stubInstanceID, request.InstanceId)
+					return &ecs.AttachNetworkInterfaceResponse{}, nil
+				}).Times(tc.expectAttach)
+			mockAlibabaCloudClient.EXPECT().DetachNetworkInterface(gomock.Any()).DoAndReturn(
+				func(request *ecs.DetachNetworkInterfaceRequest) (*ecs.DetachNetworkInterfaceResponse, error) {
+					assert.Equal(t, "eni-bp1removed", request.NetworkInterfaceId)
+					return &ecs.DetachNetworkInterfaceResponse{}, nil
+				}).Times(tc.expectDetach)
+			mockAlibabaCloudClient.EXPECT().DeleteNetworkInterface(gomock.Any()).DoAndReturn(
+				func(request *ecs.DeleteNetworkInterfaceRequest) (*ecs.DeleteNetworkInterfaceResponse, error) {
+					assert.Equal(t, "eni-bp1removed", request.NetworkInterfaceId)
+					return &ecs.DeleteNetworkInterfaceResponse{}, nil
+				}).Times(tc.expectDelete)
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine.UID = stubMachineUID
+
+			providerSpec := stubProviderConfig()
+			providerSpec.NetworkInterfaces = networkInterfaces
+
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				machine:            machine,
+				providerSpec:       providerSpec,
+				providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+			})
+
+			instance := &ecs.Instance{
+				InstanceId:       stubInstanceID,
+				Status:           ECSInstanceStatusRunning,
+				SecurityGroupIds: ecs.SecurityGroupIdsInDescribeInstances{SecurityGroupId: []string{"sg-instance"}},
+			}
+
+			err = r.reconcileNetworkInterfaces(instance)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			statuses := make([]string, 0)
+			for _, status := range r.providerStatus.NetworkInterfaces {
+				statuses = append(statuses, status.Status)
+			}
+			assert.Equal(t, tc.expectedStatuses, statuses)
+			if tc.existing != nil {
+				assert.Equal(t, []string{"10.0.1.10", "10.0.1.11"}, r.providerStatus.NetworkInterfaces[0].PrivateIPAddresses)
+			}
+		})
+	}
+}
+
+func TestDeleteNetworkInterfaces(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	detached := false
+	mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).DoAndReturn(
+		func(request *ecs.DescribeNetworkInterfacesRequest) (*ecs.DescribeNetworkInterfacesResponse, error) {
+			if detached {
+				return &ecs.DescribeNetworkInterfacesResponse{
+					NetworkInterfaceSets: ecs.NetworkInterfaceSets{NetworkInterfaceSet: []ecs.NetworkInterfaceSet{
+						stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusAvailable, "", "0"),
+					}},
+				}, nil
+			}
+			return &ecs.DescribeNetworkInterfacesResponse{
+				NetworkInterfaceSets: ecs.NetworkInterfaceSets{NetworkInterfaceSet: []ecs.NetworkInterfaceSet{
+					stubNetworkInterfaceSet(stubNetworkInterfaceID, ECSNetworkInterfaceStatusInUse, stubInstanceID, "0"),
+					stubNetworkInterfaceSet(stubOtherNetworkInterfaceID, ECSNetworkInterfaceStatusAvailable, "", "1"),
+				}},
+			}, nil
+		}).Times(2)
+	mockAlibabaCloudClient.EXPECT().DetachNetworkInterface(gomock.Any()).DoAndReturn(
+		func(request *ecs.DetachNetworkInterfaceRequest) (*ecs.DetachNetworkInterfaceResponse, error) {
+			assert.Equal(t, stubNetworkInterfaceID, request.NetworkInterfaceId)
+			assert.Equal(t, stubInstanceID, request.InstanceId)
+			return &ecs.DetachNetworkInterfaceResponse{}, nil
+		}).Times(1)
+	deleted := make([]string, 0)
+	mockAlibabaCloudClient.EXPECT().DeleteNetworkInterface(gomock.Any()).DoAndReturn(
+		func(request *ecs.DeleteNetworkInterfaceRequest) (*ecs.DeleteNetworkInterfaceResponse, error) {
+			deleted = append(deleted, request.NetworkInterfaceId)
+			return &ecs.DeleteNetworkInterfaceResponse{}, nil
+		}).Times(2)
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		machine:            machine,
+		providerSpec:       stubProviderConfig(),
+		providerStatus: &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+			NetworkInterfaces: []alibabacloudproviderv1.NetworkInterfaceStatus{{ID: stubNetworkInterfaceID}, {ID: stubOtherNetworkInterfaceID}},
+		},
+	})
+
+	// The attached network interface is deleted once it is detached
+	_, requeue := r.deleteNetworkInterfaces().(*machinecontroller.RequeueAfterError)
+	assert.True(t, requeue, "expected the machine to be requeued while the network interface is detached")
+	assert.Equal(t, []string{stubOtherNetworkInterfaceID}, deleted)
+	assert.Len(t, r.providerStatus.NetworkInterfaces, 2)
+
+	detached = true
+	assert.NoError(t, r.deleteNetworkInterfaces())
+	assert.Equal(t, []string{stubOtherNetworkInterfaceID, stubNetworkInterfaceID}, deleted)
+	assert.Empty(t, r.providerStatus.NetworkInterfaces)
+}
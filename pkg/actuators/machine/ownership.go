@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/klog"
+)
+
+// instanceOwnershipMismatchEventReason is the reason of the event recorded when an instance is not owned by the machine
+const instanceOwnershipMismatchEventReason = "InstanceOwnershipMismatch"
+
+// isMachineOwnershipTagKey returns true for the tags identifying the machine owning a resource
+func isMachineOwnershipTagKey(key string) bool {
+	return key == machineNamespaceTagKey || key == machineUIDTagKey
+}
+
+// machineOwnershipTags returns the tags identifying the machine as the owner of an instance
+func machineOwnershipTags(machine *machinev1beta1.Machine) []ecs.TagResourcesTag {
+	return []ecs.TagResourcesTag{
+		{Key: machineNamespaceTagKey, Value: machine.Namespace},
+		{Key: machineUIDTagKey, Value: string(machine.UID)},
+	}
+}
+
+// verifyInstanceOwnership checks the tags of the instance show it is owned by the machine.
+// Instances launched before the namespace and UID tags were introduced do not have them,
+// they are only compared when present.
+func verifyInstanceOwnership(machine *machinev1beta1.Machine, instance *ecs.Instance) error {
+	clusterID, ok := getClusterID(machine)
+	if !ok {
+		return fmt.Errorf("unable to get cluster ID for machine: %q", machine.Name)
+	}
+
+	tags := make(map[string]string, len(instance.Tags.Tag))
+	for _, tag := range instance.Tags.Tag {
+		tags[tag.TagKey] = tag.TagValue
+	}
+
+	if tags[clusterFilterKeyPrefix+clusterID] != clusterFilterValue {
+		return fmt.Errorf("instance %s is not tagged as owned by cluster %s", instance.InstanceId, clusterID)
+	}
+	if tags[clusterFilterName] != machine.Name {
+		return fmt.Errorf("instance %s is tagged with name %q instead of %q", instance.InstanceId, tags[clusterFilterName], machine.Name)
+	}
+	if namespace, ok := tags[machineNamespaceTagKey]; ok && namespace != machine.Namespace {
+		return fmt.Errorf("instance %s is tagged with machine namespace %q instead of %q", instance.InstanceId, namespace, machine.Namespace)
+	}
+	if uid, ok := tags[machineUIDTagKey]; ok && uid != string(machine.UID) {
+		return fmt.Errorf("instance %s is tagged with machine UID %q instead of %q", instance.InstanceId, uid, machine.UID)
+	}
+
+	return nil
+}
+
+// hasMachineUIDTag returns true when the instance is tagged with the UID of the machine
+func hasMachineUIDTag(machine *machinev1beta1.Machine, instance *ecs.Instance) bool {
+	if machine.UID == "" {
+		return false
+	}
+	for _, tag := range instance.Tags.Tag {
+		if tag.TagKey == machineUIDTagKey && tag.TagValue == string(machine.UID) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedInstances returns the instances owned by the machine. The instances of a machine with the same name
+// in another namespace are found by the same tags, they are left out instead of failing the machine.
+func (r *Reconciler) ownedInstances(instances []*ecs.Instance) []*ecs.Instance {
+	owned := make([]*ecs.Instance, 0, len(instances))
+	for _, instance := range instances {
+		if err := verifyInstanceOwnership(r.machine, instance); err != nil {
+			klog.Infof("%s: ignoring instance %s: %v", r.machine.Name, instance.InstanceId, err)
+			continue
+		}
+		owned = append(owned, instance)
+	}
+	return owned
+}
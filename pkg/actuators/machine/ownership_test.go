@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestVerifyInstanceOwnership(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.UID = stubMachineUID
+
+	clusterTag := ecs.Tag{TagKey: clusterFilterKeyPrefix + stubClusterID, TagValue: clusterFilterValue}
+	nameTag := ecs.Tag{TagKey: clusterFilterName, TagValue: machine.Name}
+
+	cases := []struct {
+		name     string
+		tags     []ecs.Tag
+		succeeds bool
+	}{
+		{
+			name: "Owned by the machine",
+			tags: []ecs.Tag{
+				clusterTag,
+				nameTag,
+				{TagKey: machineNamespaceTagKey, TagValue: machine.Namespace},
+				{TagKey: machineUIDTagKey, TagValue: stubMachineUID},
+			},
+			succeeds: true,
+		},
+		{
+			name:     "Launched before the namespace and UID tags",
+			tags:     []ecs.Tag{clusterTag, nameTag},
+			succeeds: true,
+		},
+		{
+			name: "Owned by another cluster",
+			tags: []ecs.Tag{
+				{TagKey: clusterFilterKeyPrefix + "othercluster", TagValue: clusterFilterValue},
+				nameTag,
+			},
+		},
+		{
+			name: "Owned by another machine",
+			tags: []ecs.Tag{
+				clusterTag,
+				{TagKey: clusterFilterName, TagValue: stubWorkerMachineName},
+			},
+		},
+		{
+			name: "Owned by a machine in another namespace",
+			tags: []ecs.Tag{
+				clusterTag,
+				nameTag,
+				{TagKey: machineNamespaceTagKey, TagValue: "other-namespace"},
+			},
+		},
+		{
+			name: "Owned by a previous machine with the same name",
+			tags: []ecs.Tag{
+				clusterTag,
+				nameTag,
+				{TagKey: machineNamespaceTagKey, TagValue: machine.Namespace},
+				{TagKey: machineUIDTagKey, TagValue: "0b8e6f4c-1d2a-4e3b-8c5d-7a9f0e1b2c3d"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			instance := &ecs.Instance{InstanceId: stubInstanceID, Tags: ecs.TagsInDescribeInstances{Tag: tc.tags}}
+
+			err := verifyInstanceOwnership(machine, instance)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestGetMachineInstancesOwnership(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.UID = stubMachineUID
+
+	describeInstancesResponse := stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusRunning, "192.168.1.0")
+	owned := describeInstancesResponse.Instances.Instance[0]
+	owned.Tags.Tag = append(append([]ecs.Tag{}, owned.Tags.Tag...),
+		ecs.Tag{TagKey: machineNamespaceTagKey, TagValue: machine.Namespace},
+		ecs.Tag{TagKey: machineUIDTagKey, TagValue: stubMachineUID},
+	)
+	foreign := describeInstancesResponse.Instances.Instance[0]
+	foreign.InstanceId = "i-foreign"
+	foreign.Tags.Tag = append(append([]ecs.Tag{}, foreign.Tags.Tag...),
+		ecs.Tag{TagKey: machineNamespaceTagKey, TagValue: "other-namespace"},
+		ecs.Tag{TagKey: machineUIDTagKey, TagValue: "0b8e6f4c-1d2a-4e3b-8c5d-7a9f0e1b2c3d"},
+	)
+	describeInstancesResponse.Instances.Instance = []ecs.Instance{foreign, owned}
+
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(describeInstancesResponse, nil).AnyTimes()
+
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		eventRecorder:      record.NewFakeRecorder(10),
+		machine:            machine,
+		providerSpec:       stubProviderConfig(),
+		providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	instances, err := r.getMachineInstances()
+	if assert.NoError(t, err) && assert.Len(t, instances, 1) {
+		assert.Equal(t, stubInstanceID, instances[0].InstanceId)
+	}
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PrivateIPAddressAssignmentsAnnotation is set on MachineSets using a private IP address pool.
+	// It maps each address handed out from the pool to the name of the Machine holding it.
+	PrivateIPAddressAssignmentsAnnotation = "machine.openshift.io/alibabacloud-private-ip-assignments"
+
+	// maxPrivateIPAddressPoolSize is the maximum number of addresses in a private IP address pool
+	maxPrivateIPAddressPoolSize = 1024
+
+	// describeNetworkInterfacesMaxPrivateIPAddresses is the maximum number of private IP addresses
+	// DescribeNetworkInterfaces accepts in a single request
+	describeNetworkInterfacesMaxPrivateIPAddresses = 100
+)
+
+// validatePrivateIPAddress checks that the static private IP address is a valid IPv4 address
+// which is not in use in the vswitch of the instance
+func validatePrivateIPAddress(machine runtimeclient.ObjectKey, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, vSwitchID string, client alibabacloudClient.Client) error {
+	address := machineProviderConfig.PrivateIPAddress
+	if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+		return mapierrors.InvalidMachineConfiguration("invalid private IP address %q: must be an IPv4 address", address)
+	}
+
+	inUse, err := getPrivateIPAddressesInUse(machineProviderConfig, vSwitchID, []string{address}, client)
+	if err != nil {
+		return err
+	}
+	if len(inUse) > 0 {
+		klog.Errorf("%s: private IP address %s is already in use", machine.Name, address)
+		return mapierrors.InvalidMachineConfiguration("private IP address %s is already in use", address)
+	}
+
+	return nil
+}
+
+// getPrivateIPAddressesInUse returns the given addresses which are assigned to a network interface
+// in the vswitch, or in the VPC when the vswitch is taken from the launch template
+func getPrivateIPAddressesInUse(machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, vSwitchID string, addresses []string, client alibabacloudClient.Client) (map[string]bool, error) {
+	request := ecs.CreateDescribeNetworkInterfacesRequest()
+	request.Scheme = "https"
+	request.RegionId = machineProviderConfig.RegionID
+	if vSwitchID != "" {
+		request.VSwitchId = vSwitchID
+	} else {
+		request.VpcId = machineProviderConfig.VpcID
+	}
+	request.PrivateIpAddress = &addresses
+
+	inUse := make(map[string]bool)
+	for {
+		response, err := client.DescribeNetworkInterfaces(request)
+		if err != nil {
+			klog.Errorf("error describing network interfaces: %v", err)
+			return nil, fmt.Errorf("error describing network interfaces: %v", err)
+		}
+
+		for _, networkInterface := range response.NetworkInterfaceSets.NetworkInterfaceSet {
+			inUse[networkInterface.PrivateIpAddress] = true
+			for _, privateIP := range networkInterface.PrivateIpSets.PrivateIpSet {
+				inUse[privateIP.PrivateIpAddress] = true
+			}
+		}
+
+		if response.NextToken == "" {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+
+	// Only report the addresses which were asked for
+	result := make(map[string]bool)
+	for _, address := range addresses {
+		if inUse[address] {
+			result[address] = true
+		}
+	}
+	return result, nil
+}
+
+// isPrivateIPAddressError returns true if RunInstances rejected the requested private IP address
+func isPrivateIPAddressError(err error) bool {
+	var serverError *sdkerrors.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	return strings.HasPrefix(serverError.ErrorCode(), "InvalidPrivateIpAddress")
+}
+
+// expandPrivateIPAddressPool returns the addresses of the pool in the order they are handed out
+func expandPrivateIPAddressPool(pool *alibabacloudproviderv1.PrivateIPAddressPool) ([]string, error) {
+	addresses := make([]string, 0, len(pool.Addresses))
+	seen := make(map[string]bool)
+
+	for _, address := range pool.Addresses {
+		ip := net.ParseIP(address)
+		if ip == nil || ip.To4() == nil {
+			return nil, mapierrors.InvalidMachineConfiguration("invalid address %q in private IP address pool: must be an IPv4 address", address)
+		}
+		if !seen[ip.String()] {
+			seen[ip.String()] = true
+			addresses = append(addresses, ip.String())
+		}
+	}
+
+	if pool.CIDR != "" {
+		_, ipNet, err := net.ParseCIDR(pool.CIDR)
+		if err != nil || ipNet.IP.To4() == nil {
+			return nil, mapierrors.InvalidMachineConfiguration("invalid CIDR %q in private IP address pool: must be an IPv4 CIDR block", pool.CIDR)
+		}
+
+		ones, bits := ipNet.Mask.Size()
+		size := uint64(1) << uint(bits-ones)
+		if size > maxPrivateIPAddressPoolSize {
+			return nil, mapierrors.InvalidMachineConfiguration("CIDR %q in private IP address pool contains more than %d addresses", pool.CIDR, maxPrivateIPAddressPoolSize)
+		}
+
+		first, last := uint64(0), size-1
+		if size > 2 {
+			// Skip the network and broadcast addresses
+			first, last = 1, size-2
+		}
+
+		base := binary.BigEndian.Uint32(ipNet.IP.To4())
+		for i := first; i <= last; i++ {
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, base+uint32(i))
+			if !seen[ip.String()] {
+				seen[ip.String()] = true
+				addresses = append(addresses, ip.String())
+			}
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, mapierrors.InvalidMachineConfiguration("private IP address pool has no addresses")
+	}
+
+	return addresses, nil
+}
+
+// getPrivateIPAddressAssignments returns the addresses handed out from the pool of the MachineSet
+func getPrivateIPAddressAssignments(machineSet *machinev1beta1.MachineSet) (map[string]string, error) {
+	assignments := make(map[string]string)
+	if value, ok := machineSet.Annotations[PrivateIPAddressAssignmentsAnnotation]; ok && value != "" {
+		if err := json.Unmarshal([]byte(value), &assignments); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation on machineset %s: %w", PrivateIPAddressAssignmentsAnnotation, machineSet.Name, err)
+		}
+	}
+	return assignments, nil
+}
+
+// setPrivateIPAddressAssignments records the addresses handed out from the pool of the MachineSet
+func setPrivateIPAddressAssignments(machineSet *machinev1beta1.MachineSet, assignments map[string]string) error {
+	value, err := json.Marshal(assignments)
+	if err != nil {
+		return err
+	}
+	if machineSet.Annotations == nil {
+		machineSet.Annotations = make(map[string]string)
+	}
+	machineSet.Annotations[PrivateIPAddressAssignmentsAnnotation] = string(value)
+	return nil
+}
+
+// getOwnerMachineSet returns the MachineSet owning the Machine
+func (r *Reconciler) getOwnerMachineSet() (*machinev1beta1.MachineSet, error) {
+	owner := metav1.GetControllerOf(r.machine)
+	if owner == nil || owner.Kind != machineSetKind {
+		return nil, mapierrors.InvalidMachineConfiguration("private IP address pool requires machine %q to be owned by a MachineSet", r.machine.Name)
+	}
+
+	machineSet := &machinev1beta1.MachineSet{}
+	if err := r.client.Get(r.Context, runtimeclient.ObjectKey{Namespace: r.machine.Namespace, Name: owner.Name}, machineSet); err != nil {
+		return nil, fmt.Errorf("error getting machineset %s: %w", owner.Name, err)
+	}
+
+	return machineSet, nil
+}
+
+// pinPrivateIPAddress hands out an address from the private IP address pool to the Machine and records it
+// on the MachineSet and in the provider status. Addresses held by other Machines of the MachineSet, and
+// addresses in use in the vswitch, are skipped. Addresses of deleted Machines are handed out again.
+func (r *Reconciler) pinPrivateIPAddress(providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	if providerSpec.PrivateIPAddressPool == nil {
+		return nil
+	}
+
+	if providerSpec.PrivateIPAddress != "" {
+		return mapierrors.InvalidMachineConfiguration("privateIpAddress and privateIpAddressPool cannot be set together")
+	}
+
+	if r.providerStatus.PrivateIPAddress != nil && *r.providerStatus.PrivateIPAddress != "" {
+		klog.Infof("%s: using pinned private IP address %s", r.machine.Name, *r.providerStatus.PrivateIPAddress)
+		providerSpec.PrivateIPAddress = *r.providerStatus.PrivateIPAddress
+		return nil
+	}
+
+	candidates, err := expandPrivateIPAddressPool(providerSpec.PrivateIPAddressPool)
+	if err != nil {
+		return err
+	}
+
+	machineSet, err := r.getOwnerMachineSet()
+	if err != nil {
+		return err
+	}
+
+	assignments, err := getPrivateIPAddressAssignments(machineSet)
+	if err != nil {
+		return err
+	}
+
+	address, err := r.findAssignedPrivateIPAddress(assignments)
+	if err != nil {
+		return err
+	}
+
+	if address == "" {
+		address, err = r.findFreePrivateIPAddress(providerSpec, candidates, assignments)
+		if err != nil {
+			return err
+		}
+
+		assignments[address] = r.machine.Name
+		if err := setPrivateIPAddressAssignments(machineSet, assignments); err != nil {
+			return err
+		}
+		if err := r.client.Update(r.Context, machineSet); err != nil {
+			if apierrors.IsConflict(err) {
+				klog.Infof("%s: conflict recording private IP address %s on machineset %s, requeueing", r.machine.Name, address, machineSet.Name)
+				return &mapierrors.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+			}
+			return fmt.Errorf("error recording private IP address on machineset %s: %w", machineSet.Name, err)
+		}
+		klog.Infof("%s: assigned private IP address %s from the pool of machineset %s", r.machine.Name, address, machineSet.Name)
+	}
+
+	providerSpec.PrivateIPAddress = address
+	r.providerStatus.PrivateIPAddress = &address
+
+	return nil
+}
+
+// findAssignedPrivateIPAddress returns the address already handed out to the Machine, if any.
+// Addresses held by Machines which no longer exist are removed from the assignments.
+func (r *Reconciler) findAssignedPrivateIPAddress(assignments map[string]string) (string, error) {
+	address := ""
+	for ip, machineName := range assignments {
+		if machineName == r.machine.Name {
+			address = ip
+			continue
+		}
+
+		err := r.client.Get(r.Context, runtimeclient.ObjectKey{Namespace: r.machine.Namespace, Name: machineName}, &machinev1beta1.Machine{})
+		if apierrors.IsNotFound(err) {
+			klog.Infof("%s: releasing private IP address %s of deleted machine %s", r.machine.Name, ip, machineName)
+			delete(assignments, ip)
+		} else if err != nil {
+			return "", fmt.Errorf("error getting machine %s: %w", machineName, err)
+		}
+	}
+	return address, nil
+}
+
+// findFreePrivateIPAddress returns the first address of the pool which is neither handed out nor in use in the vswitch
+func (r *Reconciler) findFreePrivateIPAddress(providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, candidates []string, assignments map[string]string) (string, error) {
+	free := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if _, ok := assignments[candidate]; !ok {
+			free = append(free, candidate)
+		}
+	}
+
+	vSwitchID := ""
+	if providerSpec.VSwitch.Type != "" {
+		machineKey := runtimeclient.ObjectKey{Name: r.machine.Name, Namespace: r.machine.Namespace}
+		id, err := getVSwitchID(machineKey, providerSpec, r.alibabacloudClient)
+		if err != nil {
+			return "", err
+		}
+		vSwitchID = id
+	}
+
+	for start := 0; start < len(free); start += describeNetworkInterfacesMaxPrivateIPAddresses {
+		end := start + describeNetworkInterfacesMaxPrivateIPAddresses
+		if end > len(free) {
+			end = len(free)
+		}
+
+		inUse, err := getPrivateIPAddressesInUse(providerSpec, vSwitchID, free[start:end], r.alibabacloudClient)
+		if err != nil {
+			return "", err
+		}
+
+		for _, candidate := range free[start:end] {
+			if !inUse[candidate] {
+				return candidate, nil
+			}
+		}
+	}
+
+	// Addresses are handed out again once the Machines holding them are deleted
+	klog.Infof("%s: no free address in the private IP address pool, requeueing", r.machine.Name)
+	return "", &mapierrors.RequeueAfterError{RequeueAfter: requeueAfterFatalSeconds * time.Second}
+}
+
+// releasePrivateIPAddress removes the address handed out to the Machine from the assignments of the MachineSet
+func (r *Reconciler) releasePrivateIPAddress() error {
+	if r.providerSpec.PrivateIPAddressPool == nil {
+		return nil
+	}
+
+	owner := metav1.GetControllerOf(r.machine)
+	if owner == nil || owner.Kind != machineSetKind {
+		return nil
+	}
+
+	machineSet := &machinev1beta1.MachineSet{}
+	if err := r.client.Get(r.Context, runtimeclient.ObjectKey{Namespace: r.machine.Namespace, Name: owner.Name}, machineSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting machineset %s: %w", owner.Name, err)
+	}
+
+	assignments, err := getPrivateIPAddressAssignments(machineSet)
+	if err != nil {
+		return err
+	}
+
+	released := false
+	for ip, machineName := range assignments {
+		if machineName == r.machine.Name {
+			delete(assignments, ip)
+			released = true
+			klog.Infof("%s: released private IP address %s to the pool of machineset %s", r.machine.Name, ip, machineSet.Name)
+		}
+	}
+	if !released {
+		return nil
+	}
+
+	if err := setPrivateIPAddressAssignments(machineSet, assignments); err != nil {
+		return err
+	}
+	if err := r.client.Update(r.Context, machineSet); err != nil {
+		return fmt.Errorf("error releasing private IP address on machineset %s: %w", machineSet.Name, err)
+	}
+
+	return nil
+}
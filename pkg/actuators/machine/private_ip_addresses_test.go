@@ -0,0 +1,323 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubectl/pkg/scheme"
+	"k8s.io/utils/pointer"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func stubNetworkInterfacesResponse(addresses ...string) *ecs.DescribeNetworkInterfacesResponse {
+	response := &ecs.DescribeNetworkInterfacesResponse{}
+	for _, address := range addresses {
+		response.NetworkInterfaceSets.NetworkInterfaceSet = append(response.NetworkInterfaceSets.NetworkInterfaceSet, ecs.NetworkInterfaceSet{
+			PrivateIpAddress: address,
+		})
+	}
+	return response
+}
+
+func TestExpandPrivateIPAddressPool(t *testing.T) {
+	cases := []struct {
+		name              string
+		pool              *alibabacloudproviderv1.PrivateIPAddressPool
+		expectedAddresses []string
+		expectError       bool
+	}{
+		{
+			name:              "Addresses",
+			pool:              &alibabacloudproviderv1.PrivateIPAddressPool{Addresses: []string{"10.0.0.10", "10.0.0.11", "10.0.0.10"}},
+			expectedAddresses: []string{"10.0.0.10", "10.0.0.11"},
+		},
+		{
+			name:              "CIDR",
+			pool:              &alibabacloudproviderv1.PrivateIPAddressPool{CIDR: "10.0.1.16/29"},
+			expectedAddresses: []string{"10.0.1.17", "10.0.1.18", "10.0.1.19", "10.0.1.20", "10.0.1.21", "10.0.1.22"},
+		},
+		{
+			name:              "Addresses before CIDR",
+			pool:              &alibabacloudproviderv1.PrivateIPAddressPool{Addresses: []string{"10.0.1.18"}, CIDR: "10.0.1.16/30"},
+			expectedAddresses: []string{"10.0.1.18", "10.0.1.17"},
+		},
+		{
+			name:        "Invalid address",
+			pool:        &alibabacloudproviderv1.PrivateIPAddressPool{Addresses: []string{"fd00::1"}},
+			expectError: true,
+		},
+		{
+			name:        "CIDR too large",
+			pool:        &alibabacloudproviderv1.PrivateIPAddressPool{CIDR: "10.0.0.0/16"},
+			expectError: true,
+		},
+		{
+			name:        "Empty pool",
+			pool:        &alibabacloudproviderv1.PrivateIPAddressPool{},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			addresses, err := expandPrivateIPAddressPool(tc.pool)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAddresses, addresses)
+		})
+	}
+}
+
+func TestValidatePrivateIPAddress(t *testing.T) {
+	cases := []struct {
+		name        string
+		address     string
+		inUse       []string
+		expectCheck bool
+		succeeds    bool
+	}{
+		{
+			name:        "Free address",
+			address:     "10.0.0.10",
+			expectCheck: true,
+			succeeds:    true,
+		},
+		{
+			name:        "Address in use",
+			address:     "10.0.0.10",
+			inUse:       []string{"10.0.0.10"},
+			expectCheck: true,
+		},
+		{
+			name:    "Invalid address",
+			address: "10.0.0",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			if tc.expectCheck {
+				mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).DoAndReturn(
+					func(request *ecs.DescribeNetworkInterfacesRequest) (*ecs.DescribeNetworkInterfacesResponse, error) {
+						assert.Equal(t, stubVSwitchID, request.VSwitchId)
+						assert.Equal(t, []string{tc.address}, *request.PrivateIpAddress)
+						return stubNetworkInterfacesResponse(tc.inUse...), nil
+					}).Times(1)
+			}
+
+			providerConfig := stubProviderConfig()
+			providerConfig.PrivateIPAddress = tc.address
+
+			machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+			err := validatePrivateIPAddress(machineKey, providerConfig, stubVSwitchID, mockAlibabaCloudClient)
+			if tc.succeeds {
+				assert.NoError(t, err)
+				return
+			}
+			machineErr, ok := err.(*machinecontroller.MachineError)
+			if assert.True(t, ok, "expected a machine error, got %v", err) {
+				assert.Equal(t, machinev1beta1.InvalidConfigurationMachineError, machineErr.Reason)
+			}
+		})
+	}
+}
+
+func TestIsPrivateIPAddressError(t *testing.T) {
+	assert.True(t, isPrivateIPAddressError(sdkerrors.NewServerError(http.StatusBadRequest, `{"Code": "InvalidPrivateIpAddress.Duplicated"}`, "")))
+	assert.False(t, isPrivateIPAddressError(sdkerrors.NewServerError(http.StatusBadRequest, `{"Code": "InvalidVSwitchId.NotFound"}`, "")))
+	assert.False(t, isPrivateIPAddressError(fmt.Errorf("error")))
+}
+
+func TestPinPrivateIPAddress(t *testing.T) {
+	pool := &alibabacloudproviderv1.PrivateIPAddressPool{Addresses: []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}}
+
+	cases := []struct {
+		name                string
+		pinnedAddress       *string
+		assignments         string
+		inUse               []string
+		expectedAddress     string
+		expectedAssignments string
+		expectRequeue       bool
+	}{
+		{
+			name:            "Pinned address",
+			pinnedAddress:   pointer.StringPtr("10.0.0.12"),
+			assignments:     `{"10.0.0.12":"` + stubWorkerMachineName + `"}`,
+			expectedAddress: "10.0.0.12",
+		},
+		{
+			name:                "Address of a deleted machine is handed out again",
+			assignments:         `{"10.0.0.10":"` + stubMasterMachineName + `","10.0.0.11":"deleted-machine"}`,
+			expectedAddress:     "10.0.0.11",
+			expectedAssignments: `{"10.0.0.10":"` + stubMasterMachineName + `","10.0.0.11":"` + stubWorkerMachineName + `"}`,
+		},
+		{
+			name:                "Address in use in the vswitch is skipped",
+			inUse:               []string{"10.0.0.10"},
+			expectedAddress:     "10.0.0.11",
+			expectedAssignments: `{"10.0.0.11":"` + stubWorkerMachineName + `"}`,
+		},
+		{
+			name:                "Address already handed out to the machine",
+			assignments:         `{"10.0.0.12":"` + stubWorkerMachineName + `"}`,
+			expectedAddress:     "10.0.0.12",
+			expectedAssignments: `{"10.0.0.12":"` + stubWorkerMachineName + `"}`,
+		},
+		{
+			name:          "Pool exhausted",
+			assignments:   `{"10.0.0.10":"` + stubMasterMachineName + `"}`,
+			inUse:         []string{"10.0.0.11", "10.0.0.12"},
+			expectRequeue: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Return(stubNetworkInterfacesResponse(tc.inUse...), nil).AnyTimes()
+
+			machineSet := &machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        stubMachineSetName,
+					Namespace:   defaultNamespace,
+					Annotations: map[string]string{},
+				},
+			}
+			if tc.assignments != "" {
+				machineSet.Annotations[PrivateIPAddressAssignmentsAnnotation] = tc.assignments
+			}
+
+			master, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine, err := stubWorkerMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine.OwnerReferences = []metav1.OwnerReference{
+				{
+					APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+					Kind:       machineSetKind,
+					Name:       stubMachineSetName,
+					Controller: pointer.BoolPtr(true),
+				},
+			}
+
+			fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, machineSet, master, machine)
+
+			providerSpec := stubProviderConfig()
+			providerSpec.PrivateIPAddressPool = pool
+
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				client:             fakeClient,
+				machine:            machine,
+				providerSpec:       providerSpec,
+				providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{PrivateIPAddress: tc.pinnedAddress},
+			})
+
+			pinnedSpec := providerSpec.DeepCopy()
+			err = r.pinPrivateIPAddress(pinnedSpec)
+			if tc.expectRequeue {
+				_, ok := err.(*machinecontroller.RequeueAfterError)
+				assert.True(t, ok, "expected a requeue error, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAddress, pinnedSpec.PrivateIPAddress)
+			if assert.NotNil(t, r.providerStatus.PrivateIPAddress) {
+				assert.Equal(t, tc.expectedAddress, *r.providerStatus.PrivateIPAddress)
+			}
+
+			if tc.expectedAssignments != "" {
+				updated := &machinev1beta1.MachineSet{}
+				assert.NoError(t, fakeClient.Get(context.Background(), runtimeclient.ObjectKey{Namespace: defaultNamespace, Name: stubMachineSetName}, updated))
+				assert.JSONEq(t, tc.expectedAssignments, updated.Annotations[PrivateIPAddressAssignmentsAnnotation])
+			}
+		})
+	}
+}
+
+func TestReleasePrivateIPAddress(t *testing.T) {
+	machineSet := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stubMachineSetName,
+			Namespace: defaultNamespace,
+			Annotations: map[string]string{
+				PrivateIPAddressAssignmentsAnnotation: `{"10.0.0.10":"` + stubMasterMachineName + `","10.0.0.11":"` + stubWorkerMachineName + `"}`,
+			},
+		},
+	}
+
+	machine, err := stubWorkerMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: machinev1beta1.SchemeGroupVersion.String(),
+			Kind:       machineSetKind,
+			Name:       stubMachineSetName,
+			Controller: pointer.BoolPtr(true),
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, machineSet, machine)
+
+	providerSpec := stubProviderConfig()
+	providerSpec.PrivateIPAddressPool = &alibabacloudproviderv1.PrivateIPAddressPool{CIDR: "10.0.0.8/29"}
+
+	r := NewReconciler(&machineScope{
+		Context:        context.Background(),
+		client:         fakeClient,
+		machine:        machine,
+		providerSpec:   providerSpec,
+		providerStatus: &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	assert.NoError(t, r.releasePrivateIPAddress())
+
+	updated := &machinev1beta1.MachineSet{}
+	assert.NoError(t, fakeClient.Get(context.Background(), runtimeclient.ObjectKey{Namespace: defaultNamespace, Name: stubMachineSetName}, updated))
+	assert.JSONEq(t, `{"10.0.0.10":"`+stubMasterMachineName+`"}`, updated.Annotations[PrivateIPAddressAssignmentsAnnotation])
+
+	// Releasing again is a no-op
+	assert.NoError(t, r.releasePrivateIPAddress())
+}
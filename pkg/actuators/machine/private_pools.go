@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"errors"
+	"strings"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+const (
+	// MachineCapacityTypeLabelName is the label recording the capacity the instance of a Machine was created from
+	MachineCapacityTypeLabelName = "machine.openshift.io/capacity-type"
+
+	// ReservedCapacityType capacity type of instances created from a private pool
+	ReservedCapacityType = "reserved"
+	// SpotCapacityType capacity type of spot instances
+	SpotCapacityType = "spot"
+	// OnDemandCapacityType capacity type of pay-as-you-go and subscription instances created from the public pool
+	OnDemandCapacityType = "on-demand"
+)
+
+// setPrivatePoolOptions sets the private pool match criteria and ID on the RunInstances request
+func setPrivatePoolOptions(request *ecs.RunInstancesRequest, options *alibabacloudproviderv1.PrivatePoolOptions) error {
+	switch options.MatchCriteria {
+	case "", alibabacloudproviderv1.OpenPrivatePoolMatchCriteria, alibabacloudproviderv1.NonePrivatePoolMatchCriteria:
+		if options.ID != "" {
+			return mapierrors.InvalidMachineConfiguration("private pool id can only be set when the match criteria is %s", alibabacloudproviderv1.TargetPrivatePoolMatchCriteria)
+		}
+	case alibabacloudproviderv1.TargetPrivatePoolMatchCriteria:
+		if options.ID == "" {
+			return mapierrors.InvalidMachineConfiguration("private pool id must be set when the match criteria is %s", alibabacloudproviderv1.TargetPrivatePoolMatchCriteria)
+		}
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid private pool match criteria: %s. Allowed options are: %s,%s,%s",
+			options.MatchCriteria,
+			alibabacloudproviderv1.OpenPrivatePoolMatchCriteria,
+			alibabacloudproviderv1.TargetPrivatePoolMatchCriteria,
+			alibabacloudproviderv1.NonePrivatePoolMatchCriteria)
+	}
+
+	switch options.FallbackMatchCriteria {
+	case "":
+	case alibabacloudproviderv1.OpenPrivatePoolMatchCriteria, alibabacloudproviderv1.NonePrivatePoolMatchCriteria:
+		if options.MatchCriteria != alibabacloudproviderv1.TargetPrivatePoolMatchCriteria {
+			return mapierrors.InvalidMachineConfiguration("private pool fallback match criteria can only be set when the match criteria is %s", alibabacloudproviderv1.TargetPrivatePoolMatchCriteria)
+		}
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid private pool fallback match criteria: %s. Allowed options are: %s,%s",
+			options.FallbackMatchCriteria,
+			alibabacloudproviderv1.OpenPrivatePoolMatchCriteria,
+			alibabacloudproviderv1.NonePrivatePoolMatchCriteria)
+	}
+
+	request.PrivatePoolOptionsMatchCriteria = string(options.MatchCriteria)
+	request.PrivatePoolOptionsId = options.ID
+
+	return nil
+}
+
+// setPrivatePoolFallback switches the RunInstances request to the fallback match criteria.
+// It returns false when the private pool options have no fallback.
+func setPrivatePoolFallback(request *ecs.RunInstancesRequest, options *alibabacloudproviderv1.PrivatePoolOptions) bool {
+	if options == nil || options.MatchCriteria != alibabacloudproviderv1.TargetPrivatePoolMatchCriteria || options.FallbackMatchCriteria == "" {
+		return false
+	}
+
+	// The request already fell back
+	if request.PrivatePoolOptionsId == "" {
+		return false
+	}
+
+	request.PrivatePoolOptionsMatchCriteria = string(options.FallbackMatchCriteria)
+	request.PrivatePoolOptionsId = ""
+
+	return true
+}
+
+// isPrivatePoolError returns true if RunInstances failed because the targeted private pool cannot be used,
+// for example because it has no capacity left, it expired, or it does not match the instance
+func isPrivatePoolError(err error) bool {
+	var serverError *sdkerrors.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	code := serverError.ErrorCode()
+	return strings.Contains(code, "PrivatePool") || strings.Contains(code, "NoStock")
+}
+
+// getCapacityType returns the capacity the instance was created from
+func getCapacityType(instance *ecs.Instance) string {
+	switch {
+	case instance.PrivatePoolOptionsId != "":
+		return ReservedCapacityType
+	case isSpotInstance(instance):
+		return SpotCapacityType
+	default:
+		return OnDemandCapacityType
+	}
+}
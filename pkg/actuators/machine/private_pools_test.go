@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"net/http"
+	"testing"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+const stubPrivatePoolID = "crp-bp1reserved"
+
+func TestSetPrivatePoolOptions(t *testing.T) {
+	cases := []struct {
+		name                  string
+		options               *alibabacloudproviderv1.PrivatePoolOptions
+		expectedMatchCriteria string
+		expectedID            string
+		succeeds              bool
+	}{
+		{
+			name:     "Default match criteria",
+			options:  &alibabacloudproviderv1.PrivatePoolOptions{},
+			succeeds: true,
+		},
+		{
+			name:                  "Open private pools",
+			options:               &alibabacloudproviderv1.PrivatePoolOptions{MatchCriteria: alibabacloudproviderv1.OpenPrivatePoolMatchCriteria},
+			expectedMatchCriteria: "Open",
+			succeeds:              true,
+		},
+		{
+			name: "Target private pool with fallback",
+			options: &alibabacloudproviderv1.PrivatePoolOptions{
+				MatchCriteria:         alibabacloudproviderv1.TargetPrivatePoolMatchCriteria,
+				ID:                    stubPrivatePoolID,
+				FallbackMatchCriteria: alibabacloudproviderv1.OpenPrivatePoolMatchCriteria,
+			},
+			expectedMatchCriteria: "Target",
+			expectedID:            stubPrivatePoolID,
+			succeeds:              true,
+		},
+		{
+			name:    "Target private pool without ID",
+			options: &alibabacloudproviderv1.PrivatePoolOptions{MatchCriteria: alibabacloudproviderv1.TargetPrivatePoolMatchCriteria},
+		},
+		{
+			name:    "ID without target match criteria",
+			options: &alibabacloudproviderv1.PrivatePoolOptions{MatchCriteria: alibabacloudproviderv1.OpenPrivatePoolMatchCriteria, ID: stubPrivatePoolID},
+		},
+		{
+			name:    "Fallback without target match criteria",
+			options: &alibabacloudproviderv1.PrivatePoolOptions{FallbackMatchCriteria: alibabacloudproviderv1.OpenPrivatePoolMatchCriteria},
+		},
+		{
+			name: "Invalid fallback match criteria",
+			options: &alibabacloudproviderv1.PrivatePoolOptions{
+				MatchCriteria:         alibabacloudproviderv1.TargetPrivatePoolMatchCriteria,
+				ID:                    stubPrivatePoolID,
+				FallbackMatchCriteria: alibabacloudproviderv1.TargetPrivatePoolMatchCriteria,
+			},
+		},
+		{
+			name:    "Invalid match criteria",
+			options: &alibabacloudproviderv1.PrivatePoolOptions{MatchCriteria: "Reserved"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := ecs.CreateRunInstancesRequest()
+			err := setPrivatePoolOptions(request, tc.options)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			if tc.succeeds {
+				assert.Equal(t, tc.expectedMatchCriteria, request.PrivatePoolOptionsMatchCriteria)
+				assert.Equal(t, tc.expectedID, request.PrivatePoolOptionsId)
+			}
+		})
+	}
+}
+
+func TestRunInstancesPrivatePoolFallback(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capacityErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "Invalid.PrivatePoolOptions.InsufficientCapacity"}`, "")
+
+	cases := []struct {
+		name             string
+		fallback         alibabacloudproviderv1.PrivatePoolMatchCriteria
+		expectedRequests []string
+		succeeds         bool
+	}{
+		{
+			name:             "Fall back to open capacity",
+			fallback:         alibabacloudproviderv1.OpenPrivatePoolMatchCriteria,
+			expectedRequests: []string{"Target/" + stubPrivatePoolID, "Open/"},
+			succeeds:         true,
+		},
+		{
+			name:             "No fallback",
+			expectedRequests: []string{"Target/" + stubPrivatePoolID},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubDescribeImagesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().ListResourceGroups(gomock.Any()).Return(stubListResourceGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeSecurityGroups(gomock.Any()).Return(stubDescribeSecurityGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).Return(stubDescribeVSwitchesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, stubInstanceStatus, "192.168.1.0"), nil).AnyTimes()
+
+			requests := make([]string, 0)
+			mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error) {
+					requests = append(requests, request.PrivatePoolOptionsMatchCriteria+"/"+request.PrivatePoolOptionsId)
+					if request.PrivatePoolOptionsId != "" {
+						return nil, capacityErr
+					}
+					return stubRunInstancesResponse(), nil
+				}).Times(len(tc.expectedRequests))
+
+			providerConfig := stubProviderConfig()
+			providerConfig.PrivatePoolOptions = &alibabacloudproviderv1.PrivatePoolOptions{
+				MatchCriteria:         alibabacloudproviderv1.TargetPrivatePoolMatchCriteria,
+				ID:                    stubPrivatePoolID,
+				FallbackMatchCriteria: tc.fallback,
+			}
+
+			_, err := runInstances(machine, providerConfig, "", new(int32), mockAlibabaCloudClient, record.NewFakeRecorder(10))
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			assert.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
+
+func TestIsPrivatePoolError(t *testing.T) {
+	assert.True(t, isPrivatePoolError(sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "Invalid.PrivatePoolOptions.InsufficientCapacity"}`, "")))
+	assert.True(t, isPrivatePoolError(sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "OperationDenied.NoStock"}`, "")))
+	assert.False(t, isPrivatePoolError(sdkerrors.NewServerError(http.StatusBadRequest, `{"Code": "InvalidVSwitchId.NotFound"}`, "")))
+}
+
+func TestGetCapacityType(t *testing.T) {
+	assert.Equal(t, ReservedCapacityType, getCapacityType(&ecs.Instance{PrivatePoolOptionsId: stubPrivatePoolID}))
+	assert.Equal(t, SpotCapacityType, getCapacityType(&ecs.Instance{SpotStrategy: string(alibabacloudproviderv1.SpotAsPriceGoStrategy)}))
+	assert.Equal(t, OnDemandCapacityType, getCapacityType(&ecs.Instance{SpotStrategy: string(alibabacloudproviderv1.NoSpotStrategy)}))
+}
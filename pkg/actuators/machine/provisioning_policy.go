@@ -0,0 +1,246 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// provisioningTimeoutEventReason is the reason of the event recorded when the action of the provisioning policy is taken
+	provisioningTimeoutEventReason = "ProvisioningTimeout"
+
+	// defaultProvisioningTimeout is how long an instance may stay Pending or Starting when the provisioning policy has no timeout
+	defaultProvisioningTimeout = 15 * time.Minute
+)
+
+// validateProvisioningPolicy checks the provisioning policy of the provider spec
+func validateProvisioningPolicy(provisioningPolicy *alibabacloudproviderv1.ProvisioningPolicy) error {
+	if provisioningPolicy == nil {
+		return nil
+	}
+
+	switch provisioningPolicy.TimeoutAction {
+	case "", alibabacloudproviderv1.RestartProvisioningTimeoutAction, alibabacloudproviderv1.RecreateProvisioningTimeoutAction, alibabacloudproviderv1.FailProvisioningTimeoutAction:
+	default:
+		return mapierrors.InvalidMachineConfiguration("invalid provisioning timeout action %s. Valid values are %s, %s and %s", provisioningPolicy.TimeoutAction,
+			alibabacloudproviderv1.RestartProvisioningTimeoutAction, alibabacloudproviderv1.RecreateProvisioningTimeoutAction, alibabacloudproviderv1.FailProvisioningTimeoutAction)
+	}
+
+	if provisioningPolicy.Timeout != nil && provisioningPolicy.Timeout.Duration <= 0 {
+		return mapierrors.InvalidMachineConfiguration("invalid provisioning timeout %v: must be positive", provisioningPolicy.Timeout.Duration)
+	}
+
+	return nil
+}
+
+// getProvisioningTimeout returns how long an instance may stay Pending or Starting, 15 minutes by default
+func getProvisioningTimeout(provisioningPolicy *alibabacloudproviderv1.ProvisioningPolicy) time.Duration {
+	if provisioningPolicy == nil || provisioningPolicy.Timeout == nil {
+		return defaultProvisioningTimeout
+	}
+	return provisioningPolicy.Timeout.Duration
+}
+
+// getProvisioningTimeoutAction returns the action taken once the provisioning timeout passed, Restart by default
+func getProvisioningTimeoutAction(provisioningPolicy *alibabacloudproviderv1.ProvisioningPolicy) alibabacloudproviderv1.ProvisioningTimeoutAction {
+	if provisioningPolicy == nil || provisioningPolicy.TimeoutAction == "" {
+		return alibabacloudproviderv1.RestartProvisioningTimeoutAction
+	}
+	return provisioningPolicy.TimeoutAction
+}
+
+// isInstanceProvisioning returns true while the launch or the restart of the instance is in progress
+func (r *Reconciler) isInstanceProvisioning(instance *ecs.Instance) bool {
+	switch r.instanceOperation() {
+	case alibabacloudproviderv1.LaunchInstanceOperation:
+		return isInstanceLaunching(instance)
+	case alibabacloudproviderv1.RestartInstanceOperation:
+		return instance.Status != ECSInstanceStatusRunning
+	default:
+		return false
+	}
+}
+
+// reconcileProvisioning requeues the machine until the instance finishes provisioning.
+// Once the timeout of the provisioning policy passed, the action of the policy is taken on the instance.
+func (r *Reconciler) reconcileProvisioning(instance *ecs.Instance) error {
+	if err := r.machineScope.setProviderStatus(instance, conditionSuccess()); err != nil {
+		return err
+	}
+
+	// A restarted instance is started again once it is Stopped
+	if r.instanceOperation() == alibabacloudproviderv1.RestartInstanceOperation && instance.Status == ECSInstanceStatusStopped {
+		if err := r.startInstance(instance); err != nil {
+			return err
+		}
+		return r.requeueInstanceOperation()
+	}
+
+	provisioningPolicy := r.providerSpec.ProvisioningPolicy
+	timeout := getProvisioningTimeout(provisioningPolicy)
+	if provisioningPolicy == nil || r.instanceOperationDuration() < timeout {
+		return r.requeueInstanceOperation()
+	}
+
+	message := fmt.Sprintf("Instance %s is still %s after %v", instance.InstanceId, instance.Status, timeout)
+
+	// The action is only taken once, the machine fails when the instance does not finish provisioning after it
+	action := getProvisioningTimeoutAction(provisioningPolicy)
+	if r.providerStatus.ProvisioningRecoveries > 0 {
+		action = alibabacloudproviderv1.FailProvisioningTimeoutAction
+	}
+
+	switch action {
+	case alibabacloudproviderv1.RestartProvisioningTimeoutAction:
+		// ECS does not stop Pending or Starting instances, the instance is restarted once it can be stopped
+		// and the machine fails when it still can not after twice the timeout
+		if !canStopInstance(instance) {
+			if r.instanceOperationDuration() < 2*timeout {
+				klog.Infof("%s: instance %s is %s, it is restarted once it can be stopped", r.machine.Name, instance.InstanceId, instance.Status)
+				return r.requeueInstanceOperation()
+			}
+			return r.failProvisioningInstance(instance, fmt.Sprintf("Instance %s is still %s after %v", instance.InstanceId, instance.Status, 2*timeout))
+		}
+		return r.restartProvisioningInstance(instance, message)
+	case alibabacloudproviderv1.RecreateProvisioningTimeoutAction:
+		return r.recreateProvisioningInstance(instance, message)
+	default:
+		return r.failProvisioningInstance(instance, message)
+	}
+}
+
+// canStopInstance returns true when ECS accepts to stop the instance
+func canStopInstance(instance *ecs.Instance) bool {
+	return instance.Status == ECSInstanceStatusRunning || instance.Status == ECSInstanceStatusStopping
+}
+
+// restartProvisioningInstance force stops the instance, which is started again once it is Stopped
+func (r *Reconciler) restartProvisioningInstance(instance *ecs.Instance, message string) error {
+	request := ecs.CreateStopInstancesRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.InstanceId = &[]string{instance.InstanceId}
+	request.ForceStop = requests.NewBoolean(true)
+
+	if _, err := r.alibabacloudClient.StopInstances(request); err != nil {
+		return fmt.Errorf("failed to force stop instance %s: %w", instance.InstanceId, err)
+	}
+
+	r.providerStatus.ProvisioningRecoveries++
+	r.startInstanceOperation(alibabacloudproviderv1.RestartInstanceOperation)
+	r.recordProvisioningTimeout(alibabacloudproviderv1.InstanceRestartedConditionReason, message+", restarting it")
+	return r.requeueInstanceOperation()
+}
+
+// startInstance starts the instance which was stopped to restart it
+func (r *Reconciler) startInstance(instance *ecs.Instance) error {
+	request := ecs.CreateStartInstanceRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.InstanceId = instance.InstanceId
+
+	if _, err := r.alibabacloudClient.StartInstance(request); err != nil {
+		return fmt.Errorf("failed to start instance %s: %w", instance.InstanceId, err)
+	}
+
+	klog.Infof("%s: started instance %s", r.machine.Name, instance.InstanceId)
+	return nil
+}
+
+// recreateProvisioningInstance releases the instance, another one is launched by the next update of the machine
+func (r *Reconciler) recreateProvisioningInstance(instance *ecs.Instance, message string) error {
+	// instances with deletion protection can not be released, turn it off first
+	if instance.DeletionProtection {
+		if err := r.disableInstanceDeletionProtection(instance); err != nil {
+			return err
+		}
+	}
+
+	request := ecs.CreateDeleteInstancesRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.InstanceId = &[]string{instance.InstanceId}
+	request.Force = requests.NewBoolean(true)
+
+	if _, err := r.alibabacloudClient.DeleteInstances(request); err != nil {
+		return fmt.Errorf("failed to release instance %s: %w", instance.InstanceId, err)
+	}
+
+	if err := r.machineScope.setProviderStatus(nil, conditionSuccess()); err != nil {
+		return err
+	}
+	r.providerStatus.ProvisioningRecoveries++
+	r.startInstanceOperation(alibabacloudproviderv1.RecreateInstanceOperation)
+	r.recordProvisioningTimeout(alibabacloudproviderv1.InstanceRecreatedConditionReason, message+", releasing it to launch another instance")
+	return r.requeueInstanceOperation()
+}
+
+// failProvisioningInstance fails the machine of the instance which did not finish provisioning in time,
+// the ProvisioningFailed reason of the ProvisioningTimedOut condition reports the machine without instance.
+// The instance is force stopped when ECS accepts to stop it, it is released when the machine is deleted.
+func (r *Reconciler) failProvisioningInstance(instance *ecs.Instance, message string) error {
+	r.recordProvisioningTimeout(alibabacloudproviderv1.ProvisioningFailedConditionReason, message+", marking the machine failed")
+
+	if canStopInstance(instance) {
+		if _, err := stopInstances(r.alibabacloudClient, r.providerSpec.RegionID, []*ecs.Instance{instance}, true, getStoppedMode(r.providerSpec.DeletePolicy)); err != nil {
+			klog.Warningf("%s: failed to stop instance %s: %v", r.machine.Name, instance.InstanceId, err)
+		}
+	}
+
+	return fmt.Errorf("machine failed: instance %s did not finish provisioning in time", instance.InstanceId)
+}
+
+// relaunchInstance launches the instance replacing the instance released as it did not finish provisioning in time
+func (r *Reconciler) relaunchInstance(ctx context.Context) error {
+	instance, err := r.CreateMachine(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = r.setProviderID(instance); err != nil {
+		return fmt.Errorf("failed to update machine object with providerID: %w", err)
+	}
+
+	_ = r.machineScope.setProviderStatus(instance, conditionSuccess())
+	return r.requeueInstanceOperation()
+}
+
+// recordProvisioningTimeout reports the action taken on an instance which did not finish provisioning in time
+// in the ProvisioningTimedOut condition and in an event
+func (r *Reconciler) recordProvisioningTimeout(reason string, message string) {
+	klog.Warningf("%s: %s", r.machine.Name, message)
+	r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, provisioningTimeoutEventReason, "%s", message)
+
+	condition := metav1.Condition{
+		Type:    string(alibabacloudproviderv1.ProvisioningTimedOut),
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+	r.providerStatus.Conditions = setMachineProviderCondition(condition, r.providerStatus.Conditions)
+}
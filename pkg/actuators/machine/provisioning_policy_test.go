@@ -0,0 +1,295 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestValidateProvisioningPolicy(t *testing.T) {
+	cases := []struct {
+		name               string
+		provisioningPolicy *alibabacloudproviderv1.ProvisioningPolicy
+		succeeds           bool
+	}{
+		{
+			name:     "No provisioning policy",
+			succeeds: true,
+		},
+		{
+			name: "Valid provisioning policy",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{
+				Timeout:       &metav1.Duration{Duration: 30 * time.Minute},
+				TimeoutAction: alibabacloudproviderv1.RecreateProvisioningTimeoutAction,
+			},
+			succeeds: true,
+		},
+		{
+			name:               "Invalid timeout action",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{TimeoutAction: "Reboot"},
+		},
+		{
+			name:               "Invalid timeout",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{Timeout: &metav1.Duration{}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateProvisioningPolicy(tc.provisioningPolicy)
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestGetClientToken(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.UID = stubMachineUID
+
+	assert.Equal(t, stubMachineUID, getClientToken(machine, 0))
+	assert.Equal(t, stubMachineUID+"-1", getClientToken(machine, 1))
+}
+
+func TestReconcileProvisioning(t *testing.T) {
+	cases := []struct {
+		name                   string
+		provisioningPolicy     *alibabacloudproviderv1.ProvisioningPolicy
+		operation              alibabacloudproviderv1.InstanceOperation
+		operationAge           time.Duration
+		instanceStatus         string
+		provisioningRecoveries int32
+		deletionProtection     bool
+		expectedStop           int
+		expectedStart          int
+		expectedRelease        int
+		expectedModify         int
+		expectedOperation      alibabacloudproviderv1.InstanceOperation
+		expectedReason         string
+		requeues               bool
+	}{
+		{
+			name:              "No provisioning policy",
+			operation:         alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:      time.Hour,
+			instanceStatus:    ECSInstanceStatusStarting,
+			expectedOperation: alibabacloudproviderv1.LaunchInstanceOperation,
+			requeues:          true,
+		},
+		{
+			name:               "Provisioning timeout not passed",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       time.Minute,
+			instanceStatus:     ECSInstanceStatusStarting,
+			expectedOperation:  alibabacloudproviderv1.LaunchInstanceOperation,
+			requeues:           true,
+		},
+		{
+			name:               "Wait for the instance to be stopped by ECS before restarting it",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       20 * time.Minute,
+			instanceStatus:     ECSInstanceStatusStarting,
+			expectedOperation:  alibabacloudproviderv1.LaunchInstanceOperation,
+			requeues:           true,
+		},
+		{
+			name:               "Fail the machine when the instance can not be stopped to restart it",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       time.Hour,
+			instanceStatus:     ECSInstanceStatusStarting,
+			expectedOperation:  alibabacloudproviderv1.LaunchInstanceOperation,
+			expectedReason:     alibabacloudproviderv1.ProvisioningFailedConditionReason,
+		},
+		{
+			name:               "Restart the instance",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       time.Hour,
+			instanceStatus:     ECSInstanceStatusRunning,
+			expectedStop:       1,
+			expectedOperation:  alibabacloudproviderv1.RestartInstanceOperation,
+			expectedReason:     alibabacloudproviderv1.InstanceRestartedConditionReason,
+			requeues:           true,
+		},
+		{
+			name:                   "Start the restarted instance once it is Stopped",
+			provisioningPolicy:     &alibabacloudproviderv1.ProvisioningPolicy{},
+			operation:              alibabacloudproviderv1.RestartInstanceOperation,
+			operationAge:           time.Minute,
+			instanceStatus:         ECSInstanceStatusStopped,
+			provisioningRecoveries: 1,
+			expectedStart:          1,
+			expectedOperation:      alibabacloudproviderv1.RestartInstanceOperation,
+			requeues:               true,
+		},
+		{
+			name:               "Recreate the instance",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{TimeoutAction: alibabacloudproviderv1.RecreateProvisioningTimeoutAction},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       time.Hour,
+			instanceStatus:     ECSInstanceStatusPending,
+			expectedRelease:    1,
+			expectedOperation:  alibabacloudproviderv1.RecreateInstanceOperation,
+			expectedReason:     alibabacloudproviderv1.InstanceRecreatedConditionReason,
+			requeues:           true,
+		},
+		{
+			name:               "Recreate the instance with deletion protection",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{TimeoutAction: alibabacloudproviderv1.RecreateProvisioningTimeoutAction},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       time.Hour,
+			instanceStatus:     ECSInstanceStatusPending,
+			deletionProtection: true,
+			expectedModify:     1,
+			expectedRelease:    1,
+			expectedOperation:  alibabacloudproviderv1.RecreateInstanceOperation,
+			expectedReason:     alibabacloudproviderv1.InstanceRecreatedConditionReason,
+			requeues:           true,
+		},
+		{
+			name:               "Fail the machine",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{TimeoutAction: alibabacloudproviderv1.FailProvisioningTimeoutAction},
+			operation:          alibabacloudproviderv1.LaunchInstanceOperation,
+			operationAge:       time.Hour,
+			instanceStatus:     ECSInstanceStatusStarting,
+			expectedOperation:  alibabacloudproviderv1.LaunchInstanceOperation,
+			expectedReason:     alibabacloudproviderv1.ProvisioningFailedConditionReason,
+		},
+		{
+			name:               "Fail the machine and stop the instance",
+			provisioningPolicy: &alibabacloudproviderv1.ProvisioningPolicy{TimeoutAction: alibabacloudproviderv1.FailProvisioningTimeoutAction},
+			operation:          alibabacloudproviderv1.RestartInstanceOperation,
+			operationAge:       time.Hour,
+			instanceStatus:     ECSInstanceStatusStopping,
+			expectedStop:       1,
+			expectedOperation:  alibabacloudproviderv1.RestartInstanceOperation,
+			expectedReason:     alibabacloudproviderv1.ProvisioningFailedConditionReason,
+		},
+		{
+			name:                   "Fail the machine when the restarted instance does not finish provisioning",
+			provisioningPolicy:     &alibabacloudproviderv1.ProvisioningPolicy{},
+			operation:              alibabacloudproviderv1.RestartInstanceOperation,
+			operationAge:           time.Hour,
+			instanceStatus:         ECSInstanceStatusStarting,
+			provisioningRecoveries: 1,
+			expectedOperation:      alibabacloudproviderv1.RestartInstanceOperation,
+			expectedReason:         alibabacloudproviderv1.ProvisioningFailedConditionReason,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().StopInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.StopInstancesRequest) (*ecs.StopInstancesResponse, error) {
+					assert.Equal(t, []string{stubInstanceID}, *request.InstanceId)
+					assert.Equal(t, "true", string(request.ForceStop))
+					return &ecs.StopInstancesResponse{}, nil
+				}).Times(tc.expectedStop)
+			mockAlibabaCloudClient.EXPECT().StartInstance(gomock.Any()).DoAndReturn(
+				func(request *ecs.StartInstanceRequest) (*ecs.StartInstanceResponse, error) {
+					assert.Equal(t, stubInstanceID, request.InstanceId)
+					assert.Equal(t, stubRegionID, request.RegionId)
+					return &ecs.StartInstanceResponse{}, nil
+				}).Times(tc.expectedStart)
+			mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.DeleteInstancesRequest) (*ecs.DeleteInstancesResponse, error) {
+					assert.Equal(t, []string{stubInstanceID}, *request.InstanceId)
+					assert.Equal(t, "true", string(request.Force))
+					return &ecs.DeleteInstancesResponse{}, nil
+				}).Times(tc.expectedRelease)
+			mockAlibabaCloudClient.EXPECT().ModifyInstanceAttribute(gomock.Any()).DoAndReturn(
+				func(request *ecs.ModifyInstanceAttributeRequest) (*ecs.ModifyInstanceAttributeResponse, error) {
+					assert.Equal(t, "false", string(request.DeletionProtection))
+					return &ecs.ModifyInstanceAttributeResponse{}, nil
+				}).Times(tc.expectedModify)
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(
+				stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, tc.instanceStatus, "192.168.1.0"), nil).AnyTimes()
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			providerConfig := stubProviderConfig()
+			providerConfig.ProvisioningPolicy = tc.provisioningPolicy
+
+			operation := tc.operation
+			operationStartTime := metav1.NewTime(time.Now().Add(-tc.operationAge))
+			instanceID := stubInstanceID
+			providerStatus := &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+				AlibabaCloudMachineProviderStatus: machinev1.AlibabaCloudMachineProviderStatus{
+					InstanceID: &instanceID,
+				},
+				Operation:              &operation,
+				OperationStartTime:     &operationStartTime,
+				ProvisioningRecoveries: tc.provisioningRecoveries,
+			}
+
+			eventRecorder := record.NewFakeRecorder(10)
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      eventRecorder,
+				machine:            machine,
+				providerSpec:       providerConfig,
+				providerStatus:     providerStatus,
+			})
+
+			instance := &ecs.Instance{InstanceId: stubInstanceID, Status: tc.instanceStatus, DeletionProtection: tc.deletionProtection}
+			err = r.reconcileProvisioning(instance)
+			_, requeue := err.(*machinecontroller.RequeueAfterError)
+			assert.Equal(t, tc.requeues, requeue, "unexpected error: %v", err)
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectedOperation, r.instanceOperation())
+
+			if tc.expectedReason == "" {
+				assert.Empty(t, eventRecorder.Events)
+				return
+			}
+			assert.Len(t, eventRecorder.Events, 1+tc.expectedModify)
+			var reason string
+			for _, condition := range r.providerStatus.Conditions {
+				if condition.Type == string(alibabacloudproviderv1.ProvisioningTimedOut) {
+					reason = condition.Reason
+				}
+			}
+			assert.Equal(t, tc.expectedReason, reason)
+			// the failed machine is reported without instance
+			failed := tc.expectedReason == alibabacloudproviderv1.ProvisioningFailedConditionReason
+			assert.Equal(t, failed, r.failureMessage() != "")
+		})
+	}
+}
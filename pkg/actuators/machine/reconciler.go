@@ -18,6 +18,7 @@ package machine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -25,8 +26,10 @@ import (
 
 	"github.com/openshift/machine-api-operator/pkg/metrics"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
 	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 )
 
@@ -58,6 +61,7 @@ func (r *Reconciler) Create(ctx context.Context) error {
 	}
 
 	klog.Infof("Created Machine %v", r.machine.Name)
+
 	if err = r.setProviderID(instance); err != nil {
 		return fmt.Errorf("failed to update machine object with providerID: %w", err)
 	}
@@ -66,6 +70,20 @@ func (r *Reconciler) Create(ctx context.Context) error {
 		return fmt.Errorf("failed to set machine cloud provider specifics: %w", err)
 	}
 
+	// The launch of an instance which is still booting is completed when the machine is updated
+	if isInstanceLaunching(instance) {
+		_ = r.machineScope.setProviderStatus(instance, conditionSuccess())
+		return r.requeueInstanceOperation()
+	}
+	r.completeLaunch(instance)
+
+	// Network interfaces which cannot be attached now are attached when the machine is updated
+	if err = r.reconcileNetworkInterfaces(instance); err != nil {
+		klog.Warningf("%s: failed to attach network interfaces: %v", r.machine.Name, err)
+	}
+
+	r.reconcileMetadataServiceOptions(instance)
+
 	_ = r.machineScope.setProviderStatus(instance, conditionSuccess())
 
 	return nil
@@ -76,12 +94,46 @@ func (r *Reconciler) CreateMachine(ctx context.Context) (*ecs.Instance, error) {
 		return nil, fmt.Errorf("%v: failed validating machine provider spec: %w", r.machine.GetName(), err)
 	}
 
+	// The instance recorded for the machine is not replaced when it can not run anymore, the machine fails instead
+	if message := r.failureMessage(); message != "" {
+		return nil, machinecontroller.InvalidMachineConfiguration("%s", message)
+	}
+	recordedInstance, err := r.getRecordedInstance()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recorded instance: %w", err)
+	}
+	if recordedInstance != nil {
+		if reason, message := terminalInstanceReason(recordedInstance); message != "" {
+			r.recordTerminalInstance(reason, message)
+			return nil, machinecontroller.InvalidMachineConfiguration("%s", message)
+		}
+	}
+
 	userData, err := r.machineScope.getUserData()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user data: %w", err)
 	}
 
-	instance, err := runInstances(r.machine, r.providerSpec, userData, r.alibabacloudClient)
+	if err := validateNetworkInterfaces(r.providerSpec.NetworkInterfaces); err != nil {
+		return nil, fmt.Errorf("%v: failed validating network interfaces: %w", r.machine.GetName(), err)
+	}
+
+	// Values resolved at creation time are pinned in a copy of the provider spec
+	providerSpec := r.providerSpec.DeepCopy()
+
+	if err := r.pinLaunchTemplate(providerSpec); err != nil {
+		return nil, fmt.Errorf("failed to resolve launch template: %w", err)
+	}
+
+	if err := r.pinImage(providerSpec); err != nil {
+		return nil, fmt.Errorf("failed to resolve image: %w", err)
+	}
+
+	if err := r.pinPrivateIPAddress(providerSpec); err != nil {
+		return nil, fmt.Errorf("failed to assign private IP address: %w", err)
+	}
+
+	instanceID, err := runInstances(r.machine, providerSpec, userData, &r.providerStatus.LaunchAttempts, r.alibabacloudClient, r.eventRecorder)
 	if err != nil {
 		klog.Errorf("%s: error creating machine: %v", r.machine.Name, err)
 		conditionFailed := conditionFailed()
@@ -90,18 +142,55 @@ func (r *Reconciler) CreateMachine(ctx context.Context) (*ecs.Instance, error) {
 		return nil, fmt.Errorf("failed to create instance: %w", err)
 	}
 
+	r.startInstanceOperation(alibabacloudproviderv1.LaunchInstanceOperation)
+	r.recordInstanceID(instanceID)
+
+	instance, err := getLaunchedInstance(r.machine, providerSpec, instanceID, r.alibabacloudClient)
+	if err != nil {
+		// The instance may not be visible right after it was launched, it is found again by its ID
+		klog.Warningf("%s: failed to get launched instance %s: %v", r.machine.Name, instanceID, err)
+		return nil, r.requeueInstanceOperation()
+	}
+
 	return instance, nil
 }
 
+// recordInstanceID records the ID of the launched instance in the provider status as soon as it is launched,
+// so that a restarted controller finds the instance instead of launching another one
+func (r *Reconciler) recordInstanceID(instanceID string) {
+	instanceState := ECSInstanceStatusPending
+	r.providerStatus.InstanceID = &instanceID
+	r.providerStatus.InstanceState = &instanceState
+
+	if err := r.patchMachine(); err != nil {
+		klog.Warningf("%s: failed to record instance ID %s: %v", r.machine.Name, instanceID, err)
+	}
+}
+
 // Update updates machine if and only if machine exists, handled by cluster-api
 func (r *Reconciler) Update(ctx context.Context) error {
 	klog.Infof("%s: updating machine", r.machine.Name)
 
+	// The instance released as it did not finish provisioning in time is replaced
+	if r.instanceOperation() == alibabacloudproviderv1.RecreateInstanceOperation {
+		return r.relaunchInstance(ctx)
+	}
+
 	instance, err := r.UpdateMachine(ctx)
 	if err != nil {
 		return err
 	}
 
+	// An instance which can not run anymore fails the machine, it is released when the machine is deleted
+	if reason, message := terminalInstanceReason(instance); message != "" {
+		r.recordTerminalInstance(reason, message)
+		return fmt.Errorf("machine failed: %s", message)
+	}
+
+	// Only the instance recorded for the machine is tagged with its namespace and UID,
+	// this is checked before the provider ID is set to the instance
+	tagOwnership := containsString(r.recordedInstanceIDs(), instance.InstanceId)
+
 	if err = r.setProviderID(instance); err != nil {
 		return fmt.Errorf("failed to update machine object with providerID: %w", err)
 	}
@@ -110,10 +199,30 @@ func (r *Reconciler) Update(ctx context.Context) error {
 		return fmt.Errorf("failed to set machine cloud provider specifics: %w", err)
 	}
 
-	if err = correctExistingTags(r.machine, r.providerSpec.RegionID, instance, r.alibabacloudClient); err != nil {
+	if err = correctExistingTags(r.machine, r.providerSpec.RegionID, instance, tagOwnership, r.alibabacloudClient); err != nil {
 		return fmt.Errorf("failed to correct existing instance tags: %w", err)
 	}
 
+	// The launch of an instance which is still booting is completed by a later update
+	if r.isInstanceProvisioning(instance) {
+		return r.reconcileProvisioning(instance)
+	}
+	r.completeLaunch(instance)
+
+	if err = r.reconcileNetworkInterfaces(instance); err != nil {
+		return fmt.Errorf("failed to reconcile network interfaces: %w", err)
+	}
+
+	r.reconcileMetadataServiceOptions(instance)
+
+	if err = r.reconcileLaunchTemplateDrift(); err != nil {
+		klog.Warningf("%s: failed to check launch template drift: %v", r.machine.Name, err)
+	}
+
+	if err = r.releaseDuplicateInstances(instance); err != nil {
+		klog.Warningf("%s: failed to release duplicate instances: %v", r.machine.Name, err)
+	}
+
 	klog.Infof("Updated machine %s", r.machine.Name)
 
 	r.machineScope.setProviderStatus(instance, conditionSuccess())
@@ -140,6 +249,17 @@ func (r *Reconciler) UpdateMachine(ctx context.Context) (*ecs.Instance, error) {
 
 	existingLen := len(existingInstances)
 	if existingLen == 0 {
+		// An expired instance is not listed with the existing instances, it fails the machine
+		recordedInstance, err := r.getRecordedInstance()
+		if err != nil {
+			klog.Warningf("%s: failed to look up the recorded instance: %v", r.machine.Name, err)
+		} else if recordedInstance != nil {
+			if reason, message := terminalInstanceReason(recordedInstance); message != "" {
+				r.recordTerminalInstance(reason, message)
+				return nil, fmt.Errorf("machine failed: %s", message)
+			}
+		}
+
 		if r.machine.Spec.ProviderID != nil && *r.machine.Spec.ProviderID != "" && (r.machine.Status.LastUpdated == nil || r.machine.Status.LastUpdated.Add(requeueAfterSeconds*time.Second).After(time.Now())) {
 			klog.Infof("%s: Possible eventual-consistency discrepancy; returning an error to requeue", r.machine.Name)
 			return nil, &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
@@ -174,6 +294,10 @@ func (r *Reconciler) requeueIfInstancePending(instance *ecs.Instance) error {
 	// we get a public IP populated more quickly.
 	if instance.Status == ECSInstanceStatusPending {
 		klog.Infof("%s: Instance state still pending, returning an error to requeue", r.machine.Name)
+		// The provisioning timeout of an instance launched without an instance operation runs from now on
+		if r.instanceOperation() == "" {
+			r.startInstanceOperation(alibabacloudproviderv1.LaunchInstanceOperation)
+		}
 		return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
 	}
 
@@ -188,13 +312,19 @@ func (r *Reconciler) Delete(ctx context.Context) error {
 		return err
 	}
 
+	if err := r.releasePrivateIPAddress(); err != nil {
+		return err
+	}
+
+	unregisterDuplicateInstances(r.machine.Namespace, r.machine.Name)
+
 	klog.Infof("Deleted machine %v", r.machine.Name)
 	return nil
 }
 
 func (r *Reconciler) DeleteMachine(ctx context.Context) error {
-	// Get all instances not terminated.
-	existingInstances, err := r.getMachineInstances()
+	// Get all instances not terminated, including the expired instances which are released with them.
+	existingInstances, err := r.getMachineInstancesInStates(deletableInstanceStates())
 	if err != nil {
 		metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
 			Name:      r.machine.Name,
@@ -209,24 +339,24 @@ func (r *Reconciler) DeleteMachine(ctx context.Context) error {
 	klog.Infof("%s: found %d existing instances for machine", r.machine.Name, existingLen)
 	if existingLen < 1 {
 		klog.Warningf("%s: no instances found to delete for machine", r.machine.Name)
-		return nil
+		return r.cleanupMachineResources()
 	}
 
-	// stopInstances stop all running instances ,if instance stauts not running ,skip stop it
-	stoppedInstances, err := stopInstances(r.alibabacloudClient, r.providerSpec.RegionID, existingInstances)
-	if err != nil {
-		metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
-			Name:      r.machine.Name,
-			Namespace: r.machine.Namespace,
-			Reason:    err.Error(),
-		})
-		klog.Errorf("failed to stop instances %v error %v", existingInstances, err)
-		return fmt.Errorf("failed to stop instaces: %w", err)
+	// The release was already requested, the machine is requeued until the instances are gone
+	if r.instanceOperation() == alibabacloudproviderv1.ReleaseInstanceOperation {
+		return r.requeueInstanceOperation()
 	}
 
-	if len(stoppedInstances) == 1 {
-		if stoppedInstances[0].Code == "200" && stoppedInstances[0].CurrentStatus != "" {
-			r.machine.Annotations[machinecontroller.MachineInstanceStateAnnotationName] = stoppedInstances[0].CurrentStatus
+	if err := validateDeletePolicy(r.providerSpec.DeletePolicy); err != nil {
+		return err
+	}
+
+	// Instances are stopped as required by the delete policy before they are released,
+	// the machine is requeued until all of them are Stopped
+	forceRelease := getDeleteMode(r.providerSpec.DeletePolicy) == alibabacloudproviderv1.ForceDeleteMode
+	if !forceRelease && !allInstancesStopped(existingInstances) {
+		if forceRelease, err = r.stopInstancesForDeletion(existingInstances); err != nil {
+			return err
 		}
 	}
 
@@ -235,17 +365,53 @@ func (r *Reconciler) DeleteMachine(ctx context.Context) error {
 		existingInstancesIds = append(existingInstancesIds, instance.InstanceId)
 	}
 
-	// wait for all instances stopped
-	// Query the status of the instance until Stopped
-	_, err = waitForInstancesStatus(r.alibabacloudClient, r.providerSpec.RegionID, existingInstancesIds, ECSInstanceStatusStopped, InstanceDefaultTimeout)
-	if err != nil {
+	// secondary network interfaces are only detached when the instances are released, delete them now.
+	// Instances released with force may not be stopped, their network interfaces are deleted once they are gone.
+	if !forceRelease {
+		if err := r.deleteNetworkInterfaces(); err != nil {
+			var requeueAfterError *machinecontroller.RequeueAfterError
+			if errors.As(err, &requeueAfterError) {
+				return err
+			}
+			metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
+				Name:      r.machine.Name,
+				Namespace: r.machine.Namespace,
+				Reason:    err.Error(),
+			})
+			klog.Errorf("%s: failed to delete network interfaces: %v", r.machine.Name, err)
+			return fmt.Errorf("failed to delete network interfaces: %w", err)
+		}
+	}
+
+	// subscription instances can not be deleted, convert them to pay-as-you-go first
+	if err := convertSubscriptionInstances(r.alibabacloudClient, r.providerSpec.RegionID, existingInstances); err != nil {
+		metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
+			Name:      r.machine.Name,
+			Namespace: r.machine.Namespace,
+			Reason:    err.Error(),
+		})
+		klog.Errorf("%s: %v", r.machine.Name, err)
+		return err
+	}
+
+	// instances with deletion protection can not be deleted, turn it off first
+	if err := r.disableDeletionProtection(existingInstances); err != nil {
+		metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
+			Name:      r.machine.Name,
+			Namespace: r.machine.Namespace,
+			Reason:    err.Error(),
+		})
+		return err
+	}
+
+	// load balancers stop sending traffic to the instances before they are released
+	if err := r.deregisterLoadBalancerBackends(existingInstancesIds); err != nil {
 		metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
 			Name:      r.machine.Name,
 			Namespace: r.machine.Namespace,
 			Reason:    err.Error(),
 		})
-		klog.Errorf("failed to wait for  instances %v stopped: %v", existingInstancesIds, err)
-		return fmt.Errorf("failed to wait for  instances stopped: %v", err)
+		return err
 	}
 
 	// delete stoppted instances
@@ -257,6 +423,9 @@ func (r *Reconciler) DeleteMachine(ctx context.Context) error {
 	deleteInstancesRequest.Scheme = "https"
 	deleteInstancesRequest.RegionId = r.providerSpec.RegionID
 	deleteInstancesRequest.InstanceId = &existingInstancesIds
+	if forceRelease {
+		deleteInstancesRequest.Force = requests.NewBoolean(true)
+	}
 
 	deleteInstancsResponse, err := r.alibabacloudClient.DeleteInstances(deleteInstancesRequest)
 	if err != nil {
@@ -270,11 +439,58 @@ func (r *Reconciler) DeleteMachine(ctx context.Context) error {
 	}
 
 	klog.V(3).Infof("Delete instance response %v", deleteInstancsResponse)
+	r.startInstanceOperation(alibabacloudproviderv1.ReleaseInstanceOperation)
+	return nil
+}
+
+// requestStopInstances requests to stop the instances, without waiting for them to be Stopped
+func (r *Reconciler) requestStopInstances(instances []*ecs.Instance, forceStop bool) error {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	// stopInstances stop all running instances ,if instance stauts not running ,skip stop it
+	stoppedInstances, err := stopInstances(r.alibabacloudClient, r.providerSpec.RegionID, instances, forceStop, getStoppedMode(r.providerSpec.DeletePolicy))
+	if err != nil {
+		metrics.RegisterFailedInstanceDelete(&metrics.MachineLabels{
+			Name:      r.machine.Name,
+			Namespace: r.machine.Namespace,
+			Reason:    err.Error(),
+		})
+		klog.Errorf("failed to stop instances %v error %v", instances, err)
+		return fmt.Errorf("failed to stop instaces: %w", err)
+	}
+
+	if len(stoppedInstances) == 1 {
+		if stoppedInstances[0].Code == "200" && stoppedInstances[0].CurrentStatus != "" {
+			r.machine.Annotations[machinecontroller.MachineInstanceStateAnnotationName] = stoppedInstances[0].CurrentStatus
+		}
+	}
+
 	return nil
 }
 
+// allInstancesStopped returns true when all the instances are Stopped, expired instances are stopped by ECS
+func allInstancesStopped(instances []*ecs.Instance) bool {
+	for _, instance := range instances {
+		if instance.Status != ECSInstanceStatusStopped && instance.Status != ECSInstanceStatusExpired {
+			return false
+		}
+	}
+	return true
+}
+
 // Exists checks if machine exists
 func (r *Reconciler) Exists(ctx context.Context) (bool, error) {
+	// A failed machine is reported without instance so that the machine controller marks it failed,
+	// its instance is still released when the machine is deleted
+	if r.machine.DeletionTimestamp == nil {
+		if message := r.failureMessage(); message != "" {
+			klog.Infof("%s: machine failed: %s", r.machine.Name, message)
+			return false, nil
+		}
+	}
+
 	// Get all instances not terminated.
 	existingInstances, err := r.getMachineInstances()
 	if err != nil {
@@ -289,12 +505,39 @@ func (r *Reconciler) Exists(ctx context.Context) (bool, error) {
 		return false, err
 	}
 
+	// The instance released as it did not finish provisioning in time is replaced by the next update
+	if r.instanceOperation() == alibabacloudproviderv1.RecreateInstanceOperation {
+		return true, nil
+	}
+
 	if len(existingInstances) == 0 {
+		// The resources of the machine are released once its instances are gone,
+		// the machine is kept until the cleanup completes
+		if operation := r.instanceOperation(); operation == alibabacloudproviderv1.ReleaseInstanceOperation || operation == alibabacloudproviderv1.CleanupInstanceOperation {
+			klog.Infof("%s: instance operation %s in progress", r.machine.Name, operation)
+			return true, nil
+		}
+
+		// An instance which can not run anymore, e.g. an expired instance, still exists until it is released.
+		// The machine is failed by the next update, or its instance is released by the deletion.
+		recordedInstance, err := r.getRecordedInstance()
+		if err != nil {
+			klog.Warningf("%s: failed to look up the recorded instance: %v", r.machine.Name, err)
+		} else if recordedInstance != nil {
+			klog.Infof("%s: instance %s is %s", r.machine.Name, recordedInstance.InstanceId, recordedInstance.Status)
+			return true, nil
+		}
+
 		if r.machine.Spec.ProviderID != nil && *r.machine.Spec.ProviderID != "" && (r.machine.Status.LastUpdated == nil || r.machine.Status.LastUpdated.Add(requeueAfterSeconds*time.Second).After(time.Now())) {
 			klog.Infof("%s: Possible eventual-consistency discrepancy; returning an error to requeue", r.machine.Name)
 			return false, &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
 		}
 
+		// The instances of deleted machines are released by the machine API
+		if err == nil && r.machine.DeletionTimestamp == nil && r.machine.Spec.ProviderID != nil && *r.machine.Spec.ProviderID != "" {
+			r.reportTerminalInstance(fmt.Sprintf("instance of machine %s no longer exists, it was released outside of the machine API", r.machine.Name))
+		}
+
 		klog.Infof("%s: Instance does not exist", r.machine.Name)
 		return false, nil
 	}
@@ -349,19 +592,35 @@ func (r *Reconciler) setMachineCloudProviderSpecifics(instance *ecs.Instance) er
 		r.machine.Annotations[machinecontroller.MachineInstanceStateAnnotationName] = instance.Status
 	}
 
+	r.machine.Labels[MachineCapacityTypeLabelName] = getCapacityType(instance)
+
+	if isSpotInstance(instance) {
+		// Label on the Spec so that it is propagated to the Node
+		r.machine.Spec.Labels[machinecontroller.MachineInterruptibleInstanceLabelName] = ""
+	}
+
 	return nil
 }
 
 func (r *Reconciler) getMachineInstances() ([]*ecs.Instance, error) {
+	return r.getMachineInstancesInStates(supportedInstanceStates())
+}
+
+// getMachineInstancesInStates returns the instances of the machine in the given states
+func (r *Reconciler) getMachineInstancesInStates(instanceStates []string) ([]*ecs.Instance, error) {
 	if r.providerStatus.InstanceID != nil && *r.providerStatus.InstanceID != "" {
-		i, err := getExistingInstanceByID(*r.providerStatus.InstanceID, r.providerSpec.RegionID, r.alibabacloudClient)
+		i, err := getInstanceByID(*r.providerStatus.InstanceID, r.providerSpec.RegionID, r.alibabacloudClient, instanceStates)
 		if err != nil {
 			klog.Warningf("%s: Failed to find existing instance by id %s: %v", r.machine.Name, *r.providerStatus.InstanceID, err)
 		} else {
 			klog.Infof("%s: Found instance by id: %s", r.machine.Name, *r.providerStatus.InstanceID)
-			return []*ecs.Instance{i}, nil
+			return r.ownedInstances([]*ecs.Instance{i}), nil
 		}
 	}
 
-	return getExistingInstances(r.machine, r.providerSpec.RegionID, r.alibabacloudClient)
+	existingInstances, err := getInstances(r.machine, r.providerSpec.RegionID, r.alibabacloudClient, instanceStates)
+	if err != nil {
+		return nil, err
+	}
+	return r.ownedInstances(existingInstances), nil
 }
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
 	"github.com/golang/mock/gomock"
 	configv1 "github.com/openshift/api/config/v1"
 	machinev1 "github.com/openshift/api/machine/v1"
@@ -58,7 +59,7 @@ func TestCreate(t *testing.T) {
 
 	testCases := []struct {
 		testcase                      string
-		providerConfig                *machinev1.AlibabaCloudMachineProviderConfig
+		providerConfig                *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig
 		userDataSecret                *corev1.Secret
 		alibabaCloudCredentialsSecret *corev1.Secret
 		expectedError                 error
@@ -308,6 +309,8 @@ func TestDelete(t *testing.T) {
 				mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).Return(stubRunInstancesResponse(), nil).AnyTimes()
 				mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, stubStoppedInstanceStatus, "192.168.1.0"), nil).AnyTimes()
 				mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).Return(&ecs.DeleteInstancesResponse{}, nil).AnyTimes()
+				mockAlibabaCloudClient.EXPECT().DescribeLoadBalancers(gomock.Any()).Return(&slb.DescribeLoadBalancersResponse{}, nil).AnyTimes()
+				mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Return(&ecs.DescribeNetworkInterfacesResponse{}, nil).AnyTimes()
 				return mockAlibabaCloudClient
 			},
 		},
@@ -478,13 +481,13 @@ func TestGetMachineInstances(t *testing.T) {
 
 	testCases := []struct {
 		testcase           string
-		providerStatus     machinev1.AlibabaCloudMachineProviderStatus
+		providerStatus     alibabacloudproviderv1.AlibabaCloudMachineProviderStatus
 		alibabacloudClient func(*gomock.Controller) alibabacloudclient.Client
 		exists             bool
 	}{
 		{
 			testcase:       "empty-status",
-			providerStatus: machinev1.AlibabaCloudMachineProviderStatus{},
+			providerStatus: alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
 			alibabacloudClient: func(ctrl *gomock.Controller) alibabacloudclient.Client {
 				mockAlibabaCloudClient := mock.NewMockClient(ctrl)
 
@@ -499,8 +502,10 @@ func TestGetMachineInstances(t *testing.T) {
 		},
 		{
 			testcase: "instance-has-status-running",
-			providerStatus: machinev1.AlibabaCloudMachineProviderStatus{
-				InstanceID: &stubInstanceID,
+			providerStatus: alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+				AlibabaCloudMachineProviderStatus: machinev1.AlibabaCloudMachineProviderStatus{
+					InstanceID: &stubInstanceID,
+				},
 			},
 			alibabacloudClient: func(ctrl *gomock.Controller) alibabacloudclient.Client {
 				mockAlibabaCloudClient := mock.NewMockClient(ctrl)
@@ -515,8 +520,10 @@ func TestGetMachineInstances(t *testing.T) {
 		},
 		{
 			testcase: "instance-has-status-stopped",
-			providerStatus: machinev1.AlibabaCloudMachineProviderStatus{
-				InstanceID: &stubInstanceID,
+			providerStatus: alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+				AlibabaCloudMachineProviderStatus: machinev1.AlibabaCloudMachineProviderStatus{
+					InstanceID: &stubInstanceID,
+				},
 			},
 			alibabacloudClient: func(ctrl *gomock.Controller) alibabacloudclient.Client {
 				mockAlibabaCloudClient := mock.NewMockClient(ctrl)
@@ -575,3 +582,37 @@ func TestGetMachineInstances(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordInstanceID(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, machine)
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		client:             fakeClient,
+		machine:            machine,
+		machineToBePatched: runtimeclient.MergeFrom(machine.DeepCopy()),
+		providerSpec:       stubProviderConfig(),
+		providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	r.recordInstanceID(stubInstanceID)
+
+	got := &machinev1beta1.Machine{}
+	if err := fakeClient.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(machine), got); err != nil {
+		t.Fatal(err)
+	}
+	providerStatus, err := alibabacloudproviderv1.ProviderStatusFromRawExtension(got.Status.ProviderStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if providerStatus.InstanceID == nil || *providerStatus.InstanceID != stubInstanceID {
+		t.Errorf("Expected instance ID %s to be recorded, got %v", stubInstanceID, providerStatus.InstanceID)
+	}
+	if providerStatus.InstanceState == nil || *providerStatus.InstanceState != ECSInstanceStatusPending {
+		t.Errorf("Expected instance state %s to be recorded, got %v", ECSInstanceStatusPending, providerStatus.InstanceState)
+	}
+}
@@ -0,0 +1,466 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// VPCEIPAddressStatusAvailable EIP status when it is not associated with any resource
+	VPCEIPAddressStatusAvailable = "Available"
+	// VPCEIPAddressStatusInUse EIP status when it is associated with a resource
+	VPCEIPAddressStatusInUse = "InUse"
+
+	// ECSDiskStatusAvailable disk status when it is not attached to an instance
+	ECSDiskStatusAvailable = "Available"
+	// ECSDiskTypeData type of the data disks of an instance
+	ECSDiskTypeData = "data"
+
+	// keepOnDeleteTagKey is the tag keeping a resource owned by a Machine when the Machine is deleted
+	keepOnDeleteTagKey = "machine.openshift.io/keep-on-delete"
+
+	// describeEIPAddressesPageSize is the maximum number of EIPs returned by DescribeEipAddresses
+	describeEIPAddressesPageSize = 100
+
+	// eipUnassociateTimeout is how long the cleanup waits for the EIPs owned by the machine to be unassociated
+	eipUnassociateTimeout = 10 * time.Minute
+
+	// eipAddressNotReleasedEventReason is the reason of the event recorded when an EIP owned by the machine is not released
+	eipAddressNotReleasedEventReason = "EIPAddressNotReleased"
+)
+
+// slbBackendServer is a backend server of the BackendServers parameter of the SLB API
+type slbBackendServer struct {
+	ServerId string `json:"ServerId"`
+	Port     int    `json:"Port,omitempty"`
+}
+
+// isKeepOnDeleteTag returns true for the tag asking to keep a resource when its Machine is deleted
+func isKeepOnDeleteTag(key, value string) bool {
+	return key == keepOnDeleteTagKey && value == "true"
+}
+
+// cleanupMachineResources releases the resources owned by the machine once its instances are released.
+// The resources are found by the ownership tags of the machine, and the released ones are recorded in the
+// provider status, so that a partial cleanup is resumed by the next reconcile.
+func (r *Reconciler) cleanupMachineResources() error {
+	r.startInstanceOperation(alibabacloudproviderv1.CleanupInstanceOperation)
+
+	// The EIPs are unassociated from the network interfaces of the machine before those are deleted
+	if err := r.releaseEIPAddresses(); err != nil {
+		return err
+	}
+
+	if err := r.deleteNetworkInterfaces(); err != nil {
+		return err
+	}
+
+	if err := r.releasePreservedDisks(); err != nil {
+		return err
+	}
+
+	r.providerStatus.ReleasedResources = nil
+	r.completeInstanceOperation()
+	return nil
+}
+
+// isResourceReleased returns true when the resource was already released by the cleanup in progress
+func (r *Reconciler) isResourceReleased(resourceID string) bool {
+	for _, releasedResourceID := range r.providerStatus.ReleasedResources {
+		if releasedResourceID == resourceID {
+			return true
+		}
+	}
+	return false
+}
+
+// recordResourceReleased records the release of the resource in the provider status
+func (r *Reconciler) recordResourceReleased(resourceID string) {
+	r.providerStatus.ReleasedResources = append(r.providerStatus.ReleasedResources, resourceID)
+}
+
+// recordedInstanceIDs returns the IDs of the instance recorded for the machine in its provider status and in its provider ID
+func (r *Reconciler) recordedInstanceIDs() []string {
+	instanceIDs := make([]string, 0, 2)
+	if r.providerStatus.InstanceID != nil && *r.providerStatus.InstanceID != "" {
+		instanceIDs = append(instanceIDs, *r.providerStatus.InstanceID)
+	}
+	// The provider ID has the format alicloud://<region>.<instance ID>
+	if providerID := r.machine.Spec.ProviderID; providerID != nil && strings.Contains(*providerID, ".") {
+		instanceID := (*providerID)[strings.LastIndex(*providerID, ".")+1:]
+		if instanceID != "" && !containsString(instanceIDs, instanceID) {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+	return instanceIDs
+}
+
+// releaseEIPAddresses releases the EIPs owned by the machine, unassociating them first.
+// Only the EIPs associated with the instance or the network interfaces recorded for the machine are unassociated,
+// an EIP associated with another resource is left in place.
+func (r *Reconciler) releaseEIPAddresses() error {
+	eipAddresses, err := getMachineEIPAddresses(r.machine, r.providerSpec.RegionID, r.alibabacloudClient)
+	if err != nil {
+		return err
+	}
+
+	associatedIDs := r.recordedInstanceIDs()
+	for _, networkInterface := range r.providerStatus.NetworkInterfaces {
+		associatedIDs = append(associatedIDs, networkInterface.ID)
+	}
+
+	unassociating := 0
+	for _, eipAddress := range eipAddresses {
+		if r.isResourceReleased(eipAddress.AllocationId) || keepEIPAddress(eipAddress) {
+			continue
+		}
+
+		switch eipAddress.Status {
+		case VPCEIPAddressStatusAvailable:
+		case VPCEIPAddressStatusInUse:
+			if !containsString(associatedIDs, eipAddress.InstanceId) {
+				klog.Warningf("%s: keeping EIP %s associated with %s %s, which is not owned by the machine", r.machine.Name, eipAddress.AllocationId, eipAddress.InstanceType, eipAddress.InstanceId)
+				r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, eipAddressNotReleasedEventReason,
+					"EIP %s is associated with %s %s, which is not owned by the machine", eipAddress.AllocationId, eipAddress.InstanceType, eipAddress.InstanceId)
+				continue
+			}
+
+			request := vpc.CreateUnassociateEipAddressRequest()
+			request.Scheme = "https"
+			request.RegionId = r.providerSpec.RegionID
+			request.AllocationId = eipAddress.AllocationId
+			request.InstanceId = eipAddress.InstanceId
+			request.InstanceType = eipAddress.InstanceType
+
+			if _, err := r.alibabacloudClient.UnassociateEipAddress(request); err != nil {
+				klog.Errorf("Error unassociating EIP %s from %s: %v", eipAddress.AllocationId, eipAddress.InstanceId, err)
+				return fmt.Errorf("error unassociating EIP %s: %v", eipAddress.AllocationId, err)
+			}
+			klog.Infof("%s: unassociated EIP %s from %s", r.machine.Name, eipAddress.AllocationId, eipAddress.InstanceId)
+			unassociating++
+			continue
+		default:
+			// The EIP is released once it is unassociated
+			unassociating++
+			continue
+		}
+
+		request := vpc.CreateReleaseEipAddressRequest()
+		request.Scheme = "https"
+		request.RegionId = r.providerSpec.RegionID
+		request.AllocationId = eipAddress.AllocationId
+
+		if _, err := r.alibabacloudClient.ReleaseEipAddress(request); err != nil {
+			klog.Errorf("Error releasing EIP %s: %v", eipAddress.AllocationId, err)
+			return fmt.Errorf("error releasing EIP %s: %v", eipAddress.AllocationId, err)
+		}
+		klog.Infof("%s: released EIP %s", r.machine.Name, eipAddress.AllocationId)
+		r.recordResourceReleased(eipAddress.AllocationId)
+	}
+
+	if unassociating > 0 {
+		if r.instanceOperationDuration() > eipUnassociateTimeout {
+			klog.Warningf("%s: %d EIPs were not unassociated within %v, leaving them in place", r.machine.Name, unassociating, eipUnassociateTimeout)
+			r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, eipAddressNotReleasedEventReason,
+				"%d EIPs were not unassociated within %v and were not released", unassociating, eipUnassociateTimeout)
+			return nil
+		}
+		klog.Infof("%s: waiting for %d EIPs to be unassociated, returning an error to requeue", r.machine.Name, unassociating)
+		return &mapierrors.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+	}
+
+	return nil
+}
+
+// keepEIPAddress returns true when the EIP is tagged to be kept
+func keepEIPAddress(eipAddress vpc.EipAddress) bool {
+	for _, tag := range eipAddress.Tags.Tag {
+		if isKeepOnDeleteTag(tag.Key, tag.Value) {
+			klog.Infof("Keeping EIP %s tagged %s", eipAddress.AllocationId, keepOnDeleteTagKey)
+			return true
+		}
+	}
+	return false
+}
+
+// getMachineEIPAddresses returns the EIPs tagged as owned by the machine, including its namespace and UID
+func getMachineEIPAddresses(machine *machinev1beta1.Machine, regionID string, client alibabacloudClient.Client) ([]vpc.EipAddress, error) {
+	clusterID, ok := getClusterID(machine)
+	if !ok {
+		return nil, fmt.Errorf("unable to get cluster ID for machine: %q", machine.Name)
+	}
+
+	request := vpc.CreateDescribeEipAddressesRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.PageSize = requests.NewInteger(describeEIPAddressesPageSize)
+	request.Tag = &[]vpc.DescribeEipAddressesTag{
+		{Key: clusterFilterKeyPrefix + clusterID, Value: clusterFilterValue},
+		{Key: clusterFilterName, Value: machine.Name},
+		{Key: machineNamespaceTagKey, Value: machine.Namespace},
+		{Key: machineUIDTagKey, Value: string(machine.UID)},
+	}
+
+	eipAddresses := make([]vpc.EipAddress, 0)
+	for pageNumber := 1; ; pageNumber++ {
+		request.PageNumber = requests.NewInteger(pageNumber)
+		response, err := client.DescribeEipAddresses(request)
+		if err != nil {
+			klog.Errorf("error describing EIPs: %v", err)
+			return nil, fmt.Errorf("error describing EIPs: %v", err)
+		}
+
+		eipAddresses = append(eipAddresses, response.EipAddresses.EipAddress...)
+
+		if len(response.EipAddresses.EipAddress) == 0 || len(eipAddresses) >= response.TotalCount {
+			break
+		}
+	}
+
+	return eipAddresses, nil
+}
+
+// releasePreservedDisks deletes the data disks owned by the machine which were preserved when its instance was released,
+// when the delete policy asks for it
+func (r *Reconciler) releasePreservedDisks() error {
+	if r.providerSpec.DeletePolicy == nil || !r.providerSpec.DeletePolicy.ReleasePreservedDisks {
+		return nil
+	}
+
+	disks, err := getMachinePreservedDisks(r.machine, r.providerSpec.RegionID, r.alibabacloudClient)
+	if err != nil {
+		return err
+	}
+
+	for _, disk := range disks {
+		if r.isResourceReleased(disk.DiskId) || keepDisk(disk) {
+			continue
+		}
+
+		// The disk was attached to another instance after the instance of the machine was released
+		if disk.Status != ECSDiskStatusAvailable {
+			klog.Infof("%s: keeping disk %s in status %s", r.machine.Name, disk.DiskId, disk.Status)
+			continue
+		}
+
+		request := ecs.CreateDeleteDiskRequest()
+		request.Scheme = "https"
+		request.RegionId = r.providerSpec.RegionID
+		request.DiskId = disk.DiskId
+
+		if _, err := r.alibabacloudClient.DeleteDisk(request); err != nil {
+			klog.Errorf("Error deleting disk %s: %v", disk.DiskId, err)
+			return fmt.Errorf("error deleting disk %s: %v", disk.DiskId, err)
+		}
+		klog.Infof("%s: deleted preserved disk %s", r.machine.Name, disk.DiskId)
+		r.recordResourceReleased(disk.DiskId)
+	}
+
+	return nil
+}
+
+// keepDisk returns true when the disk is tagged to be kept
+func keepDisk(disk ecs.Disk) bool {
+	for _, tag := range disk.Tags.Tag {
+		if isKeepOnDeleteTag(tag.TagKey, tag.TagValue) {
+			klog.Infof("Keeping disk %s tagged %s", disk.DiskId, keepOnDeleteTagKey)
+			return true
+		}
+	}
+	return false
+}
+
+// getMachinePreservedDisks returns the data disks tagged as owned by the machine, including its namespace and UID,
+// which are not deleted with the instance
+func getMachinePreservedDisks(machine *machinev1beta1.Machine, regionID string, client alibabacloudClient.Client) ([]ecs.Disk, error) {
+	clusterID, ok := getClusterID(machine)
+	if !ok {
+		return nil, fmt.Errorf("unable to get cluster ID for machine: %q", machine.Name)
+	}
+
+	request := ecs.CreateDescribeDisksRequest()
+	request.Scheme = "https"
+	request.RegionId = regionID
+	request.DiskType = ECSDiskTypeData
+	request.DeleteWithInstance = requests.NewBoolean(false)
+	request.Tag = &[]ecs.DescribeDisksTag{
+		{Key: clusterFilterKeyPrefix + clusterID, Value: clusterFilterValue},
+		{Key: clusterFilterName, Value: machine.Name},
+		{Key: machineNamespaceTagKey, Value: machine.Namespace},
+		{Key: machineUIDTagKey, Value: string(machine.UID)},
+	}
+
+	disks := make([]ecs.Disk, 0)
+	for {
+		response, err := client.DescribeDisks(request)
+		if err != nil {
+			klog.Errorf("error describing disks: %v", err)
+			return nil, fmt.Errorf("error describing disks: %v", err)
+		}
+
+		disks = append(disks, response.Disks.Disk...)
+
+		if response.NextToken == "" {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+
+	return disks, nil
+}
+
+// deregisterLoadBalancerBackends removes the instances from the backend servers of the load balancers they are registered with.
+// It is called before the instances are released, so that the load balancers stop sending traffic to them.
+func (r *Reconciler) deregisterLoadBalancerBackends(instanceIDs []string) error {
+	for _, instanceID := range instanceIDs {
+		request := slb.CreateDescribeLoadBalancersRequest()
+		request.Scheme = "https"
+		request.RegionId = r.providerSpec.RegionID
+		request.ServerId = instanceID
+
+		response, err := r.alibabacloudClient.DescribeLoadBalancers(request)
+		if err != nil {
+			klog.Errorf("Error describing load balancers of instance %s: %v", instanceID, err)
+			return fmt.Errorf("error describing load balancers of instance %s: %v", instanceID, err)
+		}
+
+		for _, loadBalancer := range response.LoadBalancers.LoadBalancer {
+			if err := r.removeDefaultServerGroupBackend(loadBalancer.LoadBalancerId, instanceID); err != nil {
+				return err
+			}
+			if err := r.removeVServerGroupBackends(loadBalancer.LoadBalancerId, instanceID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeDefaultServerGroupBackend removes the instance from the default server group of the load balancer
+func (r *Reconciler) removeDefaultServerGroupBackend(loadBalancerID, instanceID string) error {
+	request := slb.CreateDescribeLoadBalancerAttributeRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.LoadBalancerId = loadBalancerID
+
+	response, err := r.alibabacloudClient.DescribeLoadBalancerAttribute(request)
+	if err != nil {
+		klog.Errorf("Error describing load balancer %s: %v", loadBalancerID, err)
+		return fmt.Errorf("error describing load balancer %s: %v", loadBalancerID, err)
+	}
+
+	registered := false
+	for _, backendServer := range response.BackendServers.BackendServer {
+		if backendServer.ServerId == instanceID {
+			registered = true
+		}
+	}
+	if !registered {
+		return nil
+	}
+
+	backendServers, err := json.Marshal([]slbBackendServer{{ServerId: instanceID}})
+	if err != nil {
+		return err
+	}
+
+	removeRequest := slb.CreateRemoveBackendServersRequest()
+	removeRequest.Scheme = "https"
+	removeRequest.RegionId = r.providerSpec.RegionID
+	removeRequest.LoadBalancerId = loadBalancerID
+	removeRequest.BackendServers = string(backendServers)
+
+	if _, err := r.alibabacloudClient.RemoveBackendServers(removeRequest); err != nil {
+		klog.Errorf("Error removing instance %s from load balancer %s: %v", instanceID, loadBalancerID, err)
+		return fmt.Errorf("error removing instance %s from load balancer %s: %v", instanceID, loadBalancerID, err)
+	}
+	klog.Infof("%s: removed instance %s from load balancer %s", r.machine.Name, instanceID, loadBalancerID)
+
+	return nil
+}
+
+// removeVServerGroupBackends removes the instance from the vserver groups of the load balancer
+func (r *Reconciler) removeVServerGroupBackends(loadBalancerID, instanceID string) error {
+	request := slb.CreateDescribeVServerGroupsRequest()
+	request.Scheme = "https"
+	request.RegionId = r.providerSpec.RegionID
+	request.LoadBalancerId = loadBalancerID
+
+	response, err := r.alibabacloudClient.DescribeVServerGroups(request)
+	if err != nil {
+		klog.Errorf("Error describing vserver groups of load balancer %s: %v", loadBalancerID, err)
+		return fmt.Errorf("error describing vserver groups of load balancer %s: %v", loadBalancerID, err)
+	}
+
+	for _, vServerGroup := range response.VServerGroups.VServerGroup {
+		attributeRequest := slb.CreateDescribeVServerGroupAttributeRequest()
+		attributeRequest.Scheme = "https"
+		attributeRequest.RegionId = r.providerSpec.RegionID
+		attributeRequest.VServerGroupId = vServerGroup.VServerGroupId
+
+		attributeResponse, err := r.alibabacloudClient.DescribeVServerGroupAttribute(attributeRequest)
+		if err != nil {
+			klog.Errorf("Error describing vserver group %s: %v", vServerGroup.VServerGroupId, err)
+			return fmt.Errorf("error describing vserver group %s: %v", vServerGroup.VServerGroupId, err)
+		}
+
+		backends := make([]slbBackendServer, 0)
+		for _, backendServer := range attributeResponse.BackendServers.BackendServer {
+			if backendServer.ServerId == instanceID {
+				backends = append(backends, slbBackendServer{ServerId: instanceID, Port: backendServer.Port})
+			}
+		}
+		if len(backends) == 0 {
+			continue
+		}
+
+		backendServers, err := json.Marshal(backends)
+		if err != nil {
+			return err
+		}
+
+		removeRequest := slb.CreateRemoveVServerGroupBackendServersRequest()
+		removeRequest.Scheme = "https"
+		removeRequest.RegionId = r.providerSpec.RegionID
+		removeRequest.VServerGroupId = vServerGroup.VServerGroupId
+		removeRequest.BackendServers = string(backendServers)
+
+		if _, err := r.alibabacloudClient.RemoveVServerGroupBackendServers(removeRequest); err != nil {
+			klog.Errorf("Error removing instance %s from vserver group %s: %v", instanceID, vServerGroup.VServerGroupId, err)
+			return fmt.Errorf("error removing instance %s from vserver group %s: %v", instanceID, vServerGroup.VServerGroupId, err)
+		}
+		klog.Infof("%s: removed instance %s from vserver group %s of load balancer %s", r.machine.Name, instanceID, vServerGroup.VServerGroupId, loadBalancerID)
+	}
+
+	return nil
+}
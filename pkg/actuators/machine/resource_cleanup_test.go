@@ -0,0 +1,285 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/slb"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	"github.com/golang/mock/gomock"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	stubEIPAllocationID      = "eip-bp1available"
+	stubInUseEIPAllocationID = "eip-bp1inuse"
+	stubKeptEIPAllocationID  = "eip-bp1kept"
+	stubOtherEIPAllocationID = "eip-bp1other"
+	stubPreservedDiskID      = "d-bp1preserved"
+	stubLoadBalancerID       = "lb-bp1machine"
+	stubVServerGroupID       = "rsp-bp1machine"
+)
+
+func TestCleanupMachineResources(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Return(&ecs.DescribeNetworkInterfacesResponse{}, nil).AnyTimes()
+
+	unassociated := false
+	mockAlibabaCloudClient.EXPECT().DescribeEipAddresses(gomock.Any()).DoAndReturn(
+		func(request *vpc.DescribeEipAddressesRequest) (*vpc.DescribeEipAddressesResponse, error) {
+			assert.Contains(t, *request.Tag, vpc.DescribeEipAddressesTag{Key: clusterFilterName, Value: stubMasterMachineName})
+			assert.Contains(t, *request.Tag, vpc.DescribeEipAddressesTag{Key: machineUIDTagKey, Value: stubMachineUID})
+			inUseStatus := VPCEIPAddressStatusInUse
+			if unassociated {
+				inUseStatus = VPCEIPAddressStatusAvailable
+			}
+			eipAddresses := []vpc.EipAddress{
+				// The released EIP is still listed
+				{AllocationId: stubEIPAllocationID, Status: VPCEIPAddressStatusAvailable},
+				{AllocationId: stubInUseEIPAllocationID, Status: inUseStatus, InstanceId: stubNetworkInterfaceID, InstanceType: "NetworkInterface"},
+				{AllocationId: stubKeptEIPAllocationID, Status: VPCEIPAddressStatusAvailable, Tags: vpc.TagsInDescribeEipAddresses{Tag: []vpc.Tag{{Key: keepOnDeleteTagKey, Value: "true"}}}},
+				// The EIP is associated with an instance which is not owned by the machine
+				{AllocationId: stubOtherEIPAllocationID, Status: VPCEIPAddressStatusInUse, InstanceId: "i-bp1other", InstanceType: "EcsInstance"},
+			}
+			return &vpc.DescribeEipAddressesResponse{TotalCount: len(eipAddresses), EipAddresses: vpc.EipAddresses{EipAddress: eipAddresses}}, nil
+		}).Times(2)
+	mockAlibabaCloudClient.EXPECT().UnassociateEipAddress(gomock.Any()).DoAndReturn(
+		func(request *vpc.UnassociateEipAddressRequest) (*vpc.UnassociateEipAddressResponse, error) {
+			assert.Equal(t, stubInUseEIPAllocationID, request.AllocationId)
+			assert.Equal(t, stubNetworkInterfaceID, request.InstanceId)
+			return &vpc.UnassociateEipAddressResponse{}, nil
+		}).Times(1)
+	released := make([]string, 0)
+	mockAlibabaCloudClient.EXPECT().ReleaseEipAddress(gomock.Any()).DoAndReturn(
+		func(request *vpc.ReleaseEipAddressRequest) (*vpc.ReleaseEipAddressResponse, error) {
+			released = append(released, request.AllocationId)
+			return &vpc.ReleaseEipAddressResponse{}, nil
+		}).Times(2)
+	mockAlibabaCloudClient.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(
+		func(request *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+			assert.Equal(t, ECSDiskTypeData, request.DiskType)
+			assert.Equal(t, "false", string(request.DeleteWithInstance))
+			assert.Contains(t, *request.Tag, ecs.DescribeDisksTag{Key: machineUIDTagKey, Value: stubMachineUID})
+			return &ecs.DescribeDisksResponse{Disks: ecs.DisksInDescribeDisks{Disk: []ecs.Disk{
+				{DiskId: stubPreservedDiskID, Status: ECSDiskStatusAvailable},
+				{DiskId: "d-bp1reattached", Status: "In_use"},
+			}}}, nil
+		}).Times(1)
+	mockAlibabaCloudClient.EXPECT().DeleteDisk(gomock.Any()).DoAndReturn(
+		func(request *ecs.DeleteDiskRequest) (*ecs.DeleteDiskResponse, error) {
+			assert.Equal(t, stubPreservedDiskID, request.DiskId)
+			return &ecs.DeleteDiskResponse{}, nil
+		}).Times(1)
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.UID = stubMachineUID
+
+	providerConfig := stubProviderConfig()
+	providerConfig.DeletePolicy = &alibabacloudproviderv1.DeletePolicy{ReleasePreservedDisks: true}
+
+	eventRecorder := record.NewFakeRecorder(10)
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		eventRecorder:      eventRecorder,
+		machine:            machine,
+		providerSpec:       providerConfig,
+		providerStatus: &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+			NetworkInterfaces: []alibabacloudproviderv1.NetworkInterfaceStatus{{ID: stubNetworkInterfaceID}},
+		},
+	})
+
+	// The cleanup is resumed once the EIP in use is unassociated
+	_, requeue := r.cleanupMachineResources().(*machinecontroller.RequeueAfterError)
+	assert.True(t, requeue, "expected the machine to be requeued while the EIP is unassociated")
+	assert.Equal(t, alibabacloudproviderv1.CleanupInstanceOperation, r.instanceOperation())
+	assert.Equal(t, []string{stubEIPAllocationID}, r.providerStatus.ReleasedResources)
+
+	unassociated = true
+	assert.NoError(t, r.cleanupMachineResources())
+	assert.Equal(t, []string{stubEIPAllocationID, stubInUseEIPAllocationID}, released)
+	assert.Empty(t, r.instanceOperation())
+	assert.Empty(t, r.providerStatus.ReleasedResources)
+	assert.Len(t, eventRecorder.Events, 2)
+}
+
+func TestDeleteInstanceGoneBeforeExists(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+
+	released := false
+	mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).DoAndReturn(
+		func(request *ecs.DescribeInstancesRequest) (*ecs.DescribeInstancesResponse, error) {
+			// The instance is gone as soon as its release is requested
+			if released {
+				return &ecs.DescribeInstancesResponse{}, nil
+			}
+			return stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusStopped, "192.168.1.0"), nil
+		}).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DeleteInstances(gomock.Any()).DoAndReturn(
+		func(request *ecs.DeleteInstancesRequest) (*ecs.DeleteInstancesResponse, error) {
+			released = true
+			return &ecs.DeleteInstancesResponse{}, nil
+		}).Times(1)
+	mockAlibabaCloudClient.EXPECT().DescribeNetworkInterfaces(gomock.Any()).Return(&ecs.DescribeNetworkInterfacesResponse{}, nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DescribeLoadBalancers(gomock.Any()).Return(&slb.DescribeLoadBalancersResponse{}, nil).AnyTimes()
+	mockAlibabaCloudClient.EXPECT().DescribeEipAddresses(gomock.Any()).Return(&vpc.DescribeEipAddressesResponse{
+		TotalCount:   1,
+		EipAddresses: vpc.EipAddresses{EipAddress: []vpc.EipAddress{{AllocationId: stubEIPAllocationID, Status: VPCEIPAddressStatusAvailable}}},
+	}, nil).Times(1)
+	mockAlibabaCloudClient.EXPECT().ReleaseEipAddress(gomock.Any()).Return(&vpc.ReleaseEipAddressResponse{}, nil).Times(1)
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.UID = stubMachineUID
+	providerID := "alicloud://" + stubRegionID + "." + stubInstanceID
+	machine.Spec.ProviderID = &providerID
+	lastUpdated := metav1.NewTime(time.Now().Add(-time.Hour))
+	machine.Status.LastUpdated = &lastUpdated
+	machine.DeletionTimestamp = &lastUpdated
+
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		eventRecorder:      record.NewFakeRecorder(10),
+		machine:            machine,
+		providerSpec:       stubProviderConfig(),
+		providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	assert.NoError(t, r.DeleteMachine(context.TODO()))
+	assert.Equal(t, alibabacloudproviderv1.ReleaseInstanceOperation, r.instanceOperation())
+
+	// The machine is kept until its resources are released, even though its instance is already gone
+	exists, err := r.Exists(context.TODO())
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, r.DeleteMachine(context.TODO()))
+	assert.Empty(t, r.instanceOperation())
+
+	exists, err = r.Exists(context.TODO())
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestReleaseEIPAddressesUnassociateTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeEipAddresses(gomock.Any()).Return(&vpc.DescribeEipAddressesResponse{
+		TotalCount:   1,
+		EipAddresses: vpc.EipAddresses{EipAddress: []vpc.EipAddress{{AllocationId: stubInUseEIPAllocationID, Status: "Unassociating"}}},
+	}, nil).Times(2)
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventRecorder := record.NewFakeRecorder(10)
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		eventRecorder:      eventRecorder,
+		machine:            machine,
+		providerSpec:       stubProviderConfig(),
+		providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+	r.startInstanceOperation(alibabacloudproviderv1.CleanupInstanceOperation)
+
+	_, requeue := r.releaseEIPAddresses().(*machinecontroller.RequeueAfterError)
+	assert.True(t, requeue, "expected the machine to be requeued while the EIP is unassociated")
+
+	// The EIP is left in place once the timeout passed
+	startTime := metav1.NewTime(time.Now().Add(-eipUnassociateTimeout - time.Minute))
+	r.providerStatus.OperationStartTime = &startTime
+	assert.NoError(t, r.releaseEIPAddresses())
+	assert.Len(t, eventRecorder.Events, 1)
+}
+
+func TestDeregisterLoadBalancerBackends(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeLoadBalancers(gomock.Any()).DoAndReturn(
+		func(request *slb.DescribeLoadBalancersRequest) (*slb.DescribeLoadBalancersResponse, error) {
+			assert.Equal(t, stubInstanceID, request.ServerId)
+			return &slb.DescribeLoadBalancersResponse{LoadBalancers: slb.LoadBalancers{LoadBalancer: []slb.LoadBalancer{
+				{LoadBalancerId: stubLoadBalancerID},
+			}}}, nil
+		}).Times(1)
+	mockAlibabaCloudClient.EXPECT().DescribeLoadBalancerAttribute(gomock.Any()).Return(&slb.DescribeLoadBalancerAttributeResponse{
+		BackendServers: slb.BackendServersInDescribeLoadBalancerAttribute{BackendServer: []slb.BackendServerInDescribeLoadBalancerAttribute{
+			{ServerId: stubInstanceID},
+			{ServerId: "i-other"},
+		}},
+	}, nil).Times(1)
+	mockAlibabaCloudClient.EXPECT().RemoveBackendServers(gomock.Any()).DoAndReturn(
+		func(request *slb.RemoveBackendServersRequest) (*slb.RemoveBackendServersResponse, error) {
+			assert.Equal(t, stubLoadBalancerID, request.LoadBalancerId)
+			assert.JSONEq(t, `[{"ServerId": "`+stubInstanceID+`"}]`, request.BackendServers)
+			return &slb.RemoveBackendServersResponse{}, nil
+		}).Times(1)
+	mockAlibabaCloudClient.EXPECT().DescribeVServerGroups(gomock.Any()).Return(&slb.DescribeVServerGroupsResponse{
+		VServerGroups: slb.VServerGroups{VServerGroup: []slb.VServerGroup{{VServerGroupId: stubVServerGroupID}, {VServerGroupId: "rsp-other"}}},
+	}, nil).Times(1)
+	mockAlibabaCloudClient.EXPECT().DescribeVServerGroupAttribute(gomock.Any()).DoAndReturn(
+		func(request *slb.DescribeVServerGroupAttributeRequest) (*slb.DescribeVServerGroupAttributeResponse, error) {
+			backendServers := []slb.BackendServerInDescribeVServerGroupAttribute{{ServerId: "i-other", Port: 80}}
+			if request.VServerGroupId == stubVServerGroupID {
+				backendServers = append(backendServers, slb.BackendServerInDescribeVServerGroupAttribute{ServerId: stubInstanceID, Port: 6443})
+			}
+			return &slb.DescribeVServerGroupAttributeResponse{
+				BackendServers: slb.BackendServersInDescribeVServerGroupAttribute{BackendServer: backendServers},
+			}, nil
+		}).Times(2)
+	mockAlibabaCloudClient.EXPECT().RemoveVServerGroupBackendServers(gomock.Any()).DoAndReturn(
+		func(request *slb.RemoveVServerGroupBackendServersRequest) (*slb.RemoveVServerGroupBackendServersResponse, error) {
+			assert.Equal(t, stubVServerGroupID, request.VServerGroupId)
+			assert.JSONEq(t, `[{"ServerId": "`+stubInstanceID+`", "Port": 6443}]`, request.BackendServers)
+			return &slb.RemoveVServerGroupBackendServersResponse{}, nil
+		}).Times(1)
+
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReconciler(&machineScope{
+		Context:            context.Background(),
+		alibabacloudClient: mockAlibabaCloudClient,
+		machine:            machine,
+		providerSpec:       stubProviderConfig(),
+		providerStatus:     &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{},
+	})
+
+	assert.NoError(t, r.deregisterLoadBalancerBackends([]string{stubInstanceID}))
+}
@@ -90,13 +90,13 @@ func generateAlibabaCloudCredentialsSecretFromEnv(secretName, namespace string)
 	}
 }
 
-func stubProviderConfigSecurityGroups(groups []machinev1.AlibabaResourceReference) *machinev1.AlibabaCloudMachineProviderConfig {
+func stubProviderConfigSecurityGroups(groups []machinev1.AlibabaResourceReference) *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig {
 	pc := stubProviderConfig()
 	pc.SecurityGroups = groups
 	return pc
 }
 
-func stubProviderConfigResourceGroup(group machinev1.AlibabaResourceReference) *machinev1.AlibabaCloudMachineProviderConfig {
+func stubProviderConfigResourceGroup(group machinev1.AlibabaResourceReference) *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig {
 	pc := stubProviderConfig()
 	pc.SecurityGroups = []machinev1.AlibabaResourceReference{
 		{
@@ -113,61 +113,63 @@ func stubProviderConfigResourceGroup(group machinev1.AlibabaResourceReference) *
 	return pc
 }
 
-func stubProviderConfigVSwitches(group machinev1.AlibabaResourceReference) *machinev1.AlibabaCloudMachineProviderConfig {
+func stubProviderConfigVSwitches(group machinev1.AlibabaResourceReference) *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig {
 	pc := stubProviderConfig()
 	pc.VSwitch = group
 	return pc
 }
 
-func stubProviderConfig() *machinev1.AlibabaCloudMachineProviderConfig {
-	return &machinev1.AlibabaCloudMachineProviderConfig{
-		InstanceType: stubInstanceType,
-		ImageID:      stubImageID,
-		RegionID:     stubRegionID,
-		ZoneID:       stubZoneID,
-		SecurityGroups: []machinev1.AlibabaResourceReference{
-			{
+func stubProviderConfig() *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig {
+	return &alibabacloudproviderv1.AlibabaCloudMachineProviderConfig{
+		AlibabaCloudMachineProviderConfig: machinev1.AlibabaCloudMachineProviderConfig{
+			InstanceType: stubInstanceType,
+			ImageID:      stubImageID,
+			RegionID:     stubRegionID,
+			ZoneID:       stubZoneID,
+			SecurityGroups: []machinev1.AlibabaResourceReference{
+				{
+					Type: machinev1.AlibabaResourceReferenceTypeID,
+					ID:   &stubSecurityGroupID,
+				},
+			},
+			ResourceGroup: machinev1.AlibabaResourceReference{
 				Type: machinev1.AlibabaResourceReferenceTypeID,
-				ID:   &stubSecurityGroupID,
+				ID:   &stubResourceGroupID,
 			},
-		},
-		ResourceGroup: machinev1.AlibabaResourceReference{
-			Type: machinev1.AlibabaResourceReferenceTypeID,
-			ID:   &stubResourceGroupID,
-		},
-		VpcID: stubVpcID,
-		VSwitch: machinev1.AlibabaResourceReference{
-			Type: machinev1.AlibabaResourceReferenceTypeID,
-			ID:   &stubVSwitchID,
-		},
-		SystemDisk: machinev1.SystemDiskProperties{
-			Category: stubSystemDiskCategory,
-			Size:     int64(stubSystemDiskSize),
-		},
-		DataDisks: []machinev1.DataDiskProperties{
-			{
-				Size:             100,
-				Category:         "cloud_ssd",
-				DiskEncryption:   machinev1.AlibabaDiskEncryptionDisabled,
-				Name:             "my-disk",
-				SnapshotID:       "sp-xxx",
-				PerformanceLevel: "p2",
-				DiskPreservation: machinev1.DeleteWithInstance,
+			VpcID: stubVpcID,
+			VSwitch: machinev1.AlibabaResourceReference{
+				Type: machinev1.AlibabaResourceReferenceTypeID,
+				ID:   &stubVSwitchID,
+			},
+			SystemDisk: machinev1.SystemDiskProperties{
+				Category: stubSystemDiskCategory,
+				Size:     int64(stubSystemDiskSize),
+			},
+			DataDisks: []machinev1.DataDiskProperties{
+				{
+					Size:             100,
+					Category:         "cloud_ssd",
+					DiskEncryption:   machinev1.AlibabaDiskEncryptionDisabled,
+					Name:             "my-disk",
+					SnapshotID:       "sp-xxx",
+					PerformanceLevel: "p2",
+					DiskPreservation: machinev1.DeleteWithInstance,
+				},
+			},
+			Bandwidth: machinev1.BandwidthProperties{
+				InternetMaxBandwidthOut: int64(stubInternetMaxBandwidthOut),
+			},
+			UserDataSecret: &corev1.LocalObjectReference{
+				Name: alibabaCloudMasterUserDataSecretName,
+			},
+			CredentialsSecret: &corev1.LocalObjectReference{
+				Name: alibabaCloudCredentialsSecretName,
+			},
+			Tags: []machinev1.Tag{
+				{Key: "openshift-node-group-config", Value: "node-config-master"},
+				{Key: "host-type", Value: "master"},
+				{Key: "sub-host-type", Value: "default"},
 			},
-		},
-		Bandwidth: machinev1.BandwidthProperties{
-			InternetMaxBandwidthOut: int64(stubInternetMaxBandwidthOut),
-		},
-		UserDataSecret: &corev1.LocalObjectReference{
-			Name: alibabaCloudMasterUserDataSecretName,
-		},
-		CredentialsSecret: &corev1.LocalObjectReference{
-			Name: alibabaCloudCredentialsSecretName,
-		},
-		Tags: []machinev1.Tag{
-			{Key: "openshift-node-group-config", Value: "node-config-master"},
-			{Key: "host-type", Value: "master"},
-			{Key: "sub-host-type", Value: "default"},
 		},
 	}
 }
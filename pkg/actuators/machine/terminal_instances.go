@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// instanceTerminatedEventReason is the reason of the event recorded when the instance of the machine can not run anymore
+	instanceTerminatedEventReason = "InstanceTerminated"
+
+	// ecsLockReasonFinancial is the operation lock of instances stopped for overdue payment
+	ecsLockReasonFinancial = "financial"
+
+	// formatExpiredTime is the format of the expiration time of subscription instances
+	formatExpiredTime = "2006-01-02T15:04Z"
+)
+
+// terminalInstanceReason returns the reason and the message of the InstanceTerminated condition
+// when the instance can not run anymore, or empty strings when it can
+func terminalInstanceReason(instance *ecs.Instance) (string, string) {
+	switch instance.Status {
+	case ECSInstanceStatusReleased:
+		return alibabacloudproviderv1.InstanceReleasedConditionReason, fmt.Sprintf("instance %s was released", instance.InstanceId)
+	case ECSInstanceStatusExpired:
+		return alibabacloudproviderv1.InstanceExpiredConditionReason, fmt.Sprintf("instance %s expired", instance.InstanceId)
+	}
+
+	if instance.Status != ECSInstanceStatusStopped {
+		return "", ""
+	}
+
+	for _, lock := range instance.OperationLocks.LockReason {
+		if lock.LockReason == ecsLockReasonFinancial {
+			return alibabacloudproviderv1.InstanceOverduePaymentConditionReason, fmt.Sprintf("instance %s was stopped for overdue payment", instance.InstanceId)
+		}
+	}
+
+	if instance.InstanceChargeType == string(alibabacloudproviderv1.PrePaidInstanceChargeType) && instance.ExpiredTime != "" {
+		expiredTime, err := time.Parse(formatExpiredTime, instance.ExpiredTime)
+		if err == nil && expiredTime.Before(time.Now()) {
+			return alibabacloudproviderv1.InstanceExpiredConditionReason, fmt.Sprintf("instance %s expired at %s", instance.InstanceId, instance.ExpiredTime)
+		}
+	}
+
+	return "", ""
+}
+
+// getRecordedInstance looks up the instance recorded for the machine in any state, it returns nil when it was released.
+// Instances which can not run anymore, e.g. Expired instances, are not listed with the existing instances of the machine
+// but still exist until they are released.
+func (r *Reconciler) getRecordedInstance() (*ecs.Instance, error) {
+	instanceIDs := r.recordedInstanceIDs()
+	if len(instanceIDs) == 0 {
+		return nil, nil
+	}
+
+	instances, err := describeInstances(instanceIDs, r.providerSpec.RegionID, r.alibabacloudClient)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range instances {
+		instance := &instances[i]
+		if instance.Status != ECSInstanceStatusReleased && len(r.ownedInstances([]*ecs.Instance{instance})) > 0 {
+			return instance, nil
+		}
+	}
+	return nil, nil
+}
+
+// reportTerminalInstance records that the instance of the machine can not run anymore
+func (r *Reconciler) reportTerminalInstance(message string) {
+	klog.Errorf("%s: %s", r.machine.Name, message)
+	r.eventRecorder.Eventf(r.machine, corev1.EventTypeWarning, instanceTerminatedEventReason, "Machine failed: %s", message)
+}
+
+// recordTerminalInstance records that the instance of the machine can not run anymore in the InstanceTerminated
+// condition, the machine is then reported without instance so that the machine controller marks it failed
+func (r *Reconciler) recordTerminalInstance(reason string, message string) {
+	r.reportTerminalInstance(message)
+
+	condition := metav1.Condition{
+		Type:    string(alibabacloudproviderv1.InstanceTerminated),
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+	r.providerStatus.Conditions = setMachineProviderCondition(condition, r.providerStatus.Conditions)
+}
+
+// failureMessage returns why the machine failed as recorded in the conditions of its provider status,
+// or an empty string when it did not fail
+func (r *Reconciler) failureMessage() string {
+	if condition := findProviderCondition(r.providerStatus.Conditions, alibabacloudproviderv1.InstanceTerminated); condition != nil && condition.Status == metav1.ConditionTrue {
+		return condition.Message
+	}
+
+	if condition := findProviderCondition(r.providerStatus.Conditions, alibabacloudproviderv1.ProvisioningTimedOut); condition != nil &&
+		condition.Status == metav1.ConditionTrue && condition.Reason == alibabacloudproviderv1.ProvisioningFailedConditionReason {
+		return condition.Message
+	}
+
+	return ""
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestTerminalInstanceMessage(t *testing.T) {
+	cases := []struct {
+		name     string
+		instance ecs.Instance
+		terminal bool
+	}{
+		{
+			name:     "Running instance",
+			instance: ecs.Instance{Status: ECSInstanceStatusRunning},
+		},
+		{
+			name:     "Stopped instance",
+			instance: ecs.Instance{Status: ECSInstanceStatusStopped},
+		},
+		{
+			name:     "Released instance",
+			instance: ecs.Instance{Status: ECSInstanceStatusReleased},
+			terminal: true,
+		},
+		{
+			name:     "Expired instance",
+			instance: ecs.Instance{Status: ECSInstanceStatusExpired},
+			terminal: true,
+		},
+		{
+			name: "Instance stopped for overdue payment",
+			instance: ecs.Instance{
+				Status:         ECSInstanceStatusStopped,
+				OperationLocks: ecs.OperationLocksInDescribeInstances{LockReason: []ecs.LockReason{{LockReason: ecsLockReasonFinancial}}},
+			},
+			terminal: true,
+		},
+		{
+			name: "Stopped subscription instance past its expiration time",
+			instance: ecs.Instance{
+				Status:             ECSInstanceStatusStopped,
+				InstanceChargeType: string(alibabacloudproviderv1.PrePaidInstanceChargeType),
+				ExpiredTime:        time.Now().Add(-time.Hour).UTC().Format(formatExpiredTime),
+			},
+			terminal: true,
+		},
+		{
+			name: "Stopped subscription instance before its expiration time",
+			instance: ecs.Instance{
+				Status:             ECSInstanceStatusStopped,
+				InstanceChargeType: string(alibabacloudproviderv1.PrePaidInstanceChargeType),
+				ExpiredTime:        time.Now().Add(24 * time.Hour).UTC().Format(formatExpiredTime),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.instance.InstanceId = stubInstanceID
+			reason, message := terminalInstanceReason(&tc.instance)
+			assert.Equal(t, tc.terminal, message != "", "unexpected message: %q", message)
+			assert.Equal(t, tc.terminal, reason != "", "unexpected reason: %q", reason)
+		})
+	}
+}
+
+func TestExistsTerminalInstance(t *testing.T) {
+	providerID := "alicloud://" + stubRegionID + "." + stubInstanceID
+
+	stoppedForPayment := stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusStopped, "192.168.1.0")
+	stoppedForPayment.Instances.Instance[0].OperationLocks.LockReason = []ecs.LockReason{{LockReason: ecsLockReasonFinancial}}
+
+	cases := []struct {
+		name                      string
+		providerID                *string
+		deleting                  bool
+		describeInstancesResponse *ecs.DescribeInstancesResponse
+		exists                    bool
+		expectedEvents            int
+	}{
+		{
+			name:                      "Running instance",
+			providerID:                &providerID,
+			describeInstancesResponse: stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusRunning, "192.168.1.0"),
+			exists:                    true,
+		},
+		{
+			name:                      "Instance released outside of the machine API",
+			providerID:                &providerID,
+			describeInstancesResponse: &ecs.DescribeInstancesResponse{},
+			expectedEvents:            1,
+		},
+		{
+			name:                      "Instance released by the deletion of the machine",
+			providerID:                &providerID,
+			deleting:                  true,
+			describeInstancesResponse: &ecs.DescribeInstancesResponse{},
+		},
+		{
+			name:                      "Launched instance not visible yet",
+			describeInstancesResponse: &ecs.DescribeInstancesResponse{},
+		},
+		{
+			name:                      "Instance stopped for overdue payment",
+			providerID:                &providerID,
+			describeInstancesResponse: stoppedForPayment,
+			exists:                    true,
+		},
+		{
+			name:                      "Expired instance",
+			providerID:                &providerID,
+			describeInstancesResponse: stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusExpired, "192.168.1.0"),
+			exists:                    true,
+		},
+		{
+			name:                      "Expired instance of a deleted machine",
+			providerID:                &providerID,
+			deleting:                  true,
+			describeInstancesResponse: stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusExpired, "192.168.1.0"),
+			exists:                    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(tc.describeInstancesResponse, nil).AnyTimes()
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine.Spec.ProviderID = tc.providerID
+			lastUpdated := metav1.NewTime(time.Now().Add(-time.Hour))
+			machine.Status.LastUpdated = &lastUpdated
+			if tc.deleting {
+				machine.DeletionTimestamp = &lastUpdated
+			}
+
+			instanceID := stubInstanceID
+			eventRecorder := record.NewFakeRecorder(10)
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      eventRecorder,
+				machine:            machine,
+				providerSpec:       stubProviderConfig(),
+				providerStatus: &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+					AlibabaCloudMachineProviderStatus: machinev1.AlibabaCloudMachineProviderStatus{
+						InstanceID: &instanceID,
+					},
+				},
+			})
+
+			exists, err := r.Exists(context.TODO())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exists, exists)
+			assert.Len(t, eventRecorder.Events, tc.expectedEvents)
+		})
+	}
+}
+
+func TestUpdateTerminalInstance(t *testing.T) {
+	stoppedForPayment := stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusStopped, "192.168.1.0")
+	stoppedForPayment.Instances.Instance[0].OperationLocks.LockReason = []ecs.LockReason{{LockReason: ecsLockReasonFinancial}}
+
+	cases := []struct {
+		name                      string
+		describeInstancesResponse *ecs.DescribeInstancesResponse
+		expectedReason            string
+	}{
+		{
+			name:                      "Instance stopped for overdue payment",
+			describeInstancesResponse: stoppedForPayment,
+			expectedReason:            alibabacloudproviderv1.InstanceOverduePaymentConditionReason,
+		},
+		{
+			name:                      "Expired instance",
+			describeInstancesResponse: stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, ECSInstanceStatusExpired, "192.168.1.0"),
+			expectedReason:            alibabacloudproviderv1.InstanceExpiredConditionReason,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(tc.describeInstancesResponse, nil).AnyTimes()
+
+			machine, err := stubMasterMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			instanceID := stubInstanceID
+			eventRecorder := record.NewFakeRecorder(10)
+			r := NewReconciler(&machineScope{
+				Context:            context.Background(),
+				alibabacloudClient: mockAlibabaCloudClient,
+				eventRecorder:      eventRecorder,
+				machine:            machine,
+				providerSpec:       stubProviderConfig(),
+				providerStatus: &alibabacloudproviderv1.AlibabaCloudMachineProviderStatus{
+					AlibabaCloudMachineProviderStatus: machinev1.AlibabaCloudMachineProviderStatus{
+						InstanceID: &instanceID,
+					},
+				},
+			})
+
+			err = r.Update(context.TODO())
+			assert.Error(t, err)
+			assert.Len(t, eventRecorder.Events, 1)
+
+			condition := findProviderCondition(r.providerStatus.Conditions, alibabacloudproviderv1.InstanceTerminated)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, metav1.ConditionTrue, condition.Status)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+				assert.Contains(t, condition.Message, stubInstanceID)
+			}
+
+			// The failed machine is reported without instance, until it is deleted
+			exists, err := r.Exists(context.TODO())
+			assert.NoError(t, err)
+			assert.False(t, exists)
+
+			deletionTimestamp := metav1.Now()
+			machine.DeletionTimestamp = &deletionTimestamp
+			exists, err = r.Exists(context.TODO())
+			assert.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
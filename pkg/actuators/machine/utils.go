@@ -1,13 +1,9 @@
 package machine
 
 import (
-	"fmt"
-	"time"
-
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/klog"
 )
 
 // upstreamMachineClusterIDLabel is the label that a machine must have to identify the cluster to which it belongs
@@ -24,6 +20,12 @@ func supportedInstanceStates() []string {
 	}
 }
 
+// deletableInstanceStates returns the states of the instances released with their machine,
+// expired subscription instances are released as they are
+func deletableInstanceStates() []string {
+	return append(supportedInstanceStates(), ECSInstanceStatusExpired)
+}
+
 // validateMachine check the label that a machine must have to identify the cluster to which it belongs is present.
 func validateMachine(machine machinev1.Machine) error {
 	if machine.Labels[machinev1.MachineClusterIDLabel] == "" {
@@ -107,28 +109,3 @@ func updateExistingCondition(newCondition, existingCondition *metav1.Condition)
 func shouldUpdateCondition(newCondition, existingCondition *metav1.Condition) bool {
 	return newCondition.Reason != existingCondition.Reason || newCondition.Message != existingCondition.Message
 }
-
-// WaitForResult wait func
-func WaitForResult(name string, predicate func() (bool, interface{}, error), returnWhenError bool, delay int, timeout int) (interface{}, error) {
-	endTime := time.Now().Add(time.Duration(timeout) * time.Second)
-	delaySecond := time.Duration(delay) * time.Second
-	for {
-		// Execute the function
-		satisfied, result, err := predicate()
-		if err != nil {
-			klog.Errorf("%s Invoke func %++s error %++v", name, "predicate func() (bool, error)", err)
-			if returnWhenError {
-				return result, err
-			}
-		}
-		if satisfied {
-			return result, nil
-		}
-		// Sleep
-		time.Sleep(delaySecond)
-		// If a timeout is set, and that's been exceeded, shut it down
-		if timeout >= 0 && time.Now().After(endTime) {
-			return nil, fmt.Errorf("wait for %s timeout", name)
-		}
-	}
-}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	"k8s.io/klog"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// describeVSwitchesPageSize is the maximum number of vswitches returned by DescribeVSwitches
+const describeVSwitchesPageSize = 50
+
+// rankVSwitches returns the IDs of the vswitches in the zone, the ones with the most available IP addresses first.
+// An empty zone keeps the vswitches of all zones.
+func rankVSwitches(vSwitches []vpc.VSwitch, zoneID string) []string {
+	candidates := make([]vpc.VSwitch, 0, len(vSwitches))
+	for _, vSwitch := range vSwitches {
+		if zoneID != "" && vSwitch.ZoneId != zoneID {
+			continue
+		}
+		candidates = append(candidates, vSwitch)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].AvailableIpAddressCount > candidates[j].AvailableIpAddressCount
+	})
+
+	vSwitchIDs := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		vSwitchIDs = append(vSwitchIDs, candidate.VSwitchId)
+	}
+	return vSwitchIDs
+}
+
+// isVSwitchIPExhaustedError returns true when the instance could not be created because its vswitch has no free IP address left
+func isVSwitchIPExhaustedError(err error) bool {
+	var serverError *sdkerrors.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	return strings.Contains(serverError.ErrorCode(), "IpNotEnough")
+}
+
+// runInstancesInVSwitches runs the RunInstances request in the first vswitch ranked for the machine,
+// and falls back to the next vswitch while the vswitch has no free IP address left.
+// A static private IP address belongs to a single vswitch, so other vswitches are only tried without it.
+func runInstancesInVSwitches(machine runtimeclient.ObjectKey, request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig,
+	vSwitchIDs []string, client alibabacloudClient.Client, run func() (*ecs.RunInstancesResponse, error)) (*ecs.RunInstancesResponse, error) {
+	// A previous attempt may have left the request in another vswitch
+	if len(vSwitchIDs) > 0 && request.VSwitchId != vSwitchIDs[0] {
+		if err := setVSwitch(machine, request, machineProviderConfig, vSwitchIDs[0], client); err != nil {
+			return nil, err
+		}
+	}
+
+	runResponse, err := run()
+	for next := 1; err != nil && isVSwitchIPExhaustedError(err) && next < len(vSwitchIDs) && machineProviderConfig.PrivateIPAddress == ""; next++ {
+		klog.Warningf("%s: vswitch %s has no free IP address left, falling back to vswitch %s: %v", machine.Name, request.VSwitchId, vSwitchIDs[next], err)
+		if err := setVSwitch(machine, request, machineProviderConfig, vSwitchIDs[next], client); err != nil {
+			return nil, err
+		}
+		runResponse, err = run()
+	}
+	return runResponse, err
+}
+
+// setVSwitch switches the RunInstances request to the vswitch, along with its IPv6 addresses
+func setVSwitch(machine runtimeclient.ObjectKey, request *ecs.RunInstancesRequest, machineProviderConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig, vSwitchID string, client alibabacloudClient.Client) error {
+	request.VSwitchId = vSwitchID
+	if machineProviderConfig.IPv6 != nil {
+		return setIPv6Options(machine, request, machineProviderConfig, vSwitchID, client)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"net/http"
+	"testing"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	"github.com/golang/mock/gomock"
+	machinev1 "github.com/openshift/api/machine/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	stubFullVSwitchID  = "vsw-bp1full0vswitch0id0"
+	stubOtherZoneID    = "cn-beijing-g"
+	stubOtherVSwitchID = "vsw-bp1other0zone0vswitch"
+)
+
+func stubZonedDescribeVSwitchesResponse() *vpc.DescribeVSwitchesResponse {
+	return &vpc.DescribeVSwitchesResponse{
+		VSwitches: vpc.VSwitches{
+			VSwitch: []vpc.VSwitch{
+				{VSwitchId: stubFullVSwitchID, ZoneId: stubZoneID, AvailableIpAddressCount: 3},
+				{VSwitchId: stubOtherVSwitchID, ZoneId: stubOtherZoneID, AvailableIpAddressCount: 4000},
+				{VSwitchId: stubVSwitchID, ZoneId: stubZoneID, AvailableIpAddressCount: 250},
+			},
+		},
+	}
+}
+
+func stubProviderConfigVSwitchTags() *machinev1.AlibabaResourceReference {
+	return &machinev1.AlibabaResourceReference{
+		Type: machinev1.AlibabaResourceReferenceTypeTags,
+		Tags: &[]machinev1.Tag{{Key: "Name", Value: "test-vswitch"}},
+	}
+}
+
+func TestRankVSwitches(t *testing.T) {
+	vSwitches := stubZonedDescribeVSwitchesResponse().VSwitches.VSwitch
+
+	assert.Equal(t, []string{stubVSwitchID, stubFullVSwitchID}, rankVSwitches(vSwitches, stubZoneID))
+	assert.Equal(t, []string{stubOtherVSwitchID}, rankVSwitches(vSwitches, stubOtherZoneID))
+	assert.Equal(t, []string{stubOtherVSwitchID, stubVSwitchID, stubFullVSwitchID}, rankVSwitches(vSwitches, ""))
+	assert.Empty(t, rankVSwitches(vSwitches, "cn-beijing-h"))
+}
+
+func TestGetVSwitchIDsFromTags(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+	mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).DoAndReturn(
+		func(request *vpc.DescribeVSwitchesRequest) (*vpc.DescribeVSwitchesResponse, error) {
+			assert.Equal(t, stubZoneID, request.ZoneId)
+			assert.Equal(t, stubRegionID, request.RegionId)
+			return stubZonedDescribeVSwitchesResponse(), nil
+		}).Times(1)
+
+	providerConfig := stubProviderConfig()
+	providerConfig.VSwitch = *stubProviderConfigVSwitchTags()
+
+	machineKey := runtimeclient.ObjectKey{Name: stubMasterMachineName, Namespace: defaultNamespace}
+	vSwitchIDs, err := getVSwitchIDsFromTags(machineKey, providerConfig, mockAlibabaCloudClient)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{stubVSwitchID, stubFullVSwitchID}, vSwitchIDs)
+	}
+}
+
+func TestRunInstancesVSwitchFallback(t *testing.T) {
+	machine, err := stubMasterMachine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipExhaustedErr := sdkerrors.NewServerError(http.StatusForbidden, `{"Code": "InvalidVSwitchId.IpNotEnough"}`, "")
+
+	cases := []struct {
+		name             string
+		vSwitch          *machinev1.AlibabaResourceReference
+		expectedRequests []string
+		succeeds         bool
+	}{
+		{
+			name:             "Fall back to the next vswitch",
+			vSwitch:          stubProviderConfigVSwitchTags(),
+			expectedRequests: []string{stubVSwitchID, stubFullVSwitchID},
+			succeeds:         true,
+		},
+		{
+			name:             "No other vswitch",
+			expectedRequests: []string{stubVSwitchID},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockAlibabaCloudClient := mock.NewMockClient(mockCtrl)
+			mockAlibabaCloudClient.EXPECT().DescribeImages(gomock.Any()).Return(stubDescribeImagesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().ListResourceGroups(gomock.Any()).Return(stubListResourceGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeSecurityGroups(gomock.Any()).Return(stubDescribeSecurityGroupsResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeVSwitches(gomock.Any()).Return(stubZonedDescribeVSwitchesResponse(), nil).AnyTimes()
+			mockAlibabaCloudClient.EXPECT().DescribeInstances(gomock.Any()).Return(stubDescribeInstancesWithParamsResponse(stubImageID, stubInstanceID, stubInstanceStatus, "192.168.1.0"), nil).AnyTimes()
+
+			requests := make([]string, 0)
+			mockAlibabaCloudClient.EXPECT().RunInstances(gomock.Any()).DoAndReturn(
+				func(request *ecs.RunInstancesRequest) (*ecs.RunInstancesResponse, error) {
+					requests = append(requests, request.VSwitchId)
+					assert.Equal(t, stubZoneID, request.ZoneId)
+					if request.VSwitchId == stubVSwitchID {
+						return nil, ipExhaustedErr
+					}
+					return stubRunInstancesResponse(), nil
+				}).Times(len(tc.expectedRequests))
+
+			providerConfig := stubProviderConfig()
+			if tc.vSwitch != nil {
+				providerConfig.VSwitch = *tc.vSwitch
+			}
+
+			_, err := runInstances(machine, providerConfig, "", new(int32), mockAlibabaCloudClient, record.NewFakeRecorder(10))
+			assert.Equal(t, tc.succeeds, err == nil, "unexpected error: %v", err)
+			assert.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
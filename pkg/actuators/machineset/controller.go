@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -27,13 +28,16 @@ import (
 	"github.com/go-logr/logr"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
 	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 const (
@@ -43,6 +47,12 @@ const (
 	cpuKey    = "machine.openshift.io/vCPU"
 	memoryKey = "machine.openshift.io/memoryMb"
 	gpuKey    = "machine.openshift.io/GPU"
+
+	requeueAfterSeconds = 20
+
+	// deploymentSetDeleteTimeout is how long the deletion of a MachineSet waits for the instances
+	// of its deployment set to be released once its Machines are gone, the deployment set is left behind after it
+	deploymentSetDeleteTimeout = 10 * time.Minute
 )
 
 // Reconciler reconciles machineSets.
@@ -86,10 +96,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 		return ctrl.Result{}, err
 	}
 
-	// Ignore deleted MachineSets, this can happen when foregroundDeletion
-	// is enabled
+	// Deleted MachineSets only need to release the resources they own,
+	// this can happen when foregroundDeletion is enabled
 	if !machineSet.DeletionTimestamp.IsZero() {
-		return ctrl.Result{}, nil
+		return r.reconcileDelete(ctx, machineSet)
 	}
 	originalMachineSetToPatch := client.MergeFrom(machineSet.DeepCopy())
 
@@ -119,6 +129,12 @@ func (r *Reconciler) reconcile(machineSet *machinev1beta1.MachineSet) (ctrl.Resu
 		return ctrl.Result{}, mapierrors.InvalidMachineConfiguration("failed to get providerConfig: %v", err)
 	}
 
+	if usesMachineSetDeploymentSet(providerConfig) {
+		if err := r.reconcileDeploymentSet(machineSet, providerConfig); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	instanceType, err := r.getInstanceType(machineSet, providerConfig)
 	if err != nil {
 		klog.Errorf("Unable to set scale from zero annotations: unknown instance type: %s", providerConfig.InstanceType)
@@ -141,6 +157,132 @@ func (r *Reconciler) reconcile(machineSet *machinev1beta1.MachineSet) (ctrl.Resu
 	return ctrl.Result{}, nil
 }
 
+// reconcileDeploymentSet makes sure the deployment set owned by the MachineSet exists
+// and will be deleted together with the MachineSet.
+func (r *Reconciler) reconcileDeploymentSet(machineSet *machinev1beta1.MachineSet, providerConfig *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) error {
+	aliClient, err := r.newAlibabaCloudClient(machineSet, providerConfig)
+	if err != nil {
+		return err
+	}
+
+	controllerutil.AddFinalizer(machineSet, deploymentSetFinalizer)
+
+	if _, err := ensureDeploymentSet(aliClient, machineSet, providerConfig); err != nil {
+		return fmt.Errorf("failed to ensure deployment set: %w", err)
+	}
+
+	return nil
+}
+
+// reconcileDelete deletes the deployment set owned by the MachineSet and removes the finalizer once it is gone.
+// The Machines of the MachineSet are deleted first, as they are only garbage collected once the MachineSet is gone.
+func (r *Reconciler) reconcileDelete(ctx context.Context, machineSet *machinev1beta1.MachineSet) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(machineSet, deploymentSetFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	originalMachineSetToPatch := client.MergeFrom(machineSet.DeepCopy())
+
+	if controllerutil.ContainsFinalizer(machineSet, metav1.FinalizerOrphanDependents) {
+		// The orphaned Machines keep their instances in the deployment set, leave it behind
+		r.recorder.Eventf(machineSet, corev1.EventTypeWarning, "FailedDelete", "Unable to delete deployment set, the Machines of the MachineSet are orphaned")
+	} else if result, err := r.deleteDeploymentSet(ctx, machineSet); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	controllerutil.RemoveFinalizer(machineSet, deploymentSetFinalizer)
+	if err := r.Client.Patch(ctx, machineSet, originalMachineSetToPatch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to patch machineSet: %v", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deleteDeploymentSet deletes the Machines of the MachineSet, then its deployment set once their instances are released.
+// The returned result requeues the MachineSet while the deployment set can not be deleted yet.
+func (r *Reconciler) deleteDeploymentSet(ctx context.Context, machineSet *machinev1beta1.MachineSet) (ctrl.Result, error) {
+	remaining, err := r.deleteMachineSetMachines(ctx, machineSet)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if remaining > 0 {
+		klog.Infof("%s: waiting for %d machines to be deleted", machineSet.Name, remaining)
+		return ctrl.Result{RequeueAfter: requeueAfterSeconds * time.Second}, nil
+	}
+
+	providerConfig, err := alibabacloudproviderv1.ProviderSpecFromRawExtension(machineSet.Spec.Template.Spec.ProviderSpec.Value)
+	if err != nil {
+		// Without a valid providerSpec the deployment set can not be found, leave it behind
+		r.recorder.Eventf(machineSet, corev1.EventTypeWarning, "FailedDelete", "Unable to delete deployment set, failed to get providerConfig: %v", err)
+		return ctrl.Result{}, nil
+	}
+
+	aliClient, err := r.newAlibabaCloudClient(machineSet, providerConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	deleted, err := deleteDeploymentSet(aliClient, machineSet, providerConfig)
+	if err != nil {
+		r.recorder.Eventf(machineSet, corev1.EventTypeWarning, "FailedDelete", "Failed to delete deployment set: %v", err)
+		return ctrl.Result{}, err
+	}
+	if deleted {
+		return ctrl.Result{}, nil
+	}
+
+	// The instances left in the deployment set are not owned by Machines of the MachineSet anymore
+	if time.Since(machineSet.DeletionTimestamp.Time) > deploymentSetDeleteTimeout {
+		r.recorder.Eventf(machineSet, corev1.EventTypeWarning, "FailedDelete", "Unable to delete deployment set, it still has instances after %v", deploymentSetDeleteTimeout)
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: requeueAfterSeconds * time.Second}, nil
+}
+
+// deleteMachineSetMachines deletes the Machines controlled by the MachineSet, their instances are released by the
+// machine controller. It returns the number of Machines which still exist.
+func (r *Reconciler) deleteMachineSetMachines(ctx context.Context, machineSet *machinev1beta1.MachineSet) (int, error) {
+	machines := &machinev1beta1.MachineList{}
+	if err := r.Client.List(ctx, machines, client.InNamespace(machineSet.Namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list machines: %w", err)
+	}
+
+	remaining := 0
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if !metav1.IsControlledBy(machine, machineSet) {
+			continue
+		}
+
+		remaining++
+		if !machine.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to delete machine %s: %w", machine.Name, err)
+		}
+		klog.Infof("%s: deleted machine %s", machineSet.Name, machine.Name)
+	}
+
+	return remaining, nil
+}
+
+// newAlibabaCloudClient creates an alibabacloud client from the credentials referenced in the providerSpec
+func (r *Reconciler) newAlibabaCloudClient(machineSet *machinev1beta1.MachineSet, providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) (alibabacloudClient.Client, error) {
+	credentialsSecretName := ""
+	if providerSpec.CredentialsSecret != nil {
+		credentialsSecretName = providerSpec.CredentialsSecret.Name
+	}
+
+	aliClient, err := alibabacloudClient.NewClient(r.Client, credentialsSecretName, machineSet.Namespace, providerSpec.RegionID, nil)
+	if err != nil {
+		klog.Errorf("Failed to create alibabacloud client: %v", err)
+		return nil, err
+	}
+
+	return aliClient, nil
+}
+
 func isInvalidConfigurationError(err error) bool {
 	switch t := err.(type) {
 	case *mapierrors.MachineError:
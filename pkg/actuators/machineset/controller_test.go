@@ -12,3 +12,78 @@ limitations under the License.
 */
 
 package machineset
+
+import (
+	"context"
+	"testing"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func stubMachineSetMachine(machineSet *machinev1beta1.MachineSet, name string, finalizers ...string) *machinev1beta1.Machine {
+	return &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       machineSet.Namespace,
+			Finalizers:      finalizers,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, machinev1beta1.GroupVersion.WithKind("MachineSet"))},
+		},
+	}
+}
+
+func TestReconcileDeleteMachines(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, machinev1beta1.AddToScheme(scheme))
+
+	machineSet, _ := stubDeploymentSetMachineSet()
+	deletionTimestamp := metav1.Now()
+	machineSet.DeletionTimestamp = &deletionTimestamp
+	machineSet.Finalizers = []string{deploymentSetFinalizer}
+
+	otherMachine := &machinev1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: machineSet.Namespace}}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		machineSet.DeepCopy(),
+		stubMachineSetMachine(machineSet, "running", "machine.machine.openshift.io"),
+		stubMachineSetMachine(machineSet, "stopped"),
+		otherMachine,
+	).Build()
+
+	r := &Reconciler{Client: fakeClient, recorder: record.NewFakeRecorder(10)}
+
+	// The MachineSet waits for its Machines to be deleted, they are not garbage collected while it exists
+	for i := 0; i < 2; i++ {
+		result, err := r.reconcileDelete(context.TODO(), machineSet)
+		assert.NoError(t, err)
+		assert.NotZero(t, result.RequeueAfter)
+		assert.Contains(t, machineSet.Finalizers, deploymentSetFinalizer)
+	}
+
+	machines := &machinev1beta1.MachineList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), machines, client.InNamespace(machineSet.Namespace)))
+	names := make([]string, 0)
+	for _, machine := range machines.Items {
+		names = append(names, machine.Name)
+		if machine.Name == "running" {
+			assert.NotNil(t, machine.DeletionTimestamp)
+		} else {
+			assert.Nil(t, machine.DeletionTimestamp)
+		}
+	}
+	assert.ElementsMatch(t, []string{"running", "other"}, names)
+
+	// The Machines orphaned by the deletion keep the deployment set
+	orphaned := &machinev1beta1.MachineSet{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: machineSet.Name, Namespace: machineSet.Namespace}, orphaned))
+	orphaned.Finalizers = append(orphaned.Finalizers, metav1.FinalizerOrphanDependents)
+	result, err := r.reconcileDelete(context.TODO(), orphaned)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.NotContains(t, orphaned.Finalizers, deploymentSetFinalizer)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	machineactuator "github.com/openshift/cluster-api-provider-alibaba/pkg/actuators/machine"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	mapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+
+	"k8s.io/klog"
+)
+
+const (
+	// deploymentSetFinalizer is set on MachineSets owning a deployment set,
+	// so that the deployment set is deleted together with the MachineSet
+	deploymentSetFinalizer = "machine.openshift.io/alibabacloud-deployment-set"
+)
+
+// usesMachineSetDeploymentSet returns true if the machines of the MachineSet are placed in a deployment set owned by the MachineSet
+func usesMachineSetDeploymentSet(providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) bool {
+	return providerSpec.DeploymentSet != nil && providerSpec.DeploymentSet.Type == alibabacloudproviderv1.DeploymentSetReferenceTypeMachineSet
+}
+
+// getMachineSetClusterID returns the cluster ID from the MachineSet labels, or from the labels of its template
+func getMachineSetClusterID(machineSet *machinev1beta1.MachineSet) (string, bool) {
+	if clusterID, ok := machineSet.Labels[machinev1beta1.MachineClusterIDLabel]; ok && clusterID != "" {
+		return clusterID, true
+	}
+	clusterID, ok := machineSet.Spec.Template.Labels[machinev1beta1.MachineClusterIDLabel]
+	return clusterID, ok && clusterID != ""
+}
+
+// deploymentSetDescription returns the description of the deployment set owned by the MachineSet
+func deploymentSetDescription(machineSet *machinev1beta1.MachineSet) string {
+	return fmt.Sprintf("Deployment set of MachineSet %s/%s", machineSet.Namespace, machineSet.Name)
+}
+
+// getDeploymentSet returns the deployment set owned by the MachineSet, and whether it carries its ownership tags.
+// The deployment set is looked up by its ownership tags. A deployment set which could not be tagged after it was
+// created is found by its exact name and description instead, so that it is tagged rather than leaked.
+func getDeploymentSet(client alibabacloudClient.Client, machineSet *machinev1beta1.MachineSet, regionID, clusterID string) (*ecs.DeploymentSet, bool, error) {
+	id, err := machineactuator.GetMachineSetDeploymentSetID(client, regionID, clusterID, machineSet.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	if id != "" {
+		deploymentSet, err := machineactuator.GetDeploymentSetByID(client, regionID, id)
+		return deploymentSet, true, err
+	}
+
+	deploymentSet, err := machineactuator.GetDeploymentSetByName(client, regionID, machineactuator.MachineSetDeploymentSetName(clusterID, machineSet.Name))
+	if err != nil {
+		return nil, false, err
+	}
+	if deploymentSet == nil || deploymentSet.DeploymentSetDescription != deploymentSetDescription(machineSet) {
+		return nil, false, nil
+	}
+	return deploymentSet, false, nil
+}
+
+// tagDeploymentSet sets the ownership tags on the deployment set owned by the MachineSet
+func tagDeploymentSet(client alibabacloudClient.Client, machineSet *machinev1beta1.MachineSet, regionID, clusterID, deploymentSetID string) error {
+	tagRequest := ecs.CreateTagResourcesRequest()
+	tagRequest.Scheme = "https"
+	tagRequest.RegionId = regionID
+	tagRequest.ResourceType = machineactuator.ECSTagResourceTypeDeploymentSet
+	tagRequest.ResourceId = &[]string{deploymentSetID}
+	tagRequest.Tag = machineactuator.MachineSetDeploymentSetTags(clusterID, machineSet.Name)
+
+	if _, err := client.TagResources(tagRequest); err != nil {
+		return fmt.Errorf("error tagging deployment set %s: %v", deploymentSetID, err)
+	}
+	return nil
+}
+
+// ensureDeploymentSet creates the deployment set owned by the MachineSet if it does not exist yet and returns its ID
+func ensureDeploymentSet(client alibabacloudClient.Client, machineSet *machinev1beta1.MachineSet, providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) (string, error) {
+	clusterID, ok := getMachineSetClusterID(machineSet)
+	if !ok {
+		return "", mapierrors.InvalidMachineConfiguration("%v: missing %q label", machineSet.Name, machinev1beta1.MachineClusterIDLabel)
+	}
+
+	deploymentSet, tagged, err := getDeploymentSet(client, machineSet, providerSpec.RegionID, clusterID)
+	if err != nil {
+		return "", err
+	}
+	if deploymentSet != nil {
+		if !tagged {
+			klog.Infof("%s: tagging deployment set %s", machineSet.Name, deploymentSet.DeploymentSetId)
+			if err := tagDeploymentSet(client, machineSet, providerSpec.RegionID, clusterID, deploymentSet.DeploymentSetId); err != nil {
+				return "", err
+			}
+		}
+		return deploymentSet.DeploymentSetId, nil
+	}
+
+	name := machineactuator.MachineSetDeploymentSetName(clusterID, machineSet.Name)
+
+	strategy := providerSpec.DeploymentSet.Strategy
+	if strategy == "" {
+		strategy = alibabacloudproviderv1.AvailabilityDeploymentSetStrategy
+	}
+
+	createRequest := ecs.CreateCreateDeploymentSetRequest()
+	createRequest.Scheme = "https"
+	createRequest.RegionId = providerSpec.RegionID
+	createRequest.DeploymentSetName = name
+	createRequest.Description = deploymentSetDescription(machineSet)
+	createRequest.Strategy = string(strategy)
+	createRequest.ClientToken = string(machineSet.UID)
+
+	createResponse, err := client.CreateDeploymentSet(createRequest)
+	if err != nil {
+		return "", fmt.Errorf("error creating deployment set %s: %v", name, err)
+	}
+	klog.Infof("%s: created deployment set %s (%s)", machineSet.Name, name, createResponse.DeploymentSetId)
+
+	if err := tagDeploymentSet(client, machineSet, providerSpec.RegionID, clusterID, createResponse.DeploymentSetId); err != nil {
+		return "", err
+	}
+
+	return createResponse.DeploymentSetId, nil
+}
+
+// deleteDeploymentSet deletes the deployment set owned by the MachineSet.
+// It returns false while instances are still placed in the deployment set.
+func deleteDeploymentSet(client alibabacloudClient.Client, machineSet *machinev1beta1.MachineSet, providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) (bool, error) {
+	clusterID, ok := getMachineSetClusterID(machineSet)
+	if !ok {
+		klog.Warningf("%s: missing %q label, unable to find deployment set", machineSet.Name, machinev1beta1.MachineClusterIDLabel)
+		return true, nil
+	}
+
+	name := machineactuator.MachineSetDeploymentSetName(clusterID, machineSet.Name)
+	deploymentSet, _, err := getDeploymentSet(client, machineSet, providerSpec.RegionID, clusterID)
+	if err != nil {
+		return false, err
+	}
+	if deploymentSet == nil {
+		return true, nil
+	}
+
+	if deploymentSet.InstanceAmount > 0 {
+		klog.Infof("%s: deployment set %s still has %d instances, waiting for them to be deleted", machineSet.Name, name, deploymentSet.InstanceAmount)
+		return false, nil
+	}
+
+	deleteRequest := ecs.CreateDeleteDeploymentSetRequest()
+	deleteRequest.Scheme = "https"
+	deleteRequest.RegionId = providerSpec.RegionID
+	deleteRequest.DeploymentSetId = deploymentSet.DeploymentSetId
+
+	if _, err := client.DeleteDeploymentSet(deleteRequest); err != nil {
+		return false, fmt.Errorf("error deleting deployment set %s: %v", name, err)
+	}
+	klog.Infof("%s: deleted deployment set %s (%s)", machineSet.Name, name, deploymentSet.DeploymentSetId)
+
+	return true, nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineset
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/golang/mock/gomock"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
+	"github.com/openshift/cluster-api-provider-alibaba/pkg/client/mock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	stubClusterID         = "alibabacloud-actuator-cluster"
+	stubMachineSetName    = "alibabacloud-actuator-testing-machineset"
+	stubDeploymentSetID   = "ds-bp13v7bjnj9gisnlo6ow"
+	stubDeploymentSetName = stubClusterID + "-" + stubMachineSetName
+)
+
+func stubDeploymentSetMachineSet() (*machinev1beta1.MachineSet, *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) {
+	machineSet := &machinev1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stubMachineSetName,
+			Namespace: "openshift-machine-api",
+			UID:       "4e7a2a4c-4a6c-4f7b-9d2c-0a1e9f1f2b3c",
+			Labels: map[string]string{
+				machinev1beta1.MachineClusterIDLabel: stubClusterID,
+			},
+		},
+	}
+	providerConfig := &alibabacloudproviderv1.AlibabaCloudMachineProviderConfig{
+		DeploymentSet: &alibabacloudproviderv1.DeploymentSetReference{
+			Type: alibabacloudproviderv1.DeploymentSetReferenceTypeMachineSet,
+		},
+	}
+	providerConfig.RegionID = "cn-beijing"
+	return machineSet, providerConfig
+}
+
+func stubListTagResourcesResponse(deploymentSetIDs ...string) *ecs.ListTagResourcesResponse {
+	tagResources := make([]ecs.TagResource, 0)
+	for _, id := range deploymentSetIDs {
+		tagResources = append(tagResources, ecs.TagResource{ResourceId: id, TagKey: "Name", TagValue: stubDeploymentSetName})
+	}
+	return &ecs.ListTagResourcesResponse{TagResources: ecs.TagResources{TagResource: tagResources}}
+}
+
+func TestEnsureDeploymentSet(t *testing.T) {
+	machineSet, _ := stubDeploymentSetMachineSet()
+	description := deploymentSetDescription(machineSet)
+
+	cases := []struct {
+		name           string
+		taggedIDs      []string
+		deploymentSets []ecs.DeploymentSet
+		expectCreate   bool
+		expectTag      bool
+	}{
+		{
+			name:           "Existing deployment set",
+			taggedIDs:      []string{stubDeploymentSetID},
+			deploymentSets: []ecs.DeploymentSet{{DeploymentSetId: stubDeploymentSetID, DeploymentSetName: stubDeploymentSetName, DeploymentSetDescription: description}},
+		},
+		{
+			name:           "Existing deployment set missing its tags is tagged",
+			deploymentSets: []ecs.DeploymentSet{{DeploymentSetId: stubDeploymentSetID, DeploymentSetName: stubDeploymentSetName, DeploymentSetDescription: description}},
+			expectTag:      true,
+		},
+		{
+			name:           "Deployment set with the same name not owned by the MachineSet is ignored",
+			deploymentSets: []ecs.DeploymentSet{{DeploymentSetId: "ds-other", DeploymentSetName: stubDeploymentSetName, DeploymentSetDescription: "other"}},
+			expectCreate:   true,
+			expectTag:      true,
+		},
+		{
+			name:         "Deployment set is created and tagged",
+			expectCreate: true,
+			expectTag:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAlibabaCloudClient := mock.NewMockClient(gomock.NewController(t))
+			machineSet, providerConfig := stubDeploymentSetMachineSet()
+
+			mockAlibabaCloudClient.EXPECT().ListTagResources(gomock.Any()).DoAndReturn(
+				func(request *ecs.ListTagResourcesRequest) (*ecs.ListTagResourcesResponse, error) {
+					assert.Equal(t, "deploymentset", request.ResourceType)
+					assert.Contains(t, *request.Tag, ecs.ListTagResourcesTag{Key: "kubernetes.io/cluster/" + stubClusterID, Value: "owned"})
+					assert.Contains(t, *request.Tag, ecs.ListTagResourcesTag{Key: "Name", Value: stubDeploymentSetName})
+					return stubListTagResourcesResponse(tc.taggedIDs...), nil
+				}).Times(1)
+			mockAlibabaCloudClient.EXPECT().DescribeDeploymentSets(gomock.Any()).Return(&ecs.DescribeDeploymentSetsResponse{
+				DeploymentSets: ecs.DeploymentSets{DeploymentSet: tc.deploymentSets},
+			}, nil).Times(1)
+
+			expectedID := stubDeploymentSetID
+			if tc.expectCreate {
+				expectedID = "ds-created"
+				mockAlibabaCloudClient.EXPECT().CreateDeploymentSet(gomock.Any()).DoAndReturn(
+					func(request *ecs.CreateDeploymentSetRequest) (*ecs.CreateDeploymentSetResponse, error) {
+						assert.Equal(t, stubDeploymentSetName, request.DeploymentSetName)
+						assert.Equal(t, description, request.Description)
+						assert.Equal(t, "Availability", request.Strategy)
+						assert.Equal(t, string(machineSet.UID), request.ClientToken)
+						return &ecs.CreateDeploymentSetResponse{DeploymentSetId: expectedID}, nil
+					}).Times(1)
+			}
+			if tc.expectTag {
+				mockAlibabaCloudClient.EXPECT().TagResources(gomock.Any()).DoAndReturn(
+					func(request *ecs.TagResourcesRequest) (*ecs.TagResourcesResponse, error) {
+						assert.Equal(t, "deploymentset", request.ResourceType)
+						assert.Equal(t, &[]string{expectedID}, request.ResourceId)
+						assert.Contains(t, *request.Tag, ecs.TagResourcesTag{Key: "kubernetes.io/cluster/" + stubClusterID, Value: "owned"})
+						return &ecs.TagResourcesResponse{}, nil
+					}).Times(1)
+			}
+
+			id, err := ensureDeploymentSet(mockAlibabaCloudClient, machineSet, providerConfig)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedID, id)
+		})
+	}
+
+	t.Run("Missing cluster ID", func(t *testing.T) {
+		mockAlibabaCloudClient := mock.NewMockClient(gomock.NewController(t))
+		machineSet, providerConfig := stubDeploymentSetMachineSet()
+		machineSet.Labels = nil
+
+		_, err := ensureDeploymentSet(mockAlibabaCloudClient, machineSet, providerConfig)
+		assert.True(t, isInvalidConfigurationError(err))
+	})
+}
+
+func TestDeleteDeploymentSet(t *testing.T) {
+	cases := []struct {
+		name            string
+		deploymentSets  []ecs.DeploymentSet
+		expectDelete    bool
+		deleteErr       error
+		expectedDeleted bool
+		succeeds        bool
+	}{
+		{
+			name:            "Deployment set already gone",
+			expectedDeleted: true,
+			succeeds:        true,
+		},
+		{
+			name:           "Deployment set still has instances",
+			deploymentSets: []ecs.DeploymentSet{{DeploymentSetId: stubDeploymentSetID, DeploymentSetName: stubDeploymentSetName, InstanceAmount: 1}},
+			succeeds:       true,
+		},
+		{
+			name:            "Deployment set is deleted",
+			deploymentSets:  []ecs.DeploymentSet{{DeploymentSetId: stubDeploymentSetID, DeploymentSetName: stubDeploymentSetName}},
+			expectDelete:    true,
+			expectedDeleted: true,
+			succeeds:        true,
+		},
+		{
+			name:           "Delete fails",
+			deploymentSets: []ecs.DeploymentSet{{DeploymentSetId: stubDeploymentSetID, DeploymentSetName: stubDeploymentSetName}},
+			expectDelete:   true,
+			deleteErr:      fmt.Errorf("error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAlibabaCloudClient := mock.NewMockClient(gomock.NewController(t))
+			machineSet, providerConfig := stubDeploymentSetMachineSet()
+
+			taggedIDs := make([]string, 0)
+			for _, deploymentSet := range tc.deploymentSets {
+				taggedIDs = append(taggedIDs, deploymentSet.DeploymentSetId)
+			}
+			mockAlibabaCloudClient.EXPECT().ListTagResources(gomock.Any()).Return(stubListTagResourcesResponse(taggedIDs...), nil).Times(1)
+			mockAlibabaCloudClient.EXPECT().DescribeDeploymentSets(gomock.Any()).Return(&ecs.DescribeDeploymentSetsResponse{
+				DeploymentSets: ecs.DeploymentSets{DeploymentSet: tc.deploymentSets},
+			}, nil).Times(1)
+			if tc.expectDelete {
+				mockAlibabaCloudClient.EXPECT().DeleteDeploymentSet(gomock.Any()).DoAndReturn(
+					func(request *ecs.DeleteDeploymentSetRequest) (*ecs.DeleteDeploymentSetResponse, error) {
+						assert.Equal(t, stubDeploymentSetID, request.DeploymentSetId)
+						return &ecs.DeleteDeploymentSetResponse{}, tc.deleteErr
+					}).Times(1)
+			}
+
+			deleted, err := deleteDeploymentSet(mockAlibabaCloudClient, machineSet, providerConfig)
+			assert.Equal(t, tc.succeeds, err == nil)
+			assert.Equal(t, tc.expectedDeleted, deleted)
+		})
+	}
+}
@@ -17,10 +17,10 @@ import (
 	"fmt"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
-	machinev1 "github.com/openshift/api/machine/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 
-	alibabacloudClient "github.com/openshift/cluster-api-provider-alibaba/pkg/client"
+	machineactuator "github.com/openshift/cluster-api-provider-alibaba/pkg/actuators/machine"
+	alibabacloudproviderv1 "github.com/openshift/cluster-api-provider-alibaba/pkg/apis/alibabacloudprovider/v1"
 
 	"k8s.io/klog"
 )
@@ -33,15 +33,9 @@ type instanceType struct {
 }
 
 // Check whether instanceType is correct, and return the corresponding CPU, MEM, and GPU data
-func (r *Reconciler) getInstanceType(machineSet *machinev1beta1.MachineSet, providerSpec *machinev1.AlibabaCloudMachineProviderConfig) (*instanceType, error) {
-	credentialsSecretName := ""
-	if providerSpec.CredentialsSecret != nil {
-		credentialsSecretName = providerSpec.CredentialsSecret.Name
-	}
-
-	aliClient, err := alibabacloudClient.NewClient(r.Client, credentialsSecretName, machineSet.Namespace, providerSpec.RegionID, nil)
+func (r *Reconciler) getInstanceType(machineSet *machinev1beta1.MachineSet, providerSpec *alibabacloudproviderv1.AlibabaCloudMachineProviderConfig) (*instanceType, error) {
+	aliClient, err := r.newAlibabaCloudClient(machineSet, providerSpec)
 	if err != nil {
-		klog.Errorf("Failed to create alibabacloud client: %v", err)
 		return nil, err
 	}
 
@@ -66,7 +60,7 @@ func (r *Reconciler) getInstanceType(machineSet *machinev1beta1.MachineSet, prov
 
 	return &instanceType{
 		InstanceType: it.InstanceType,
-		VCPU:         int64(it.CpuCoreCount),
+		VCPU:         machineactuator.EffectiveVCPUCount(&it, providerSpec.CPUOptions),
 		MemoryMb:     int64(it.MemorySize * 1024),
 		GPU:          int64(it.GPUAmount),
 	}, nil
@@ -0,0 +1,849 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SpotStrategy enum attribute to describe the bidding policy of a spot instance
+type SpotStrategy string
+
+// SpotInterruptionBehavior enum attribute to describe what happens to a spot instance when it is reclaimed
+type SpotInterruptionBehavior string
+
+// InstanceChargeType enum attribute to describe the billing method of an instance
+type InstanceChargeType string
+
+// PeriodUnit enum attribute to describe the unit of a subscription period
+type PeriodUnit string
+
+// DeploymentSetReferenceType enum attribute to describe how a deployment set is referenced
+type DeploymentSetReferenceType string
+
+// DeploymentSetStrategy enum attribute to describe the deployment strategy of a deployment set
+type DeploymentSetStrategy string
+
+// IPFamily enum attribute to describe an IP address family
+type IPFamily string
+
+// PrivatePoolMatchCriteria enum attribute to describe which private pools an instance can use
+type PrivatePoolMatchCriteria string
+
+// MetadataHTTPTokens enum attribute to describe whether the metadata service of an instance requires tokens
+type MetadataHTTPTokens string
+
+// DeletionProtectionMode enum attribute to describe whether an instance can be released from the console or the API
+type DeletionProtectionMode string
+
+// CreditSpecification enum attribute to describe the performance mode of a burstable instance
+type CreditSpecification string
+
+// InstanceOperation enum attribute to describe the operation in progress on the instance of a Machine
+type InstanceOperation string
+
+// DeleteMode enum attribute to describe how the instance is stopped and released when the Machine is deleted
+type DeleteMode string
+
+// StoppedMode enum attribute to describe whether a stopped pay-as-you-go instance keeps being billed
+type StoppedMode string
+
+// ProvisioningTimeoutAction enum attribute to describe the action taken on an instance which does not finish provisioning in time
+type ProvisioningTimeoutAction string
+
+const (
+	// NoSpotStrategy enum property to create a regular pay-as-you-go instance
+	NoSpotStrategy SpotStrategy = "NoSpot"
+	// SpotWithPriceLimitStrategy enum property to create a spot instance with a user-defined maximum hourly price
+	SpotWithPriceLimitStrategy SpotStrategy = "SpotWithPriceLimit"
+	// SpotAsPriceGoStrategy enum property to create a spot instance which pays the market price up to the pay-as-you-go price
+	SpotAsPriceGoStrategy SpotStrategy = "SpotAsPriceGo"
+
+	// SpotInterruptionBehaviorTerminate enum property to release the spot instance when it is interrupted
+	SpotInterruptionBehaviorTerminate SpotInterruptionBehavior = "Terminate"
+	// SpotInterruptionBehaviorStop enum property to stop the spot instance in economical mode when it is interrupted
+	SpotInterruptionBehaviorStop SpotInterruptionBehavior = "Stop"
+
+	// PrePaidInstanceChargeType enum property to create a subscription instance
+	PrePaidInstanceChargeType InstanceChargeType = "PrePaid"
+	// PostPaidInstanceChargeType enum property to create a pay-as-you-go instance
+	PostPaidInstanceChargeType InstanceChargeType = "PostPaid"
+
+	// WeekPeriodUnit enum property to express the subscription period in weeks
+	WeekPeriodUnit PeriodUnit = "Week"
+	// MonthPeriodUnit enum property to express the subscription period in months
+	MonthPeriodUnit PeriodUnit = "Month"
+
+	// DeploymentSetReferenceTypeID enum property to reference a deployment set by its ID
+	DeploymentSetReferenceTypeID DeploymentSetReferenceType = "ID"
+	// DeploymentSetReferenceTypeTags enum property to reference a deployment set by its tags
+	DeploymentSetReferenceTypeTags DeploymentSetReferenceType = "Tags"
+	// DeploymentSetReferenceTypeMachineSet enum property to use a deployment set created and owned by the MachineSet controller
+	DeploymentSetReferenceTypeMachineSet DeploymentSetReferenceType = "MachineSet"
+
+	// AvailabilityDeploymentSetStrategy enum property to spread all instances across different physical servers
+	AvailabilityDeploymentSetStrategy DeploymentSetStrategy = "Availability"
+	// AvailabilityGroupDeploymentSetStrategy enum property to spread the instances of each deployment set group across different physical servers
+	AvailabilityGroupDeploymentSetStrategy DeploymentSetStrategy = "AvailabilityGroup"
+
+	// IPv4IPFamily enum property for the IPv4 address family
+	IPv4IPFamily IPFamily = "IPv4"
+	// IPv6IPFamily enum property for the IPv6 address family
+	IPv6IPFamily IPFamily = "IPv6"
+
+	// OpenPrivatePoolMatchCriteria enum property to use any open private pool matching the instance, or the public pool when there is none
+	OpenPrivatePoolMatchCriteria PrivatePoolMatchCriteria = "Open"
+	// TargetPrivatePoolMatchCriteria enum property to use the private pool referenced by its ID
+	TargetPrivatePoolMatchCriteria PrivatePoolMatchCriteria = "Target"
+	// NonePrivatePoolMatchCriteria enum property to use the public pool only
+	NonePrivatePoolMatchCriteria PrivatePoolMatchCriteria = "None"
+
+	// RequiredMetadataHTTPTokens enum property to only allow metadata requests with a token (hardened mode)
+	RequiredMetadataHTTPTokens MetadataHTTPTokens = "Required"
+	// OptionalMetadataHTTPTokens enum property to allow metadata requests with or without a token
+	OptionalMetadataHTTPTokens MetadataHTTPTokens = "Optional"
+
+	// DeletionProtectionEnabled enum property to protect the instance from being released
+	DeletionProtectionEnabled DeletionProtectionMode = "Enabled"
+	// DeletionProtectionDisabled enum property to allow the instance to be released
+	DeletionProtectionDisabled DeletionProtectionMode = "Disabled"
+
+	// StandardCreditSpecification enum property to run a burstable instance in standard mode, limited by its CPU credits
+	StandardCreditSpecification CreditSpecification = "Standard"
+	// UnlimitedCreditSpecification enum property to run a burstable instance in unlimited mode, which can exceed its CPU credits
+	UnlimitedCreditSpecification CreditSpecification = "Unlimited"
+
+	// LaunchInstanceOperation enum property for an instance which was launched and is not Running yet
+	LaunchInstanceOperation InstanceOperation = "Launch"
+	// StopInstanceOperation enum property for an instance which is being stopped before it is released
+	StopInstanceOperation InstanceOperation = "Stop"
+	// ForceStopInstanceOperation enum property for an instance which is being force stopped before it is released
+	ForceStopInstanceOperation InstanceOperation = "ForceStop"
+	// ReleaseInstanceOperation enum property for an instance which is being released
+	ReleaseInstanceOperation InstanceOperation = "Release"
+	// CleanupInstanceOperation enum property for an instance which was released, while the resources owned by its Machine are released
+	CleanupInstanceOperation InstanceOperation = "Cleanup"
+	// RestartInstanceOperation enum property for an instance which is stopped and started again as it did not finish provisioning in time
+	RestartInstanceOperation InstanceOperation = "Restart"
+	// RecreateInstanceOperation enum property for an instance which was released as it did not finish provisioning in time, until another one is launched
+	RecreateInstanceOperation InstanceOperation = "Recreate"
+
+	// GracefulDeleteMode enum property to stop the instance gracefully before it is released
+	GracefulDeleteMode DeleteMode = "Graceful"
+	// ForceStopDeleteMode enum property to force stop the instance before it is released
+	ForceStopDeleteMode DeleteMode = "ForceStop"
+	// ForceDeleteMode enum property to release the instance right away, without stopping it first
+	ForceDeleteMode DeleteMode = "Force"
+
+	// KeepChargingStoppedMode enum property to keep billing the stopped instance and retain its resources
+	KeepChargingStoppedMode StoppedMode = "KeepCharging"
+	// StopChargingStoppedMode enum property to stop billing the computing resources of the stopped instance
+	StopChargingStoppedMode StoppedMode = "StopCharging"
+
+	// RestartProvisioningTimeoutAction enum property to force stop the instance and start it again
+	RestartProvisioningTimeoutAction ProvisioningTimeoutAction = "Restart"
+	// RecreateProvisioningTimeoutAction enum property to release the instance and launch another one
+	RecreateProvisioningTimeoutAction ProvisioningTimeoutAction = "Recreate"
+	// FailProvisioningTimeoutAction enum property to mark the Machine failed
+	FailProvisioningTimeoutAction ProvisioningTimeoutAction = "Fail"
+)
+
+const (
+	// LaunchTemplateDrift is true when the instance was not created from the launch template
+	// version currently referenced by the Machine, or by the MachineSet owning the Machine.
+	LaunchTemplateDrift machinev1beta1.ConditionType = "LaunchTemplateDrift"
+
+	// LaunchTemplateVersionChangedConditionReason is the reason for a LaunchTemplateDrift condition
+	// when the referenced launch template or launch template version changed.
+	LaunchTemplateVersionChangedConditionReason = "LaunchTemplateVersionChanged"
+	// LaunchTemplateUpToDateConditionReason is the reason for a LaunchTemplateDrift condition
+	// when the instance was created from the referenced launch template version.
+	LaunchTemplateUpToDateConditionReason = "LaunchTemplateUpToDate"
+
+	// MetadataOptionsApplied is true when the metadata service of the instance is configured
+	// as required by the MetadataServiceOptions of the provider spec.
+	MetadataOptionsApplied machinev1beta1.ConditionType = "MetadataOptionsApplied"
+
+	// MetadataOptionsAppliedConditionReason is the reason for a MetadataOptionsApplied condition
+	// when the metadata options of the instance match the provider spec.
+	MetadataOptionsAppliedConditionReason = "MetadataOptionsApplied"
+	// MetadataOptionsFailedConditionReason is the reason for a MetadataOptionsApplied condition
+	// when the metadata options could not be applied to the instance.
+	MetadataOptionsFailedConditionReason = "MetadataOptionsFailed"
+
+	// InstanceTerminated is true when the instance of the Machine can not run anymore.
+	// The Machine is reported without instance so that the machine controller marks it failed.
+	InstanceTerminated machinev1beta1.ConditionType = "InstanceTerminated"
+
+	// InstanceReleasedConditionReason is the reason for an InstanceTerminated condition
+	// when the instance was released outside of the machine API.
+	InstanceReleasedConditionReason = "InstanceReleased"
+	// InstanceExpiredConditionReason is the reason for an InstanceTerminated condition
+	// when the subscription of the instance expired.
+	InstanceExpiredConditionReason = "InstanceExpired"
+	// InstanceOverduePaymentConditionReason is the reason for an InstanceTerminated condition
+	// when the instance was stopped for overdue payment.
+	InstanceOverduePaymentConditionReason = "InstanceOverduePayment"
+
+	// ProvisioningTimedOut is true when an instance of the Machine did not finish provisioning
+	// within the timeout of the ProvisioningPolicy of the provider spec.
+	ProvisioningTimedOut machinev1beta1.ConditionType = "ProvisioningTimedOut"
+
+	// InstanceRestartedConditionReason is the reason for a ProvisioningTimedOut condition
+	// when the instance was force stopped to start it again.
+	InstanceRestartedConditionReason = "InstanceRestarted"
+	// InstanceRecreatedConditionReason is the reason for a ProvisioningTimedOut condition
+	// when the instance was released to launch another one.
+	InstanceRecreatedConditionReason = "InstanceRecreated"
+	// ProvisioningFailedConditionReason is the reason for a ProvisioningTimedOut condition
+	// when the Machine was marked failed.
+	ProvisioningFailedConditionReason = "ProvisioningFailed"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AlibabaCloudMachineProviderConfig is the providerSpec consumed by this actuator.
+// It embeds the AlibabaCloudMachineProviderConfig from openshift/api and adds the
+// settings which are only understood by this provider.
+// The embedded type is inlined, so that a providerSpec written for openshift/api
+// is decoded and encoded again unchanged.
+type AlibabaCloudMachineProviderConfig struct {
+	machinev1.AlibabaCloudMachineProviderConfig `json:",inline"`
+
+	// SpotMarketOptions allows users to configure instances to be run using spot instances.
+	// When omitted the instance is created as a regular pay-as-you-go instance.
+	// +optional
+	SpotMarketOptions *SpotMarketOptions `json:"spotMarketOptions,omitempty"`
+
+	// InstanceChargeType is the billing method of the instance.
+	// Valid values:
+	//
+	// PrePaid: subscription. SubscriptionOptions must be set.
+	// PostPaid: pay-as-you-go.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `PostPaid`.
+	// Subscription instances are converted to pay-as-you-go before they are released when the Machine is deleted.
+	// +kubebuilder:validation:Enum="PrePaid";"PostPaid"
+	// +optional
+	InstanceChargeType InstanceChargeType `json:"instanceChargeType,omitempty"`
+
+	// SubscriptionOptions configures the subscription period and auto-renewal of the instance.
+	// This parameter takes effect only when InstanceChargeType is PrePaid.
+	// +optional
+	SubscriptionOptions *SubscriptionOptions `json:"subscriptionOptions,omitempty"`
+
+	// DeploymentSet is the deployment set the instance is placed in, which spreads
+	// the instances across different physical servers.
+	// When omitted the instance is not placed in a deployment set.
+	// +optional
+	DeploymentSet *DeploymentSetReference `json:"deploymentSet,omitempty"`
+
+	// DedicatedHost is the dedicated host or dedicated host cluster the instance is placed on.
+	// This parameter takes effect only when Tenancy is host.
+	// When omitted the platform chooses a dedicated host for the instance.
+	// +optional
+	DedicatedHost *DedicatedHostPlacement `json:"dedicatedHost,omitempty"`
+
+	// LaunchTemplate is the ECS launch template the instance is created from.
+	// Fields set in the provider spec override the values of the launch template,
+	// so that the image, security groups, vswitch and system disk may be omitted
+	// from the provider spec when they are defined in the launch template.
+	// +optional
+	LaunchTemplate *LaunchTemplateReference `json:"launchTemplate,omitempty"`
+
+	// Image selects the image of the instance when ImageID is not set.
+	// The resolved image ID is recorded in the provider status and used for
+	// the lifetime of the Machine, so that new images do not affect existing Machines.
+	// +optional
+	Image *ImageSelector `json:"image,omitempty"`
+
+	// KeyPairName is the name of the ECS key pair attached to the instance for SSH access.
+	// The key pair must exist in the region of the instance.
+	// When omitted no key pair is attached to the instance.
+	// +optional
+	KeyPairName string `json:"keyPairName,omitempty"`
+
+	// PrivateIPAddress is the static private IPv4 address of the instance.
+	// The address must belong to the CIDR block of the vswitch and must not be in use.
+	// When omitted the platform assigns a private IP address from the vswitch.
+	// +optional
+	PrivateIPAddress string `json:"privateIpAddress,omitempty"`
+
+	// PrivateIPAddressPool is a pool of static private IPv4 addresses handed out to the
+	// Machines of the MachineSet owning the Machine. The address held by each Machine is
+	// tracked on the MachineSet, and is handed out again once the Machine is deleted.
+	// This parameter cannot be combined with PrivateIPAddress.
+	// +optional
+	PrivateIPAddressPool *PrivateIPAddressPool `json:"privateIpAddressPool,omitempty"`
+
+	// IPv6 configures the IPv6 addresses of the instance for dual-stack clusters.
+	// The vswitch of the instance must have an IPv6 CIDR block.
+	// When omitted no IPv6 address is requested for the instance.
+	// +optional
+	IPv6 *IPv6Options `json:"ipv6,omitempty"`
+
+	// NetworkInterfaces are the secondary elastic network interfaces of the instance.
+	// They are created and attached right after the instance is running, and are
+	// deleted when the Machine is deleted or when they are removed from the list.
+	// +optional
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
+
+	// PrivatePoolOptions configures the private pool, generated by an elasticity assurance or a
+	// capacity reservation, the instance consumes capacity from.
+	// When omitted the platform chooses a default, which is subject to change over time.
+	// Currently the default is to use any open private pool matching the instance.
+	// +optional
+	PrivatePoolOptions *PrivatePoolOptions `json:"privatePoolOptions,omitempty"`
+
+	// MetadataServiceOptions configures the metadata service of the instance.
+	// The options are applied when the instance is created, and corrected when they drift.
+	// When omitted the metadata service of the instance is left as configured by the platform.
+	// +optional
+	MetadataServiceOptions *MetadataServiceOptions `json:"metadataServiceOptions,omitempty"`
+
+	// DeletionProtection protects the instance from being released from the console or the API.
+	// Protection is only turned off when the Machine itself is deleted.
+	// Valid values:
+	//
+	// Enabled: the instance cannot be released until protection is turned off.
+	// Disabled: the instance can be released.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Disabled`.
+	// Deletion protection cannot be enabled for spot instances.
+	// +kubebuilder:validation:Enum="Enabled";"Disabled"
+	// +optional
+	DeletionProtection DeletionProtectionMode `json:"deletionProtection,omitempty"`
+
+	// CPUOptions configures the CPU cores and threads of the instance.
+	// The options are validated against the instance type.
+	// When omitted the instance uses all cores and threads of the instance type.
+	// +optional
+	CPUOptions *CPUOptions `json:"cpuOptions,omitempty"`
+
+	// CreditSpecification is the performance mode of a burstable instance, such as t5 and t6 instance types.
+	// Valid values:
+	//
+	// Standard: the CPU performance of the instance is limited by its CPU credits.
+	// Unlimited: the instance can exceed its CPU credits, which is billed separately.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// This parameter can only be set for burstable instance types.
+	// +kubebuilder:validation:Enum="Standard";"Unlimited"
+	// +optional
+	CreditSpecification CreditSpecification `json:"creditSpecification,omitempty"`
+
+	// FallbackInstanceTypes are the instance types tried in turn when InstanceType is out of stock in the zone.
+	// The instance type the instance was created with is reported in the provider status.
+	// This parameter requires InstanceType to be set.
+	// +optional
+	FallbackInstanceTypes []string `json:"fallbackInstanceTypes,omitempty"`
+
+	// DeletePolicy configures how the instance is stopped and released when the Machine is deleted,
+	// and which resources owned by the Machine are released along with it.
+	// When omitted the instance is stopped gracefully, force stopped if it does not stop in time, and then released.
+	// +optional
+	DeletePolicy *DeletePolicy `json:"deletePolicy,omitempty"`
+
+	// ProvisioningPolicy configures the action taken on an instance which is still Pending or Starting
+	// after the provisioning timeout.
+	// When omitted the Machine is requeued until the instance finishes provisioning.
+	// +optional
+	ProvisioningPolicy *ProvisioningPolicy `json:"provisioningPolicy,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AlibabaCloudMachineProviderStatus is the providerStatus reported by this actuator.
+// It embeds the AlibabaCloudMachineProviderStatus from openshift/api and adds the
+// fields which are only reported by this provider.
+// The embedded type is inlined, so that a providerStatus written for openshift/api
+// is decoded and encoded again unchanged.
+type AlibabaCloudMachineProviderStatus struct {
+	machinev1.AlibabaCloudMachineProviderStatus `json:",inline"`
+
+	// SpotStrategy is the spot strategy which was used to create the instance
+	// +optional
+	SpotStrategy *SpotStrategy `json:"spotStrategy,omitempty"`
+
+	// DedicatedHostID is the ID of the dedicated host the instance is placed on
+	// +optional
+	DedicatedHostID *string `json:"dedicatedHostId,omitempty"`
+
+	// DedicatedHostClusterID is the ID of the dedicated host cluster the instance is placed in
+	// +optional
+	DedicatedHostClusterID *string `json:"dedicatedHostClusterId,omitempty"`
+
+	// LaunchTemplateID is the ID of the launch template the instance was created from
+	// +optional
+	LaunchTemplateID *string `json:"launchTemplateId,omitempty"`
+
+	// LaunchTemplateVersion is the version of the launch template the instance was created from
+	// +optional
+	LaunchTemplateVersion *int64 `json:"launchTemplateVersion,omitempty"`
+
+	// ImageID is the ID of the image the instance was created from
+	// +optional
+	ImageID *string `json:"imageId,omitempty"`
+
+	// PrivateIPAddress is the static private IP address assigned to the instance
+	// +optional
+	PrivateIPAddress *string `json:"privateIpAddress,omitempty"`
+
+	// NetworkInterfaces are the secondary elastic network interfaces of the instance
+	// +optional
+	NetworkInterfaces []NetworkInterfaceStatus `json:"networkInterfaces,omitempty"`
+
+	// PrivatePoolID is the ID of the private pool the instance consumes capacity from.
+	// It is empty when the instance was created from the public pool.
+	// +optional
+	PrivatePoolID *string `json:"privatePoolId,omitempty"`
+
+	// VSwitchID is the ID of the vswitch the instance was created in
+	// +optional
+	VSwitchID *string `json:"vSwitchId,omitempty"`
+
+	// ZoneID is the ID of the zone the instance was created in
+	// +optional
+	ZoneID *string `json:"zoneId,omitempty"`
+
+	// InstanceType is the instance type the instance was created with.
+	// It differs from the one of the provider spec when a fallback instance type was used.
+	// +optional
+	InstanceType *string `json:"instanceType,omitempty"`
+
+	// Operation is the operation in progress on the instance.
+	// The Machine is requeued until the instance reaches the target state of the operation.
+	// +optional
+	Operation *InstanceOperation `json:"operation,omitempty"`
+
+	// OperationStartTime is the time the operation in progress was started
+	// +optional
+	OperationStartTime *metav1.Time `json:"operationStartTime,omitempty"`
+
+	// ReleasedResources are the IDs of the resources owned by the Machine which were already released
+	// while the instance operation Cleanup is in progress
+	// +optional
+	ReleasedResources []string `json:"releasedResources,omitempty"`
+
+	// LaunchAttempts is the number of RunInstances requests with a known outcome sent for the Machine.
+	// Every request is sent with its own client token derived from it, as ECS replays the outcome of a client token.
+	// +optional
+	LaunchAttempts int32 `json:"launchAttempts,omitempty"`
+
+	// ProvisioningRecoveries is the number of times the action of the ProvisioningPolicy was taken
+	// on an instance of the Machine which did not finish provisioning in time
+	// +optional
+	ProvisioningRecoveries int32 `json:"provisioningRecoveries,omitempty"`
+}
+
+// SpotMarketOptions defines the options available to a user when configuring
+// Machines to run on spot instances.
+// https://www.alibabacloud.com/help/en/doc-detail/52088.htm
+type SpotMarketOptions struct {
+	// Strategy is the bidding policy for the spot instance.
+	// Valid values:
+	//
+	// SpotWithPriceLimit: a spot instance for which the maximum hourly price is set by MaxPrice.
+	// SpotAsPriceGo: a spot instance for which the market price at the time of purchase is used as the bid price.
+	// NoSpot: a regular pay-as-you-go instance.
+	// Empty value means SpotWithPriceLimit when MaxPrice is set, and SpotAsPriceGo otherwise.
+	// +kubebuilder:validation:Enum="SpotWithPriceLimit";"SpotAsPriceGo";"NoSpot"
+	// +optional
+	Strategy SpotStrategy `json:"strategy,omitempty"`
+
+	// MaxPrice is the maximum hourly price for the spot instance. Up to three decimal places are allowed.
+	// This parameter takes effect only when Strategy is SpotWithPriceLimit.
+	// +optional
+	MaxPrice *string `json:"maxPrice,omitempty"`
+
+	// Duration is the protection period of the spot instance in hours. Valid values: 0 to 6.
+	// A value of 0 means no protection period is set.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `1`.
+	// +optional
+	Duration *int64 `json:"duration,omitempty"`
+
+	// InterruptionBehavior specifies what happens to the spot instance when it is interrupted.
+	// Valid values:
+	//
+	// Terminate: the instance is released.
+	// Stop: the instance is stopped in economical mode.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Terminate`.
+	// +kubebuilder:validation:Enum="Terminate";"Stop"
+	// +optional
+	InterruptionBehavior SpotInterruptionBehavior `json:"interruptionBehavior,omitempty"`
+}
+
+// SubscriptionOptions defines the subscription period and auto-renewal of a PrePaid instance.
+// https://www.alibabacloud.com/help/en/doc-detail/56220.htm
+type SubscriptionOptions struct {
+	// Period is the subscription period of the instance, expressed in PeriodUnit.
+	// Valid values when PeriodUnit is Week: 1 to 4.
+	// Valid values when PeriodUnit is Month: 1 to 9, 12, 24, 36, 48 and 60.
+	Period int64 `json:"period"`
+
+	// PeriodUnit is the unit of Period.
+	// Valid values: Week, Month.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Month`.
+	// +kubebuilder:validation:Enum="Week";"Month"
+	// +optional
+	PeriodUnit PeriodUnit `json:"periodUnit,omitempty"`
+
+	// AutoRenew specifies whether to enable auto-renewal for the instance.
+	// +optional
+	AutoRenew bool `json:"autoRenew,omitempty"`
+
+	// AutoRenewPeriod is the auto-renewal period of the instance, expressed in PeriodUnit.
+	// This parameter is required when AutoRenew is set to true.
+	// Valid values when PeriodUnit is Week: 1, 2 and 3.
+	// Valid values when PeriodUnit is Month: 1, 2, 3, 6, 12, 24, 36, 48 and 60.
+	// +optional
+	AutoRenewPeriod int64 `json:"autoRenewPeriod,omitempty"`
+}
+
+// DeploymentSetReference is a reference to a deployment set.
+// https://www.alibabacloud.com/help/en/doc-detail/91258.htm
+type DeploymentSetReference struct {
+	// Type identifies how the deployment set is referenced.
+	// Valid values:
+	//
+	// ID: the deployment set with the given ID.
+	// Tags: the deployment set matching all of the given tags.
+	// MachineSet: a deployment set created for the MachineSet owning the Machine.
+	// The deployment set is tagged with the cluster ID and deleted together with the MachineSet.
+	// +kubebuilder:validation:Enum="ID";"Tags";"MachineSet"
+	Type DeploymentSetReferenceType `json:"type"`
+
+	// ID of the deployment set.
+	// +optional
+	ID *string `json:"id,omitempty"`
+
+	// Tags is a set of metadata based upon which the deployment set can be identified.
+	// +optional
+	Tags *[]machinev1.Tag `json:"tags,omitempty"`
+
+	// GroupNo is the number of the deployment set group the instance is placed in.
+	// This parameter takes effect only for deployment sets with the AvailabilityGroup strategy.
+	// Valid values: 1 to 7.
+	// +optional
+	GroupNo *int64 `json:"groupNo,omitempty"`
+
+	// Strategy is the deployment strategy of the deployment set created for the MachineSet.
+	// This parameter takes effect only when Type is MachineSet.
+	// Valid values: Availability, AvailabilityGroup.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Availability`.
+	// +kubebuilder:validation:Enum="Availability";"AvailabilityGroup"
+	// +optional
+	Strategy DeploymentSetStrategy `json:"strategy,omitempty"`
+}
+
+// DedicatedHostPlacement places the instance on a dedicated host or in a dedicated host cluster.
+// Exactly one of Host and HostCluster must be set.
+// https://www.alibabacloud.com/help/en/doc-detail/118938.htm
+type DedicatedHostPlacement struct {
+	// Host is a reference to the dedicated host the instance is created on.
+	// When the reference matches more than one dedicated host, the first one
+	// with enough capacity for the instance type is used.
+	// Valid reference types: ID, Tags.
+	// +optional
+	Host *machinev1.AlibabaResourceReference `json:"host,omitempty"`
+
+	// HostCluster is a reference to the dedicated host cluster the instance is created in.
+	// The platform chooses a dedicated host with enough capacity within the cluster.
+	// Valid reference types: ID, Tags.
+	// +optional
+	HostCluster *machinev1.AlibabaResourceReference `json:"hostCluster,omitempty"`
+}
+
+// LaunchTemplateReference is a reference to a version of an ECS launch template.
+// Exactly one of ID and Name must be set.
+// https://www.alibabacloud.com/help/en/doc-detail/73916.htm
+type LaunchTemplateReference struct {
+	// ID of the launch template.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Name of the launch template.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Version of the launch template.
+	// Empty value means the default version of the launch template is used.
+	// A change of the default version is reported as drift on existing Machines.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Version *int64 `json:"version,omitempty"`
+}
+
+// ImageSelector selects an image by family, name or tags.
+// Family, Name and Tags are combined, and the newest Available image matching all of them is used.
+// https://www.alibabacloud.com/help/en/doc-detail/25534.htm
+type ImageSelector struct {
+	// Family is the image family the image belongs to.
+	// +optional
+	Family string `json:"family,omitempty"`
+
+	// Name is the name of the image.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Tags is a set of metadata based upon which the image can be identified.
+	// +optional
+	Tags *[]machinev1.Tag `json:"tags,omitempty"`
+
+	// OwnerAlias restricts the images to the given source.
+	// Valid values: system, self, others, marketplace.
+	// Empty value means images from all sources are considered.
+	// +kubebuilder:validation:Enum="system";"self";"others";"marketplace"
+	// +optional
+	OwnerAlias string `json:"ownerAlias,omitempty"`
+
+	// RegionImageIDs maps a region ID to the ID of the image used in that region.
+	// It takes precedence over Family, Name and Tags in the regions it lists,
+	// so that a single MachineSet template can be used across regions.
+	// +optional
+	RegionImageIDs map[string]string `json:"regionImageIds,omitempty"`
+}
+
+// PrivateIPAddressPool is a pool of private IPv4 addresses given as a list, a CIDR block, or both.
+// Addresses are handed out in order, the listed addresses first.
+type PrivateIPAddressPool struct {
+	// Addresses is a list of private IPv4 addresses.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+
+	// CIDR is a CIDR block of private IPv4 addresses, for example 10.0.1.16/28.
+	// The network and broadcast addresses of the block are not handed out.
+	// The block may contain at most 1024 addresses.
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// IPv6Options configures the IPv6 addresses of an instance.
+// https://www.alibabacloud.com/help/en/doc-detail/98922.htm
+type IPv6Options struct {
+	// AddressCount is the number of IPv6 addresses assigned to the primary network interface of the instance.
+	// The maximum depends on the instance type.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `1`.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	AddressCount int64 `json:"addressCount,omitempty"`
+
+	// PrimaryIPFamily is the address family of the first internal address reported in the Machine status,
+	// which is used as the primary node address.
+	// Valid values: IPv4, IPv6.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `IPv4`.
+	// +kubebuilder:validation:Enum="IPv4";"IPv6"
+	// +optional
+	PrimaryIPFamily IPFamily `json:"primaryIPFamily,omitempty"`
+}
+
+// NetworkInterface is a secondary elastic network interface of an instance.
+// https://www.alibabacloud.com/help/en/doc-detail/58496.htm
+type NetworkInterface struct {
+	// VSwitch is a reference to the vswitch the network interface is created in.
+	// The vswitch must be in the zone of the instance.
+	VSwitch machinev1.AlibabaResourceReference `json:"vSwitch"`
+
+	// SecurityGroups is a list of security group references the network interface is added to.
+	// When omitted the network interface is added to the security groups of the instance.
+	// +optional
+	SecurityGroups []machinev1.AlibabaResourceReference `json:"securityGroups,omitempty"`
+
+	// QueueNumber is the number of queues of the network interface.
+	// The maximum depends on the instance type.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	QueueNumber int32 `json:"queueNumber,omitempty"`
+
+	// SecondaryPrivateIPAddressCount is the number of secondary private IP addresses
+	// assigned to the network interface in addition to its primary private IP address.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SecondaryPrivateIPAddressCount int32 `json:"secondaryPrivateIpAddressCount,omitempty"`
+}
+
+// NetworkInterfaceStatus reports a secondary elastic network interface of an instance.
+type NetworkInterfaceStatus struct {
+	// ID is the ID of the network interface
+	ID string `json:"id"`
+
+	// VSwitchID is the ID of the vswitch of the network interface
+	// +optional
+	VSwitchID string `json:"vSwitchId,omitempty"`
+
+	// PrivateIPAddresses are the private IP addresses of the network interface, the primary address first
+	// +optional
+	PrivateIPAddresses []string `json:"privateIpAddresses,omitempty"`
+
+	// Status is the status of the network interface, for example InUse
+	// +optional
+	Status string `json:"status,omitempty"`
+}
+
+// PrivatePoolOptions configures the private pool an instance consumes capacity from.
+// https://www.alibabacloud.com/help/en/doc-detail/193630.htm
+type PrivatePoolOptions struct {
+	// MatchCriteria is the type of private pool the instance can use.
+	// Valid values:
+	//
+	// Open: any open private pool matching the instance. The instance is created from the public pool when there is none.
+	// Target: the private pool referenced by ID. The instance is not created when the pool has no capacity left,
+	// unless FallbackMatchCriteria is set.
+	// None: the public pool only.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Open`.
+	// +kubebuilder:validation:Enum="Open";"Target";"None"
+	// +optional
+	MatchCriteria PrivatePoolMatchCriteria `json:"matchCriteria,omitempty"`
+
+	// ID is the ID of the private pool, which is the ID of the elasticity assurance or capacity reservation.
+	// It must be set when MatchCriteria is Target, and only then.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// FallbackMatchCriteria is the match criteria the instance is created with when the targeted private pool
+	// has no capacity left or cannot be used. Set it to Open to fall back to open capacity.
+	// It only takes effect when MatchCriteria is Target.
+	// When omitted the instance is not created when the targeted private pool cannot be used.
+	// +kubebuilder:validation:Enum="Open";"None"
+	// +optional
+	FallbackMatchCriteria PrivatePoolMatchCriteria `json:"fallbackMatchCriteria,omitempty"`
+}
+
+// MetadataServiceOptions configures the metadata service of an instance.
+// https://www.alibabacloud.com/help/en/doc-detail/108460.htm
+type MetadataServiceOptions struct {
+	// HTTPTokens controls whether the metadata service requires a token.
+	// Valid values:
+	//
+	// Required: only requests with a token are served (hardened mode).
+	// Optional: requests with and without a token are served.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Optional`.
+	// +kubebuilder:validation:Enum="Required";"Optional"
+	// +optional
+	HTTPTokens MetadataHTTPTokens `json:"httpTokens,omitempty"`
+
+	// HTTPPutResponseHopLimit is the maximum number of hops the token response can travel.
+	// Valid values are 1 to 64.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `1`.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	// +optional
+	HTTPPutResponseHopLimit int32 `json:"httpPutResponseHopLimit,omitempty"`
+}
+
+// DeletePolicy configures how the instance of a Machine is stopped and released.
+// https://www.alibabacloud.com/help/en/doc-detail/155372.htm
+type DeletePolicy struct {
+	// Mode is how the instance is stopped before it is released.
+	// Valid values:
+	//
+	// Graceful: the instance is stopped, and force stopped if it is not Stopped within StopTimeout.
+	// ForceStop: the instance is force stopped, which may lose data not written to disk.
+	// Force: the instance is released right away with DeleteInstances Force=true, without stopping it first.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Graceful`.
+	// +kubebuilder:validation:Enum="Graceful";"ForceStop";"Force"
+	// +optional
+	Mode DeleteMode `json:"mode,omitempty"`
+
+	// StoppedMode is whether the stopped instance keeps being billed until it is released.
+	// Valid values:
+	//
+	// KeepCharging: the stopped instance is billed and retains its resources.
+	// StopCharging: the computing resources of a stopped pay-as-you-go instance are no longer billed.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `KeepCharging`.
+	// +kubebuilder:validation:Enum="KeepCharging";"StopCharging"
+	// +optional
+	StoppedMode StoppedMode `json:"stoppedMode,omitempty"`
+
+	// StopTimeout is how long a graceful stop may take before the instance is force stopped.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is 5 minutes.
+	// +optional
+	StopTimeout *metav1.Duration `json:"stopTimeout,omitempty"`
+
+	// ForceStopTimeout is how long a force stop may take before the instance is released with DeleteInstances Force=true.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is 5 minutes.
+	// +optional
+	ForceStopTimeout *metav1.Duration `json:"forceStopTimeout,omitempty"`
+
+	// ReleasePreservedDisks releases the data disks with DiskPreservation PreserveDisk once the instance is released.
+	// By default the preserved disks are kept.
+	// +optional
+	ReleasePreservedDisks bool `json:"releasePreservedDisks,omitempty"`
+}
+
+// ProvisioningPolicy configures the action taken on an instance which does not finish provisioning in time.
+// The action is taken once, the Machine is marked failed when the instance does not finish provisioning after it.
+type ProvisioningPolicy struct {
+	// Timeout is how long the instance may stay Pending or Starting after it was launched.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is 15 minutes.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// TimeoutAction is the action taken on the instance once the timeout passed.
+	// Valid values:
+	//
+	// Restart: the instance is force stopped and started again. ECS does not stop Pending or Starting instances,
+	// the Machine is marked failed when the instance can not be stopped after twice the timeout.
+	// Recreate: the instance is released with DeleteInstances Force=true and another one is launched.
+	// Fail: the Machine is marked failed, so that it can be remediated or replaced. The instance is force stopped
+	// when ECS accepts to stop it, and released when the Machine is deleted.
+	// Empty value means the platform chooses a default, which is subject to change over time.
+	// Currently the default is `Restart`.
+	// +kubebuilder:validation:Enum="Restart";"Recreate";"Fail"
+	// +optional
+	TimeoutAction ProvisioningTimeoutAction `json:"timeoutAction,omitempty"`
+}
+
+// CPUOptions configures the CPU cores and threads of an instance.
+// https://www.alibabacloud.com/help/en/doc-detail/145895.htm
+type CPUOptions struct {
+	// Core is the number of physical CPU cores of the instance.
+	// It cannot exceed the number of physical cores of the instance type.
+	// When omitted all physical cores of the instance type are used.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Core int32 `json:"core,omitempty"`
+
+	// ThreadsPerCore is the number of threads per CPU core.
+	// Set it to 1 to disable hyper-threading.
+	// When omitted the number of threads per core of the instance type is used.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=2
+	// +optional
+	ThreadsPerCore int32 `json:"threadsPerCore,omitempty"`
+
+	// Numa is the NUMA configuration of the instance, passed as CpuOptions.Numa.
+	// Only some instance types support it.
+	// +optional
+	Numa string `json:"numa,omitempty"`
+}
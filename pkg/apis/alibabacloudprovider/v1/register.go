@@ -26,7 +26,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	machinev1 "github.com/openshift/api/machine/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/klog/v2"
@@ -43,12 +42,12 @@ var (
 )
 
 // ProviderSpecFromRawExtension unmarshals a raw extension into an AlibabaCloudMachineProviderConfig type
-func ProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (*machinev1.AlibabaCloudMachineProviderConfig, error) {
+func ProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (*AlibabaCloudMachineProviderConfig, error) {
 	if rawExtension == nil {
-		return &machinev1.AlibabaCloudMachineProviderConfig{}, nil
+		return &AlibabaCloudMachineProviderConfig{}, nil
 	}
 
-	spec := new(machinev1.AlibabaCloudMachineProviderConfig)
+	spec := new(AlibabaCloudMachineProviderConfig)
 	if err := yaml.Unmarshal(rawExtension.Raw, &spec); err != nil {
 		return nil, fmt.Errorf("error unmarshalling providerSpec: %v", err)
 	}
@@ -58,12 +57,12 @@ func ProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (*machinev
 }
 
 // ProviderStatusFromRawExtension unmarshals a raw extension into an AlibabaCloudMachineProviderStatus type
-func ProviderStatusFromRawExtension(rawExtension *runtime.RawExtension) (*machinev1.AlibabaCloudMachineProviderStatus, error) {
+func ProviderStatusFromRawExtension(rawExtension *runtime.RawExtension) (*AlibabaCloudMachineProviderStatus, error) {
 	if rawExtension == nil {
-		return &machinev1.AlibabaCloudMachineProviderStatus{}, nil
+		return &AlibabaCloudMachineProviderStatus{}, nil
 	}
 
-	providerStatus := new(machinev1.AlibabaCloudMachineProviderStatus)
+	providerStatus := new(AlibabaCloudMachineProviderStatus)
 	if err := yaml.Unmarshal(rawExtension.Raw, providerStatus); err != nil {
 		return nil, fmt.Errorf("error unmarshalling providerStatus: %v", err)
 	}
@@ -73,7 +72,7 @@ func ProviderStatusFromRawExtension(rawExtension *runtime.RawExtension) (*machin
 }
 
 // RawExtensionFromProviderSpec marshals the machine provider spec.
-func RawExtensionFromProviderSpec(spec *machinev1.AlibabaCloudMachineProviderConfig) (*runtime.RawExtension, error) {
+func RawExtensionFromProviderSpec(spec *AlibabaCloudMachineProviderConfig) (*runtime.RawExtension, error) {
 	if spec == nil {
 		return &runtime.RawExtension{}, nil
 	}
@@ -90,7 +89,7 @@ func RawExtensionFromProviderSpec(spec *machinev1.AlibabaCloudMachineProviderCon
 }
 
 // RawExtensionFromProviderStatus marshals the machine provider status
-func RawExtensionFromProviderStatus(status *machinev1.AlibabaCloudMachineProviderStatus) (*runtime.RawExtension, error) {
+func RawExtensionFromProviderStatus(status *AlibabaCloudMachineProviderStatus) (*runtime.RawExtension, error) {
 	if status == nil {
 		return &runtime.RawExtension{}, nil
 	}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+)
+
+// TestProviderSpecRoundTrip checks that a providerSpec written for openshift/api is decoded and encoded again unchanged
+func TestProviderSpecRoundTrip(t *testing.T) {
+	upstream := machinev1.AlibabaCloudMachineProviderConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "machine.openshift.io/v1",
+			Kind:       "AlibabaCloudMachineProviderConfig",
+		},
+		InstanceType: "ecs.g6.large",
+		ImageID:      "centos_7_9_x64_20G_alibase_20210318.vhd",
+		RegionID:     "cn-hangzhou",
+		ZoneID:       "cn-hangzhou-i",
+		VpcID:        "vpc-bp1td11g1i90b1fjnm7jw",
+		VSwitch: machinev1.AlibabaResourceReference{
+			Type: machinev1.AlibabaResourceReferenceTypeID,
+			ID:   pointer.StringPtr("vsw-bp1ra53n8ban94mbbgb4w"),
+		},
+		SecurityGroups: []machinev1.AlibabaResourceReference{{
+			Type: machinev1.AlibabaResourceReferenceTypeTags,
+			Tags: &[]machinev1.Tag{{Key: "Name", Value: "sg-worker"}},
+		}},
+		SystemDisk: machinev1.SystemDiskProperties{Category: "cloud_essd", Size: 120},
+		DataDisks: []machinev1.DataDiskProperties{{
+			Category:         "cloud_ssd",
+			Size:             100,
+			DiskPreservation: machinev1.DeleteWithInstance,
+		}},
+		Tags:              []machinev1.Tag{{Key: "host-type", Value: "worker"}},
+		UserDataSecret:    &corev1.LocalObjectReference{Name: "worker-user-data"},
+		CredentialsSecret: &corev1.LocalObjectReference{Name: "alibabacloud-credentials"},
+	}
+
+	raw, err := json.Marshal(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := ProviderSpecFromRawExtension(&runtime.RawExtension{Raw: raw})
+	assert.NoError(t, err)
+	assert.Equal(t, upstream, spec.AlibabaCloudMachineProviderConfig)
+
+	rawExtension, err := RawExtensionFromProviderSpec(spec)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(raw), string(rawExtension.Raw))
+}
+
+// TestProviderStatusRoundTrip checks that a providerStatus written for openshift/api is decoded and encoded again unchanged
+func TestProviderStatusRoundTrip(t *testing.T) {
+	upstream := machinev1.AlibabaCloudMachineProviderStatus{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "machine.openshift.io/v1",
+			Kind:       "AlibabaCloudMachineProviderStatus",
+		},
+		InstanceID:    pointer.StringPtr("i-bp1e5ckt6j5f6ru2wcyt"),
+		InstanceState: pointer.StringPtr("Running"),
+		Conditions: []metav1.Condition{{
+			Type:               "MachineCreation",
+			Status:             metav1.ConditionTrue,
+			Reason:             "MachineCreationSucceeded",
+			Message:            "Machine successfully created",
+			LastTransitionTime: metav1.Unix(1609459200, 0),
+		}},
+	}
+
+	raw, err := json.Marshal(upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := ProviderStatusFromRawExtension(&runtime.RawExtension{Raw: raw})
+	assert.NoError(t, err)
+	assert.Equal(t, upstream, status.AlibabaCloudMachineProviderStatus)
+
+	rawExtension, err := RawExtensionFromProviderStatus(status)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(raw), string(rawExtension.Raw))
+}
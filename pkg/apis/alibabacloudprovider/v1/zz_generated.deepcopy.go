@@ -0,0 +1,547 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	machinev1 "github.com/openshift/api/machine/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlibabaCloudMachineProviderConfig) DeepCopyInto(out *AlibabaCloudMachineProviderConfig) {
+	*out = *in
+	in.AlibabaCloudMachineProviderConfig.DeepCopyInto(&out.AlibabaCloudMachineProviderConfig)
+	if in.SpotMarketOptions != nil {
+		in, out := &in.SpotMarketOptions, &out.SpotMarketOptions
+		*out = new(SpotMarketOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SubscriptionOptions != nil {
+		in, out := &in.SubscriptionOptions, &out.SubscriptionOptions
+		*out = new(SubscriptionOptions)
+		**out = **in
+	}
+	if in.DeploymentSet != nil {
+		in, out := &in.DeploymentSet, &out.DeploymentSet
+		*out = new(DeploymentSetReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DedicatedHost != nil {
+		in, out := &in.DedicatedHost, &out.DedicatedHost
+		*out = new(DedicatedHostPlacement)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LaunchTemplate != nil {
+		in, out := &in.LaunchTemplate, &out.LaunchTemplate
+		*out = new(LaunchTemplateReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrivateIPAddressPool != nil {
+		in, out := &in.PrivateIPAddressPool, &out.PrivateIPAddressPool
+		*out = new(PrivateIPAddressPool)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPv6 != nil {
+		in, out := &in.IPv6, &out.IPv6
+		*out = new(IPv6Options)
+		**out = **in
+	}
+	if in.NetworkInterfaces != nil {
+		in, out := &in.NetworkInterfaces, &out.NetworkInterfaces
+		*out = make([]NetworkInterface, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PrivatePoolOptions != nil {
+		in, out := &in.PrivatePoolOptions, &out.PrivatePoolOptions
+		*out = new(PrivatePoolOptions)
+		**out = **in
+	}
+	if in.MetadataServiceOptions != nil {
+		in, out := &in.MetadataServiceOptions, &out.MetadataServiceOptions
+		*out = new(MetadataServiceOptions)
+		**out = **in
+	}
+	if in.CPUOptions != nil {
+		in, out := &in.CPUOptions, &out.CPUOptions
+		*out = new(CPUOptions)
+		**out = **in
+	}
+	if in.FallbackInstanceTypes != nil {
+		in, out := &in.FallbackInstanceTypes, &out.FallbackInstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletePolicy != nil {
+		in, out := &in.DeletePolicy, &out.DeletePolicy
+		*out = new(DeletePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProvisioningPolicy != nil {
+		in, out := &in.ProvisioningPolicy, &out.ProvisioningPolicy
+		*out = new(ProvisioningPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlibabaCloudMachineProviderConfig.
+func (in *AlibabaCloudMachineProviderConfig) DeepCopy() *AlibabaCloudMachineProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AlibabaCloudMachineProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AlibabaCloudMachineProviderConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlibabaCloudMachineProviderStatus) DeepCopyInto(out *AlibabaCloudMachineProviderStatus) {
+	*out = *in
+	in.AlibabaCloudMachineProviderStatus.DeepCopyInto(&out.AlibabaCloudMachineProviderStatus)
+	if in.SpotStrategy != nil {
+		in, out := &in.SpotStrategy, &out.SpotStrategy
+		*out = new(SpotStrategy)
+		**out = **in
+	}
+	if in.DedicatedHostID != nil {
+		in, out := &in.DedicatedHostID, &out.DedicatedHostID
+		*out = new(string)
+		**out = **in
+	}
+	if in.DedicatedHostClusterID != nil {
+		in, out := &in.DedicatedHostClusterID, &out.DedicatedHostClusterID
+		*out = new(string)
+		**out = **in
+	}
+	if in.LaunchTemplateID != nil {
+		in, out := &in.LaunchTemplateID, &out.LaunchTemplateID
+		*out = new(string)
+		**out = **in
+	}
+	if in.LaunchTemplateVersion != nil {
+		in, out := &in.LaunchTemplateVersion, &out.LaunchTemplateVersion
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ImageID != nil {
+		in, out := &in.ImageID, &out.ImageID
+		*out = new(string)
+		**out = **in
+	}
+	if in.PrivateIPAddress != nil {
+		in, out := &in.PrivateIPAddress, &out.PrivateIPAddress
+		*out = new(string)
+		**out = **in
+	}
+	if in.NetworkInterfaces != nil {
+		in, out := &in.NetworkInterfaces, &out.NetworkInterfaces
+		*out = make([]NetworkInterfaceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PrivatePoolID != nil {
+		in, out := &in.PrivatePoolID, &out.PrivatePoolID
+		*out = new(string)
+		**out = **in
+	}
+	if in.VSwitchID != nil {
+		in, out := &in.VSwitchID, &out.VSwitchID
+		*out = new(string)
+		**out = **in
+	}
+	if in.ZoneID != nil {
+		in, out := &in.ZoneID, &out.ZoneID
+		*out = new(string)
+		**out = **in
+	}
+	if in.InstanceType != nil {
+		in, out := &in.InstanceType, &out.InstanceType
+		*out = new(string)
+		**out = **in
+	}
+	if in.Operation != nil {
+		in, out := &in.Operation, &out.Operation
+		*out = new(InstanceOperation)
+		**out = **in
+	}
+	if in.OperationStartTime != nil {
+		in, out := &in.OperationStartTime, &out.OperationStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ReleasedResources != nil {
+		in, out := &in.ReleasedResources, &out.ReleasedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlibabaCloudMachineProviderStatus.
+func (in *AlibabaCloudMachineProviderStatus) DeepCopy() *AlibabaCloudMachineProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AlibabaCloudMachineProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AlibabaCloudMachineProviderStatus) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CPUOptions) DeepCopyInto(out *CPUOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUOptions.
+func (in *CPUOptions) DeepCopy() *CPUOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CPUOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedHostPlacement) DeepCopyInto(out *DedicatedHostPlacement) {
+	*out = *in
+	if in.Host != nil {
+		in, out := &in.Host, &out.Host
+		*out = new(machinev1.AlibabaResourceReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostCluster != nil {
+		in, out := &in.HostCluster, &out.HostCluster
+		*out = new(machinev1.AlibabaResourceReference)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedHostPlacement.
+func (in *DedicatedHostPlacement) DeepCopy() *DedicatedHostPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedHostPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletePolicy) DeepCopyInto(out *DeletePolicy) {
+	*out = *in
+	if in.StopTimeout != nil {
+		in, out := &in.StopTimeout, &out.StopTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ForceStopTimeout != nil {
+		in, out := &in.ForceStopTimeout, &out.ForceStopTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletePolicy.
+func (in *DeletePolicy) DeepCopy() *DeletePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentSetReference) DeepCopyInto(out *DeploymentSetReference) {
+	*out = *in
+	if in.ID != nil {
+		in, out := &in.ID, &out.ID
+		*out = new(string)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = new([]machinev1.Tag)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]machinev1.Tag, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.GroupNo != nil {
+		in, out := &in.GroupNo, &out.GroupNo
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentSetReference.
+func (in *DeploymentSetReference) DeepCopy() *DeploymentSetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentSetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPv6Options) DeepCopyInto(out *IPv6Options) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPv6Options.
+func (in *IPv6Options) DeepCopy() *IPv6Options {
+	if in == nil {
+		return nil
+	}
+	out := new(IPv6Options)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSelector) DeepCopyInto(out *ImageSelector) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = new([]machinev1.Tag)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]machinev1.Tag, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.RegionImageIDs != nil {
+		in, out := &in.RegionImageIDs, &out.RegionImageIDs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSelector.
+func (in *ImageSelector) DeepCopy() *ImageSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LaunchTemplateReference) DeepCopyInto(out *LaunchTemplateReference) {
+	*out = *in
+	if in.Version != nil {
+		in, out := &in.Version, &out.Version
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LaunchTemplateReference.
+func (in *LaunchTemplateReference) DeepCopy() *LaunchTemplateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(LaunchTemplateReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataServiceOptions) DeepCopyInto(out *MetadataServiceOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataServiceOptions.
+func (in *MetadataServiceOptions) DeepCopy() *MetadataServiceOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataServiceOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterface) DeepCopyInto(out *NetworkInterface) {
+	*out = *in
+	in.VSwitch.DeepCopyInto(&out.VSwitch)
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]machinev1.AlibabaResourceReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterface.
+func (in *NetworkInterface) DeepCopy() *NetworkInterface {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterface)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterfaceStatus) DeepCopyInto(out *NetworkInterfaceStatus) {
+	*out = *in
+	if in.PrivateIPAddresses != nil {
+		in, out := &in.PrivateIPAddresses, &out.PrivateIPAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceStatus.
+func (in *NetworkInterfaceStatus) DeepCopy() *NetworkInterfaceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterfaceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateIPAddressPool) DeepCopyInto(out *PrivateIPAddressPool) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateIPAddressPool.
+func (in *PrivateIPAddressPool) DeepCopy() *PrivateIPAddressPool {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateIPAddressPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivatePoolOptions) DeepCopyInto(out *PrivatePoolOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivatePoolOptions.
+func (in *PrivatePoolOptions) DeepCopy() *PrivatePoolOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivatePoolOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningPolicy) DeepCopyInto(out *ProvisioningPolicy) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningPolicy.
+func (in *ProvisioningPolicy) DeepCopy() *ProvisioningPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpotMarketOptions) DeepCopyInto(out *SpotMarketOptions) {
+	*out = *in
+	if in.MaxPrice != nil {
+		in, out := &in.MaxPrice, &out.MaxPrice
+		*out = new(string)
+		**out = **in
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpotMarketOptions.
+func (in *SpotMarketOptions) DeepCopy() *SpotMarketOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SpotMarketOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionOptions) DeepCopyInto(out *SubscriptionOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionOptions.
+func (in *SubscriptionOptions) DeepCopy() *SubscriptionOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionOptions)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -62,6 +62,7 @@ type Client interface {
 	DescribeUserData(*ecs.DescribeUserDataRequest) (*ecs.DescribeUserDataResponse, error)
 	DescribeInstanceTypes(*ecs.DescribeInstanceTypesRequest) (*ecs.DescribeInstanceTypesResponse, error)
 	ModifyInstanceAttribute(*ecs.ModifyInstanceAttributeRequest) (*ecs.ModifyInstanceAttributeResponse, error)
+	ModifyInstanceChargeType(*ecs.ModifyInstanceChargeTypeRequest) (*ecs.ModifyInstanceChargeTypeResponse, error)
 	ModifyInstanceMetadataOptions(*ecs.ModifyInstanceMetadataOptionsRequest) (*ecs.ModifyInstanceMetadataOptionsResponse, error)
 
 	TagResources(*ecs.TagResourcesRequest) (*ecs.TagResourcesResponse, error)
@@ -70,6 +71,11 @@ type Client interface {
 
 	//Network
 	AllocatePublicIPAddress(*ecs.AllocatePublicIpAddressRequest) (*ecs.AllocatePublicIpAddressResponse, error)
+	DescribeNetworkInterfaces(*ecs.DescribeNetworkInterfacesRequest) (*ecs.DescribeNetworkInterfacesResponse, error)
+	CreateNetworkInterface(*ecs.CreateNetworkInterfaceRequest) (*ecs.CreateNetworkInterfaceResponse, error)
+	AttachNetworkInterface(*ecs.AttachNetworkInterfaceRequest) (*ecs.AttachNetworkInterfaceResponse, error)
+	DetachNetworkInterface(*ecs.DetachNetworkInterfaceRequest) (*ecs.DetachNetworkInterfaceResponse, error)
+	DeleteNetworkInterface(*ecs.DeleteNetworkInterfaceRequest) (*ecs.DeleteNetworkInterfaceResponse, error)
 
 	//Disk
 	CreateDisk(*ecs.CreateDiskRequest) (*ecs.CreateDiskResponse, error)
@@ -109,6 +115,21 @@ type Client interface {
 	ModifySecurityGroupRule(*ecs.ModifySecurityGroupRuleRequest) (*ecs.ModifySecurityGroupRuleResponse, error)
 	DeleteSecurityGroup(*ecs.DeleteSecurityGroupRequest) (*ecs.DeleteSecurityGroupResponse, error)
 
+	//DeploymentSet
+	CreateDeploymentSet(*ecs.CreateDeploymentSetRequest) (*ecs.CreateDeploymentSetResponse, error)
+	DescribeDeploymentSets(*ecs.DescribeDeploymentSetsRequest) (*ecs.DescribeDeploymentSetsResponse, error)
+	DeleteDeploymentSet(*ecs.DeleteDeploymentSetRequest) (*ecs.DeleteDeploymentSetResponse, error)
+
+	//DedicatedHost
+	DescribeDedicatedHosts(*ecs.DescribeDedicatedHostsRequest) (*ecs.DescribeDedicatedHostsResponse, error)
+	DescribeDedicatedHostClusters(*ecs.DescribeDedicatedHostClustersRequest) (*ecs.DescribeDedicatedHostClustersResponse, error)
+
+	//LaunchTemplate
+	DescribeLaunchTemplates(*ecs.DescribeLaunchTemplatesRequest) (*ecs.DescribeLaunchTemplatesResponse, error)
+
+	//KeyPair
+	DescribeKeyPairs(*ecs.DescribeKeyPairsRequest) (*ecs.DescribeKeyPairsResponse, error)
+
 	//VPC
 	CreateVpc(*vpc.CreateVpcRequest) (*vpc.CreateVpcResponse, error)
 	DeleteVpc(*vpc.DeleteVpcRequest) (*vpc.DeleteVpcResponse, error)
@@ -134,6 +155,7 @@ type Client interface {
 	CreateLoadBalancer(*slb.CreateLoadBalancerRequest) (*slb.CreateLoadBalancerResponse, error)
 	DeleteLoadBalancer(*slb.DeleteLoadBalancerRequest) (*slb.DeleteLoadBalancerResponse, error)
 	DescribeLoadBalancers(*slb.DescribeLoadBalancersRequest) (*slb.DescribeLoadBalancersResponse, error)
+	DescribeLoadBalancerAttribute(*slb.DescribeLoadBalancerAttributeRequest) (*slb.DescribeLoadBalancerAttributeResponse, error)
 	CreateLoadBalancerTCPListener(*slb.CreateLoadBalancerTCPListenerRequest) (*slb.CreateLoadBalancerTCPListenerResponse, error)
 	SetLoadBalancerTCPListenerAttribute(*slb.SetLoadBalancerTCPListenerAttributeRequest) (*slb.SetLoadBalancerTCPListenerAttributeResponse, error)
 	DescribeLoadBalancerTCPListenerAttribute(*slb.DescribeLoadBalancerTCPListenerAttributeRequest) (*slb.DescribeLoadBalancerTCPListenerAttributeResponse, error)
@@ -246,6 +268,10 @@ func (client *alibabacloudClient) ModifyInstanceAttribute(request *ecs.ModifyIns
 	return client.ecsClient.ModifyInstanceAttribute(request)
 }
 
+func (client *alibabacloudClient) ModifyInstanceChargeType(request *ecs.ModifyInstanceChargeTypeRequest) (*ecs.ModifyInstanceChargeTypeResponse, error) {
+	return client.ecsClient.ModifyInstanceChargeType(request)
+}
+
 func (client *alibabacloudClient) ModifyInstanceMetadataOptions(request *ecs.ModifyInstanceMetadataOptionsRequest) (*ecs.ModifyInstanceMetadataOptionsResponse, error) {
 	return client.ecsClient.ModifyInstanceMetadataOptions(request)
 }
@@ -254,6 +280,26 @@ func (client *alibabacloudClient) AllocatePublicIPAddress(request *ecs.AllocateP
 	return client.ecsClient.AllocatePublicIpAddress(request)
 }
 
+func (client *alibabacloudClient) DescribeNetworkInterfaces(request *ecs.DescribeNetworkInterfacesRequest) (*ecs.DescribeNetworkInterfacesResponse, error) {
+	return client.ecsClient.DescribeNetworkInterfaces(request)
+}
+
+func (client *alibabacloudClient) CreateNetworkInterface(request *ecs.CreateNetworkInterfaceRequest) (*ecs.CreateNetworkInterfaceResponse, error) {
+	return client.ecsClient.CreateNetworkInterface(request)
+}
+
+func (client *alibabacloudClient) AttachNetworkInterface(request *ecs.AttachNetworkInterfaceRequest) (*ecs.AttachNetworkInterfaceResponse, error) {
+	return client.ecsClient.AttachNetworkInterface(request)
+}
+
+func (client *alibabacloudClient) DetachNetworkInterface(request *ecs.DetachNetworkInterfaceRequest) (*ecs.DetachNetworkInterfaceResponse, error) {
+	return client.ecsClient.DetachNetworkInterface(request)
+}
+
+func (client *alibabacloudClient) DeleteNetworkInterface(request *ecs.DeleteNetworkInterfaceRequest) (*ecs.DeleteNetworkInterfaceResponse, error) {
+	return client.ecsClient.DeleteNetworkInterface(request)
+}
+
 func (client *alibabacloudClient) CreateDisk(request *ecs.CreateDiskRequest) (*ecs.CreateDiskResponse, error) {
 	return client.ecsClient.CreateDisk(request)
 }
@@ -374,6 +420,34 @@ func (client *alibabacloudClient) DeleteSecurityGroup(request *ecs.DeleteSecurit
 	return client.ecsClient.DeleteSecurityGroup(request)
 }
 
+func (client *alibabacloudClient) CreateDeploymentSet(request *ecs.CreateDeploymentSetRequest) (*ecs.CreateDeploymentSetResponse, error) {
+	return client.ecsClient.CreateDeploymentSet(request)
+}
+
+func (client *alibabacloudClient) DescribeDeploymentSets(request *ecs.DescribeDeploymentSetsRequest) (*ecs.DescribeDeploymentSetsResponse, error) {
+	return client.ecsClient.DescribeDeploymentSets(request)
+}
+
+func (client *alibabacloudClient) DeleteDeploymentSet(request *ecs.DeleteDeploymentSetRequest) (*ecs.DeleteDeploymentSetResponse, error) {
+	return client.ecsClient.DeleteDeploymentSet(request)
+}
+
+func (client *alibabacloudClient) DescribeDedicatedHosts(request *ecs.DescribeDedicatedHostsRequest) (*ecs.DescribeDedicatedHostsResponse, error) {
+	return client.ecsClient.DescribeDedicatedHosts(request)
+}
+
+func (client *alibabacloudClient) DescribeDedicatedHostClusters(request *ecs.DescribeDedicatedHostClustersRequest) (*ecs.DescribeDedicatedHostClustersResponse, error) {
+	return client.ecsClient.DescribeDedicatedHostClusters(request)
+}
+
+func (client *alibabacloudClient) DescribeLaunchTemplates(request *ecs.DescribeLaunchTemplatesRequest) (*ecs.DescribeLaunchTemplatesResponse, error) {
+	return client.ecsClient.DescribeLaunchTemplates(request)
+}
+
+func (client *alibabacloudClient) DescribeKeyPairs(request *ecs.DescribeKeyPairsRequest) (*ecs.DescribeKeyPairsResponse, error) {
+	return client.ecsClient.DescribeKeyPairs(request)
+}
+
 func (client *alibabacloudClient) TagResources(request *ecs.TagResourcesRequest) (*ecs.TagResourcesResponse, error) {
 	return client.ecsClient.TagResources(request)
 }
@@ -458,6 +532,10 @@ func (client *alibabacloudClient) DescribeLoadBalancers(request *slb.DescribeLoa
 	return client.slbClient.DescribeLoadBalancers(request)
 }
 
+func (client *alibabacloudClient) DescribeLoadBalancerAttribute(request *slb.DescribeLoadBalancerAttributeRequest) (*slb.DescribeLoadBalancerAttributeResponse, error) {
+	return client.slbClient.DescribeLoadBalancerAttribute(request)
+}
+
 func (client *alibabacloudClient) CreateLoadBalancerTCPListener(request *slb.CreateLoadBalancerTCPListenerRequest) (*slb.CreateLoadBalancerTCPListenerResponse, error) {
 	return client.slbClient.CreateLoadBalancerTCPListener(request)
 }
@@ -142,6 +142,21 @@ func (mr *MockClientMockRecorder) AttachInstanceRAMRole(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachInstanceRAMRole", reflect.TypeOf((*MockClient)(nil).AttachInstanceRAMRole), arg0)
 }
 
+// AttachNetworkInterface mocks base method.
+func (m *MockClient) AttachNetworkInterface(arg0 *ecs.AttachNetworkInterfaceRequest) (*ecs.AttachNetworkInterfaceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AttachNetworkInterface", arg0)
+	ret0, _ := ret[0].(*ecs.AttachNetworkInterfaceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AttachNetworkInterface indicates an expected call of AttachNetworkInterface.
+func (mr *MockClientMockRecorder) AttachNetworkInterface(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachNetworkInterface", reflect.TypeOf((*MockClient)(nil).AttachNetworkInterface), arg0)
+}
+
 // AuthorizeSecurityGroup mocks base method.
 func (m *MockClient) AuthorizeSecurityGroup(arg0 *ecs.AuthorizeSecurityGroupRequest) (*ecs.AuthorizeSecurityGroupResponse, error) {
 	m.ctrl.T.Helper()
@@ -172,6 +187,21 @@ func (mr *MockClientMockRecorder) AuthorizeSecurityGroupEgress(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthorizeSecurityGroupEgress", reflect.TypeOf((*MockClient)(nil).AuthorizeSecurityGroupEgress), arg0)
 }
 
+// CreateDeploymentSet mocks base method.
+func (m *MockClient) CreateDeploymentSet(arg0 *ecs.CreateDeploymentSetRequest) (*ecs.CreateDeploymentSetResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeploymentSet", arg0)
+	ret0, _ := ret[0].(*ecs.CreateDeploymentSetResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeploymentSet indicates an expected call of CreateDeploymentSet.
+func (mr *MockClientMockRecorder) CreateDeploymentSet(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeploymentSet", reflect.TypeOf((*MockClient)(nil).CreateDeploymentSet), arg0)
+}
+
 // CreateDisk mocks base method.
 func (m *MockClient) CreateDisk(arg0 *ecs.CreateDiskRequest) (*ecs.CreateDiskResponse, error) {
 	m.ctrl.T.Helper()
@@ -292,6 +322,21 @@ func (mr *MockClientMockRecorder) CreateNatGateway(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNatGateway", reflect.TypeOf((*MockClient)(nil).CreateNatGateway), arg0)
 }
 
+// CreateNetworkInterface mocks base method.
+func (m *MockClient) CreateNetworkInterface(arg0 *ecs.CreateNetworkInterfaceRequest) (*ecs.CreateNetworkInterfaceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateNetworkInterface", arg0)
+	ret0, _ := ret[0].(*ecs.CreateNetworkInterfaceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateNetworkInterface indicates an expected call of CreateNetworkInterface.
+func (mr *MockClientMockRecorder) CreateNetworkInterface(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNetworkInterface", reflect.TypeOf((*MockClient)(nil).CreateNetworkInterface), arg0)
+}
+
 // CreateSecurityGroup mocks base method.
 func (m *MockClient) CreateSecurityGroup(arg0 *ecs.CreateSecurityGroupRequest) (*ecs.CreateSecurityGroupResponse, error) {
 	m.ctrl.T.Helper()
@@ -352,6 +397,21 @@ func (mr *MockClientMockRecorder) CreateVpc(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVpc", reflect.TypeOf((*MockClient)(nil).CreateVpc), arg0)
 }
 
+// DeleteDeploymentSet mocks base method.
+func (m *MockClient) DeleteDeploymentSet(arg0 *ecs.DeleteDeploymentSetRequest) (*ecs.DeleteDeploymentSetResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeploymentSet", arg0)
+	ret0, _ := ret[0].(*ecs.DeleteDeploymentSetResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteDeploymentSet indicates an expected call of DeleteDeploymentSet.
+func (mr *MockClientMockRecorder) DeleteDeploymentSet(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeploymentSet", reflect.TypeOf((*MockClient)(nil).DeleteDeploymentSet), arg0)
+}
+
 // DeleteDisk mocks base method.
 func (m *MockClient) DeleteDisk(arg0 *ecs.DeleteDiskRequest) (*ecs.DeleteDiskResponse, error) {
 	m.ctrl.T.Helper()
@@ -442,6 +502,21 @@ func (mr *MockClientMockRecorder) DeleteNatGateway(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNatGateway", reflect.TypeOf((*MockClient)(nil).DeleteNatGateway), arg0)
 }
 
+// DeleteNetworkInterface mocks base method.
+func (m *MockClient) DeleteNetworkInterface(arg0 *ecs.DeleteNetworkInterfaceRequest) (*ecs.DeleteNetworkInterfaceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteNetworkInterface", arg0)
+	ret0, _ := ret[0].(*ecs.DeleteNetworkInterfaceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteNetworkInterface indicates an expected call of DeleteNetworkInterface.
+func (mr *MockClientMockRecorder) DeleteNetworkInterface(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNetworkInterface", reflect.TypeOf((*MockClient)(nil).DeleteNetworkInterface), arg0)
+}
+
 // DeleteSecurityGroup mocks base method.
 func (m *MockClient) DeleteSecurityGroup(arg0 *ecs.DeleteSecurityGroupRequest) (*ecs.DeleteSecurityGroupResponse, error) {
 	m.ctrl.T.Helper()
@@ -502,6 +577,51 @@ func (mr *MockClientMockRecorder) DeleteVpc(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVpc", reflect.TypeOf((*MockClient)(nil).DeleteVpc), arg0)
 }
 
+// DescribeDedicatedHostClusters mocks base method.
+func (m *MockClient) DescribeDedicatedHostClusters(arg0 *ecs.DescribeDedicatedHostClustersRequest) (*ecs.DescribeDedicatedHostClustersResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeDedicatedHostClusters", arg0)
+	ret0, _ := ret[0].(*ecs.DescribeDedicatedHostClustersResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeDedicatedHostClusters indicates an expected call of DescribeDedicatedHostClusters.
+func (mr *MockClientMockRecorder) DescribeDedicatedHostClusters(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeDedicatedHostClusters", reflect.TypeOf((*MockClient)(nil).DescribeDedicatedHostClusters), arg0)
+}
+
+// DescribeDedicatedHosts mocks base method.
+func (m *MockClient) DescribeDedicatedHosts(arg0 *ecs.DescribeDedicatedHostsRequest) (*ecs.DescribeDedicatedHostsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeDedicatedHosts", arg0)
+	ret0, _ := ret[0].(*ecs.DescribeDedicatedHostsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeDedicatedHosts indicates an expected call of DescribeDedicatedHosts.
+func (mr *MockClientMockRecorder) DescribeDedicatedHosts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeDedicatedHosts", reflect.TypeOf((*MockClient)(nil).DescribeDedicatedHosts), arg0)
+}
+
+// DescribeDeploymentSets mocks base method.
+func (m *MockClient) DescribeDeploymentSets(arg0 *ecs.DescribeDeploymentSetsRequest) (*ecs.DescribeDeploymentSetsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeDeploymentSets", arg0)
+	ret0, _ := ret[0].(*ecs.DescribeDeploymentSetsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeDeploymentSets indicates an expected call of DescribeDeploymentSets.
+func (mr *MockClientMockRecorder) DescribeDeploymentSets(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeDeploymentSets", reflect.TypeOf((*MockClient)(nil).DescribeDeploymentSets), arg0)
+}
+
 // DescribeDisks mocks base method.
 func (m *MockClient) DescribeDisks(arg0 *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
 	m.ctrl.T.Helper()
@@ -607,6 +727,51 @@ func (mr *MockClientMockRecorder) DescribeInstances(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeInstances", reflect.TypeOf((*MockClient)(nil).DescribeInstances), arg0)
 }
 
+// DescribeKeyPairs mocks base method.
+func (m *MockClient) DescribeKeyPairs(arg0 *ecs.DescribeKeyPairsRequest) (*ecs.DescribeKeyPairsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeKeyPairs", arg0)
+	ret0, _ := ret[0].(*ecs.DescribeKeyPairsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeKeyPairs indicates an expected call of DescribeKeyPairs.
+func (mr *MockClientMockRecorder) DescribeKeyPairs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeKeyPairs", reflect.TypeOf((*MockClient)(nil).DescribeKeyPairs), arg0)
+}
+
+// DescribeLaunchTemplates mocks base method.
+func (m *MockClient) DescribeLaunchTemplates(arg0 *ecs.DescribeLaunchTemplatesRequest) (*ecs.DescribeLaunchTemplatesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeLaunchTemplates", arg0)
+	ret0, _ := ret[0].(*ecs.DescribeLaunchTemplatesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeLaunchTemplates indicates an expected call of DescribeLaunchTemplates.
+func (mr *MockClientMockRecorder) DescribeLaunchTemplates(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeLaunchTemplates", reflect.TypeOf((*MockClient)(nil).DescribeLaunchTemplates), arg0)
+}
+
+// DescribeLoadBalancerAttribute mocks base method.
+func (m *MockClient) DescribeLoadBalancerAttribute(arg0 *slb.DescribeLoadBalancerAttributeRequest) (*slb.DescribeLoadBalancerAttributeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeLoadBalancerAttribute", arg0)
+	ret0, _ := ret[0].(*slb.DescribeLoadBalancerAttributeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeLoadBalancerAttribute indicates an expected call of DescribeLoadBalancerAttribute.
+func (mr *MockClientMockRecorder) DescribeLoadBalancerAttribute(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeLoadBalancerAttribute", reflect.TypeOf((*MockClient)(nil).DescribeLoadBalancerAttribute), arg0)
+}
+
 // DescribeLoadBalancerHTTPListenerAttribute mocks base method.
 func (m *MockClient) DescribeLoadBalancerHTTPListenerAttribute(arg0 *slb.DescribeLoadBalancerHTTPListenerAttributeRequest) (*slb.DescribeLoadBalancerHTTPListenerAttributeResponse, error) {
 	m.ctrl.T.Helper()
@@ -712,6 +877,21 @@ func (mr *MockClientMockRecorder) DescribeNatGateways(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeNatGateways", reflect.TypeOf((*MockClient)(nil).DescribeNatGateways), arg0)
 }
 
+// DescribeNetworkInterfaces mocks base method.
+func (m *MockClient) DescribeNetworkInterfaces(arg0 *ecs.DescribeNetworkInterfacesRequest) (*ecs.DescribeNetworkInterfacesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeNetworkInterfaces", arg0)
+	ret0, _ := ret[0].(*ecs.DescribeNetworkInterfacesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeNetworkInterfaces indicates an expected call of DescribeNetworkInterfaces.
+func (mr *MockClientMockRecorder) DescribeNetworkInterfaces(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeNetworkInterfaces", reflect.TypeOf((*MockClient)(nil).DescribeNetworkInterfaces), arg0)
+}
+
 // DescribeRegions mocks base method.
 func (m *MockClient) DescribeRegions(arg0 *ecs.DescribeRegionsRequest) (*ecs.DescribeRegionsResponse, error) {
 	m.ctrl.T.Helper()
@@ -892,6 +1072,21 @@ func (mr *MockClientMockRecorder) DetachInstanceRAMRole(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachInstanceRAMRole", reflect.TypeOf((*MockClient)(nil).DetachInstanceRAMRole), arg0)
 }
 
+// DetachNetworkInterface mocks base method.
+func (m *MockClient) DetachNetworkInterface(arg0 *ecs.DetachNetworkInterfaceRequest) (*ecs.DetachNetworkInterfaceResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DetachNetworkInterface", arg0)
+	ret0, _ := ret[0].(*ecs.DetachNetworkInterfaceResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DetachNetworkInterface indicates an expected call of DetachNetworkInterface.
+func (mr *MockClientMockRecorder) DetachNetworkInterface(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachNetworkInterface", reflect.TypeOf((*MockClient)(nil).DetachNetworkInterface), arg0)
+}
+
 // JoinSecurityGroup mocks base method.
 func (m *MockClient) JoinSecurityGroup(arg0 *ecs.JoinSecurityGroupRequest) (*ecs.JoinSecurityGroupResponse, error) {
 	m.ctrl.T.Helper()
@@ -1027,6 +1222,21 @@ func (mr *MockClientMockRecorder) ModifyInstanceAttribute(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifyInstanceAttribute", reflect.TypeOf((*MockClient)(nil).ModifyInstanceAttribute), arg0)
 }
 
+// ModifyInstanceChargeType mocks base method.
+func (m *MockClient) ModifyInstanceChargeType(arg0 *ecs.ModifyInstanceChargeTypeRequest) (*ecs.ModifyInstanceChargeTypeResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModifyInstanceChargeType", arg0)
+	ret0, _ := ret[0].(*ecs.ModifyInstanceChargeTypeResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ModifyInstanceChargeType indicates an expected call of ModifyInstanceChargeType.
+func (mr *MockClientMockRecorder) ModifyInstanceChargeType(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifyInstanceChargeType", reflect.TypeOf((*MockClient)(nil).ModifyInstanceChargeType), arg0)
+}
+
 // ModifyInstanceMetadataOptions mocks base method.
 func (m *MockClient) ModifyInstanceMetadataOptions(arg0 *ecs.ModifyInstanceMetadataOptionsRequest) (*ecs.ModifyInstanceMetadataOptionsResponse, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,357 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package termination
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultMetadataURL is the address of the ECS instance metadata service
+	DefaultMetadataURL = "http://100.100.100.200/latest/meta-data/"
+
+	// terminationEndpoint returns the time at which the spot instance is reclaimed,
+	// or a 404 when no termination notice has been issued
+	terminationEndpoint = "instance/spot/termination-time"
+
+	// tokenEndpoint returns the token authenticating the requests to the metadata service,
+	// which is required when the instance only allows metadata access in token mode.
+	// It is resolved relatively to the metadata URL.
+	tokenEndpoint = "../api/token"
+
+	// metadataTokenHeader is the header carrying the token of the metadata service
+	metadataTokenHeader = "X-aliyun-ecs-metadata-token"
+
+	// metadataTokenTTLHeader is the header setting the lifetime of a requested metadata token
+	metadataTokenTTLHeader = "X-aliyun-ecs-metadata-token-ttl-seconds"
+
+	// metadataTokenTTL is the lifetime of the metadata tokens, a token is renewed a minute before it expires
+	metadataTokenTTL = 6 * time.Hour
+
+	// machineAnnotationKey is the annotation set on the Node by the node link controller
+	// which references the Machine backing the Node, in the form namespace/name
+	machineAnnotationKey = "machine.openshift.io/machine"
+
+	// TerminatingConditionType is the Node condition set when a termination notice is received
+	TerminatingConditionType corev1.NodeConditionType = "Terminating"
+
+	// TerminationRequestedReason is the reason of the Terminating condition
+	TerminationRequestedReason = "TerminationRequested"
+
+	// TerminationTimeAnnotation records on the Machine the time at which the spot instance is reclaimed
+	TerminationTimeAnnotation = "machine.openshift.io/spot-termination-time"
+)
+
+// Handler represents a handler that will run to check the termination
+// notice endpoint and mark the Node and Machine for deletion if the instance
+// is about to be reclaimed.
+type Handler interface {
+	Run(stop <-chan struct{}) error
+}
+
+// NewHandler constructs a new Handler
+func NewHandler(logger logr.Logger, cfg *rest.Config, scheme *runtime.Scheme, pollInterval time.Duration, metadataURL, nodeName string) (Handler, error) {
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %v", err)
+	}
+
+	pollURL, err := buildPollURL(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	tokenURL, err := buildTokenURL(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &handler{
+		client:       c,
+		pollURL:      pollURL,
+		tokenURL:     tokenURL,
+		pollInterval: pollInterval,
+		nodeName:     nodeName,
+		log:          logger.WithValues("node", nodeName),
+	}, nil
+}
+
+// handler implements the logic to check the termination endpoint and
+// mark the Node and Machine when the instance is going to be reclaimed
+type handler struct {
+	client       client.Client
+	pollURL      *url.URL
+	tokenURL     *url.URL
+	pollInterval time.Duration
+	nodeName     string
+	log          logr.Logger
+
+	// token is the metadata service token, reused until tokenExpiry
+	token       string
+	tokenExpiry time.Time
+}
+
+// Run starts the handler and runs the termination logic
+func (h *handler) Run(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- h.run(ctx)
+	}()
+
+	select {
+	case <-stop:
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+func (h *handler) run(ctx context.Context) error {
+	h.log.V(1).Info("Monitoring node for spot termination", "url", h.pollURL.String())
+
+	var terminationTime string
+	if err := wait.PollImmediateUntil(h.pollInterval, func() (bool, error) {
+		var err error
+		terminationTime, err = h.getTerminationTime(ctx)
+		if err != nil {
+			return false, err
+		}
+		return terminationTime != "", nil
+	}, ctx.Done()); err != nil {
+		if err == wait.ErrWaitTimeout {
+			// The stop channel was closed
+			return nil
+		}
+		return fmt.Errorf("error polling termination endpoint: %v", err)
+	}
+
+	h.log.Info("Instance marked for termination", "terminationTime", terminationTime)
+
+	node, err := h.markNode(ctx, terminationTime)
+	if err != nil {
+		return err
+	}
+
+	return h.markMachine(ctx, node, terminationTime)
+}
+
+// getTerminationTime returns the termination time of the spot instance,
+// or an empty string when no termination notice has been issued yet
+func (h *handler) getTerminationTime(ctx context.Context) (string, error) {
+	token, err := h.getToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.pollURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("could not build request: %v", err)
+	}
+	req.Header.Set(metadataTokenHeader, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not get URL %q: %v", h.pollURL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		// Instance not terminated yet
+		h.log.V(2).Info("Instance not marked for termination")
+		return "", nil
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("could not read response body: %v", err)
+		}
+		return strings.TrimSpace(string(body)), nil
+	default:
+		return "", fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+// getToken returns the token of the metadata service, a new token is requested when the current one is about to expire
+func (h *handler) getToken(ctx context.Context) (string, error) {
+	if h.token != "" && time.Now().Before(h.tokenExpiry) {
+		return h.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, h.tokenURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("could not build token request: %v", err)
+	}
+	req.Header.Set(metadataTokenTTLHeader, fmt.Sprintf("%d", int(metadataTokenTTL.Seconds())))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not get metadata token from %q: %v", h.tokenURL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status requesting metadata token: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not read metadata token: %v", err)
+	}
+
+	h.token = strings.TrimSpace(string(body))
+	h.tokenExpiry = time.Now().Add(metadataTokenTTL - time.Minute)
+	return h.token, nil
+}
+
+// markNode sets the Terminating condition on the Node
+func (h *handler) markNode(ctx context.Context, terminationTime string) (*corev1.Node, error) {
+	node := &corev1.Node{}
+	if err := h.client.Get(ctx, types.NamespacedName{Name: h.nodeName}, node); err != nil {
+		return nil, fmt.Errorf("error fetching node %q: %v", h.nodeName, err)
+	}
+
+	condition := corev1.NodeCondition{
+		Type:               TerminatingConditionType,
+		Status:             corev1.ConditionTrue,
+		LastHeartbeatTime:  metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             TerminationRequestedReason,
+		Message:            fmt.Sprintf("The spot instance is going to be reclaimed at %s", terminationTime),
+	}
+
+	patchBase := client.MergeFrom(node.DeepCopy())
+	setNodeCondition(node, condition)
+	if err := h.client.Status().Patch(ctx, node, patchBase); err != nil {
+		return nil, fmt.Errorf("error patching node %q: %v", h.nodeName, err)
+	}
+
+	h.log.Info("Node marked with Terminating condition")
+	return node, nil
+}
+
+// markMachine annotates the Machine backing the Node with the termination time
+// and deletes it, so that the machine controller drains the Node and the
+// MachineSet replaces the Machine before the instance is reclaimed
+func (h *handler) markMachine(ctx context.Context, node *corev1.Node, terminationTime string) error {
+	machineKey, ok := node.Annotations[machineAnnotationKey]
+	if !ok || machineKey == "" {
+		h.log.Info("Node has no machine annotation, skipping machine deletion")
+		return nil
+	}
+
+	namespace, name, err := splitMachineKey(machineKey)
+	if err != nil {
+		return err
+	}
+
+	machine := &machinev1beta1.Machine{}
+	if err := h.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, machine); err != nil {
+		if apierrors.IsNotFound(err) {
+			h.log.Info("Machine not found, skipping machine deletion", "machine", machineKey)
+			return nil
+		}
+		return fmt.Errorf("error fetching machine %q: %v", machineKey, err)
+	}
+
+	if machine.DeletionTimestamp == nil {
+		patchBase := client.MergeFrom(machine.DeepCopy())
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[TerminationTimeAnnotation] = terminationTime
+		if err := h.client.Patch(ctx, machine, patchBase); err != nil {
+			return fmt.Errorf("error patching machine %q: %v", machineKey, err)
+		}
+
+		if err := h.client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting machine %q: %v", machineKey, err)
+		}
+	}
+
+	h.log.Info("Machine marked for deletion", "machine", machineKey)
+	return nil
+}
+
+// setNodeCondition adds or replaces the condition on the Node
+func setNodeCondition(node *corev1.Node, condition corev1.NodeCondition) {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == condition.Type {
+			if node.Status.Conditions[i].Status == condition.Status {
+				condition.LastTransitionTime = node.Status.Conditions[i].LastTransitionTime
+			}
+			node.Status.Conditions[i] = condition
+			return
+		}
+	}
+	node.Status.Conditions = append(node.Status.Conditions, condition)
+}
+
+func splitMachineKey(machineKey string) (string, string, error) {
+	parts := strings.Split(machineKey, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid machine annotation %q, expected namespace/name", machineKey)
+	}
+	return parts[0], parts[1], nil
+}
+
+func buildPollURL(metadataURL string) (*url.URL, error) {
+	baseURL, err := parseMetadataURL(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	return baseURL.ResolveReference(&url.URL{Path: terminationEndpoint}), nil
+}
+
+func buildTokenURL(metadataURL string) (*url.URL, error) {
+	baseURL, err := parseMetadataURL(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	return baseURL.ResolveReference(&url.URL{Path: tokenEndpoint}), nil
+}
+
+func parseMetadataURL(metadataURL string) (*url.URL, error) {
+	if !strings.HasSuffix(metadataURL, "/") {
+		metadataURL += "/"
+	}
+	baseURL, err := url.Parse(metadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing metadata URL %q: %v", metadataURL, err)
+	}
+	return baseURL, nil
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package termination
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNodeName         = "test-node"
+	testMachineName      = "test-machine"
+	testMachineNamespace = "openshift-machine-api"
+	testTerminationTime  = "2021-06-01T08:00:00Z"
+	testMetadataToken    = "test-metadata-token"
+)
+
+func newTestHandler(t *testing.T, metadataURL string, objects ...client.Object) *handler {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, machinev1beta1.AddToScheme(scheme))
+
+	pollURL, err := buildPollURL(metadataURL)
+	assert.NoError(t, err)
+	tokenURL, err := buildTokenURL(metadataURL)
+	assert.NoError(t, err)
+
+	return &handler{
+		client:       fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		pollURL:      pollURL,
+		tokenURL:     tokenURL,
+		pollInterval: 10 * time.Millisecond,
+		nodeName:     testNodeName,
+		log:          klogr.New(),
+	}
+}
+
+// serveMetadataToken answers the token requests of the handler, it returns false for other requests
+func serveMetadataToken(t *testing.T, w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path != "/latest/api/token" {
+		return false
+	}
+	assert.Equal(t, http.MethodPut, r.Method)
+	assert.Equal(t, "21600", r.Header.Get(metadataTokenTTLHeader))
+	w.Write([]byte(testMetadataToken))
+	return true
+}
+
+func stubNode() *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: testNodeName,
+			Annotations: map[string]string{
+				machineAnnotationKey: testMachineNamespace + "/" + testMachineName,
+			},
+		},
+	}
+}
+
+func stubMachine() *machinev1beta1.Machine {
+	return &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testMachineName,
+			Namespace: testMachineNamespace,
+		},
+	}
+}
+
+func TestHandlerRun(t *testing.T) {
+	cases := []struct {
+		name                string
+		responses           []int
+		expectError         bool
+		expectTerminating   bool
+		expectMachineExists bool
+	}{
+		{
+			name:                "Termination notice issued after a few polls",
+			responses:           []int{http.StatusNotFound, http.StatusNotFound, http.StatusOK},
+			expectTerminating:   true,
+			expectMachineExists: false,
+		},
+		{
+			name:                "Metadata token rejected",
+			responses:           []int{http.StatusForbidden},
+			expectError:         true,
+			expectMachineExists: true,
+		},
+		{
+			name:                "Unexpected status from the metadata service",
+			responses:           []int{http.StatusInternalServerError},
+			expectError:         true,
+			expectMachineExists: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests, tokenRequests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if serveMetadataToken(t, w, r) {
+					atomic.AddInt32(&tokenRequests, 1)
+					return
+				}
+				assert.Equal(t, "/latest/meta-data/instance/spot/termination-time", r.URL.Path)
+				assert.Equal(t, testMetadataToken, r.Header.Get(metadataTokenHeader))
+				i := int(atomic.AddInt32(&requests, 1)) - 1
+				if i >= len(tc.responses) {
+					i = len(tc.responses) - 1
+				}
+				w.WriteHeader(tc.responses[i])
+				if tc.responses[i] == http.StatusOK {
+					w.Write([]byte(testTerminationTime))
+				}
+			}))
+			defer server.Close()
+
+			h := newTestHandler(t, server.URL+"/latest/meta-data", stubNode(), stubMachine())
+
+			stop := make(chan struct{})
+			defer close(stop)
+			err := h.Run(stop)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			// The token is reused until it expires
+			assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+
+			ctx := context.Background()
+			node := &corev1.Node{}
+			assert.NoError(t, h.client.Get(ctx, types.NamespacedName{Name: testNodeName}, node))
+			var terminating *corev1.NodeCondition
+			for i := range node.Status.Conditions {
+				if node.Status.Conditions[i].Type == TerminatingConditionType {
+					terminating = &node.Status.Conditions[i]
+				}
+			}
+			if tc.expectTerminating {
+				if assert.NotNil(t, terminating) {
+					assert.Equal(t, corev1.ConditionTrue, terminating.Status)
+					assert.Equal(t, TerminationRequestedReason, terminating.Reason)
+					assert.Contains(t, terminating.Message, testTerminationTime)
+				}
+			} else {
+				assert.Nil(t, terminating)
+			}
+
+			err = h.client.Get(ctx, types.NamespacedName{Namespace: testMachineNamespace, Name: testMachineName}, &machinev1beta1.Machine{})
+			if tc.expectMachineExists {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, apierrors.IsNotFound(err))
+			}
+		})
+	}
+}
+
+func TestHandlerRunStops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serveMetadataToken(t, w, r) {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	h := newTestHandler(t, server.URL+"/latest/meta-data/", stubNode(), stubMachine())
+
+	stop := make(chan struct{})
+	errs := make(chan error)
+	go func() {
+		errs <- h.Run(stop)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+
+	select {
+	case err := <-errs:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not stop")
+	}
+
+	err := h.client.Get(context.Background(), types.NamespacedName{Namespace: testMachineNamespace, Name: testMachineName}, &machinev1beta1.Machine{})
+	assert.NoError(t, err)
+}
+
+func TestSplitMachineKey(t *testing.T) {
+	namespace, name, err := splitMachineKey("openshift-machine-api/machine-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "openshift-machine-api", namespace)
+	assert.Equal(t, "machine-a", name)
+
+	for _, key := range []string{"machine-a", "/machine-a", "a/b/c"} {
+		_, _, err := splitMachineKey(key)
+		assert.Error(t, err, key)
+	}
+}